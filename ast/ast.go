@@ -1,12 +1,37 @@
 package ast
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"strings"
+
+	"github.com/jellycat-io/eevee/decimal"
+	"github.com/jellycat-io/eevee/token"
 )
 
 type Node interface {
 	String() string
+	// Pos returns the position of the first character of the node.
+	Pos() token.Position
+	// End returns the position just after the last character of the node.
+	End() token.Position
+}
+
+// Span is the part of the source a node was parsed from. Every node embeds
+// it, which gives them their Pos and End methods.
+type Span struct {
+	StartPos token.Position `json:"start"`
+	EndPos   token.Position `json:"end"`
+}
+
+func (s Span) Pos() token.Position { return s.StartPos }
+func (s Span) End() token.Position { return s.EndPos }
+
+// SetSpan sets the positions of the node, as the parser does once it has
+// read its last token.
+func (s *Span) SetSpan(start, end token.Position) {
+	s.StartPos, s.EndPos = start, end
 }
 
 type Statement interface {
@@ -23,6 +48,7 @@ type Program struct {
 	// program ::= statements EOF
 	Type       string      `json:"type"`
 	Statements []Statement `json:"statements"`
+	Span
 }
 
 func (p *Program) String() string {
@@ -47,6 +73,7 @@ type BlockStatement struct {
 	// block_statement ::= INDENT statements DEDENT
 	Type       string      `json:"type"`
 	Statements []Statement `json:"statements"`
+	Span
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -75,6 +102,7 @@ type FunctionDeclaration struct {
 	Name       Identifier   `json:"name"`
 	Parameters []Identifier `json:"parameters"`
 	Body       Statement    `json:"body"`
+	Span
 }
 
 func (fd *FunctionDeclaration) statementNode() {}
@@ -103,6 +131,7 @@ func NewFunctionDeclaration(name Identifier, parameters []Identifier, body State
 type ReturnStatement struct {
 	Type  string     `json:"type"`
 	Value Expression `json:"value"`
+	Span
 }
 
 func (rs *ReturnStatement) statementNode() {}
@@ -122,6 +151,7 @@ type VariableStatement struct {
 	// variable_declaration_list ::= variable_declaration { COMMA variable_declaration }
 	Type         string                 `json:"type"`
 	Declarations []*VariableDeclaration `json:"declarations"`
+	Span
 }
 
 func (vs *VariableStatement) statementNode() {}
@@ -148,6 +178,7 @@ type VariableDeclaration struct {
 	Type        string     `json:"type"`
 	Identifier  Expression `json:"identifier"`
 	Initializer Expression `json:"initializer"`
+	Span
 }
 
 func (vd *VariableDeclaration) String() string {
@@ -168,6 +199,7 @@ type IfStatement struct {
 	Condition  Expression `json:"condition"`
 	Consequent Statement  `json:"consequent"`
 	Alternate  Statement  `json:"alternate"`
+	Span
 }
 
 func (is *IfStatement) statementNode() {}
@@ -188,6 +220,7 @@ type WhileStatement struct {
 	Type      string     `json:"type"`
 	Condition Expression `json:"condition"`
 	Body      Statement  `json:"body"`
+	Span
 }
 
 func (ws *WhileStatement) statementNode() {}
@@ -207,6 +240,7 @@ type DoWhileStatement struct {
 	Type      string     `json:"type"`
 	Condition Expression `json:"condition"`
 	Body      Statement  `json:"body"`
+	Span
 }
 
 func (dws *DoWhileStatement) statementNode() {}
@@ -228,6 +262,7 @@ type ForStatement struct {
 	Condition   Expression `json:"condition"`
 	Iterator    Expression `json:"iterator"`
 	Body        Statement  `json:"body"`
+	Span
 }
 
 func (fs *ForStatement) statementNode() {}
@@ -248,6 +283,7 @@ type ExpressionStatement struct {
 	// expression_statement ::= expression
 	Type       string     `json:"type"`
 	Expression Expression `json:"expression"`
+	Span
 }
 
 func (es *ExpressionStatement) statementNode() {}
@@ -259,6 +295,22 @@ func NewExpressionStatement(exp Expression) *ExpressionStatement {
 	return &ExpressionStatement{Type: "ExpressionStatement", Expression: exp}
 }
 
+// BadStatement stands for a statement with a syntax error. Its span covers
+// the source the parser skipped to recover from it.
+type BadStatement struct {
+	Type string `json:"type"`
+	Span
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) String() string {
+	return "(BadStatement)"
+}
+
+func NewBadStatement() *BadStatement {
+	return &BadStatement{Type: "BadStatement"}
+}
+
 type AssignmentExpression struct {
 	// assignment_expression ::= logical_or_expression [ assignment_operator assignment_expression ]
 	// assignment_operator   ::= ASSIGN | PLUS_ASSIGN | MINUS_ASSIGN | STAR_ASSIGN | SLASH_ASSIGN
@@ -266,6 +318,7 @@ type AssignmentExpression struct {
 	Operator string     `json:"operator"`
 	Left     Expression `json:"left"`
 	Right    Expression `json:"right"`
+	Span
 }
 
 func (ae *AssignmentExpression) expressionNode() {}
@@ -289,6 +342,7 @@ type LogicalExpression struct {
 	Operator string     `json:"operator"`
 	Left     Expression `json:"left"`
 	Right    Expression `json:"right"`
+	Span
 }
 
 func (le *LogicalExpression) expressionNode() {}
@@ -314,6 +368,7 @@ type BinaryExpression struct {
 	Operator string     `json:"operator"`
 	Left     Expression `json:"left"`
 	Right    Expression `json:"right"`
+	Span
 }
 
 func (be *BinaryExpression) expressionNode() {}
@@ -335,6 +390,7 @@ type UnaryExpression struct {
 	Type     string     `json:"type"`
 	Operator string     `json:"operator"`
 	Right    Expression `json:"right"`
+	Span
 }
 
 func (be *UnaryExpression) expressionNode() {}
@@ -356,6 +412,7 @@ type MemberExpression struct {
 	Computed bool       `json:"computed"`
 	Object   Expression `json:"object"`
 	Property Expression `json:"property"`
+	Span
 }
 
 func (be *MemberExpression) expressionNode() {}
@@ -372,10 +429,158 @@ func NewMemberExpression(computed bool, object, property Expression) *MemberExpr
 	}
 }
 
+type CallExpression struct {
+	// call_expression ::= member_expression { arguments | DOT identifier | LBRACKET expression RBRACKET }
+	// arguments       ::= LPAREN [ assignment_expression { COMMA assignment_expression } [ COMMA ] ] RPAREN
+	Type      string       `json:"type"`
+	Callee    Expression   `json:"callee"`
+	Arguments []Expression `json:"arguments"`
+	Span
+}
+
+func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) String() string {
+	var result strings.Builder
+	result.WriteString("(CallExpression ")
+	result.WriteString(fmt.Sprintf("%v ", ce.Callee))
+	for _, arg := range ce.Arguments {
+		result.WriteString(fmt.Sprintf("%v ", arg))
+	}
+	result.WriteString(")")
+	return strings.TrimSpace(result.String())
+}
+
+func NewCallExpression(callee Expression, arguments []Expression) *CallExpression {
+	return &CallExpression{
+		Type:      "CallExpression",
+		Callee:    callee,
+		Arguments: arguments,
+	}
+}
+
+type SliceExpression struct {
+	// slice ::= LBRACKET [ expression ] COLON [ expression ] RBRACKET
+	Type   string     `json:"type"`
+	Object Expression `json:"object"`
+	Low    Expression `json:"low"`
+	High   Expression `json:"high"`
+	Span
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) String() string {
+	return fmt.Sprintf("(SliceExpression %v %v %v)", se.Object, se.Low, se.High)
+}
+
+func NewSliceExpression(object, low, high Expression) *SliceExpression {
+	return &SliceExpression{
+		Type:   "SliceExpression",
+		Object: object,
+		Low:    low,
+		High:   high,
+	}
+}
+
+type ArrayLiteral struct {
+	// array_literal ::= LBRACKET [ assignment_expression { COMMA assignment_expression } [ COMMA ] ] RBRACKET
+	Type     string       `json:"type"`
+	Elements []Expression `json:"elements"`
+	Span
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) String() string {
+	var result strings.Builder
+	result.WriteString("(ArrayLiteral ")
+	for _, el := range al.Elements {
+		result.WriteString(fmt.Sprintf("%v ", el))
+	}
+	result.WriteString(")")
+	return strings.TrimSpace(result.String())
+}
+
+func NewArrayLiteral(elements []Expression) *ArrayLiteral {
+	return &ArrayLiteral{Type: "ArrayLiteral", Elements: elements}
+}
+
+type MapLiteral struct {
+	// map_literal ::= LBRACE [ map_entry { COMMA map_entry } [ COMMA ] ] RBRACE
+	Type  string     `json:"type"`
+	Pairs []*MapPair `json:"pairs"`
+	Span
+}
+
+func (ml *MapLiteral) expressionNode() {}
+func (ml *MapLiteral) String() string {
+	var result strings.Builder
+	result.WriteString("(MapLiteral ")
+	for _, pair := range ml.Pairs {
+		result.WriteString(fmt.Sprintf("%v ", pair))
+	}
+	result.WriteString(")")
+	return strings.TrimSpace(result.String())
+}
+
+func NewMapLiteral(pairs []*MapPair) *MapLiteral {
+	return &MapLiteral{Type: "MapLiteral", Pairs: pairs}
+}
+
+type MapPair struct {
+	// map_entry ::= ( identifier | string_literal | LBRACKET assignment_expression RBRACKET ) COLON assignment_expression
+	Type     string     `json:"type"`
+	Computed bool       `json:"computed"`
+	Key      Expression `json:"key"`
+	Value    Expression `json:"value"`
+	Span
+}
+
+func (mp *MapPair) String() string {
+	return fmt.Sprintf("(MapPair %t %v %v)", mp.Computed, mp.Key, mp.Value)
+}
+
+func NewMapPair(computed bool, key, value Expression) *MapPair {
+	return &MapPair{
+		Type:     "MapPair",
+		Computed: computed,
+		Key:      key,
+		Value:    value,
+	}
+}
+
+type FunctionLiteral struct {
+	// function_literal ::= FUNCTION LPAREN [ parameters ] RPAREN ( block_statement | return_statement | assignment_expression )
+	Type       string       `json:"type"`
+	Parameters []Identifier `json:"parameters"`
+	Body       Statement    `json:"body"`
+	Span
+}
+
+func (fl *FunctionLiteral) expressionNode() {}
+func (fl *FunctionLiteral) String() string {
+	var result strings.Builder
+	result.WriteString("(FunctionLiteral ")
+	for _, param := range fl.Parameters {
+		result.WriteString(param.String())
+		result.WriteString(" ")
+	}
+	result.WriteString(fl.Body.String())
+	result.WriteString(")")
+	return strings.TrimSpace(result.String())
+}
+
+func NewFunctionLiteral(parameters []Identifier, body Statement) *FunctionLiteral {
+	return &FunctionLiteral{
+		Type:       "FunctionLiteral",
+		Parameters: parameters,
+		Body:       body,
+	}
+}
+
 type IntegerLiteral struct {
 	// integer_literal ::= INT
 	Type  string `json:"type"`
 	Value int64  `json:"value"`
+	Span
 }
 
 func (il *IntegerLiteral) expressionNode() {}
@@ -388,10 +593,58 @@ func NewIntegerLiteral(value int64) *IntegerLiteral {
 	return &IntegerLiteral{Type: "IntegerLiteral", Value: value}
 }
 
+// BigIntegerLiteral is an integer literal too large for an int64. Its
+// value is encoded in JSON as a string of its digits, like the value of a
+// DecimalLiteral, since JSON numbers are commonly decoded as floats.
+type BigIntegerLiteral struct {
+	// integer_literal ::= INT
+	Type  string   `json:"type"`
+	Value *big.Int `json:"value"`
+	Span
+}
+
+func (bl *BigIntegerLiteral) expressionNode() {}
+func (bl *BigIntegerLiteral) String() string {
+	return fmt.Sprintf("(BigIntegerLiteral %s)", bl.Value)
+}
+
+func NewBigIntegerLiteral(value *big.Int) *BigIntegerLiteral {
+	return &BigIntegerLiteral{Type: "BigIntegerLiteral", Value: value}
+}
+
+// bigIntegerJSON is the JSON form of a BigIntegerLiteral, whose value
+// replaces the one of the literal.
+type bigIntegerJSON struct {
+	*bigIntegerLiteral
+	Value string `json:"value"`
+}
+
+type bigIntegerLiteral BigIntegerLiteral
+
+func (bl *BigIntegerLiteral) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bigIntegerJSON{(*bigIntegerLiteral)(bl), bl.Value.String()})
+}
+
+func (bl *BigIntegerLiteral) UnmarshalJSON(data []byte) error {
+	decoded := bigIntegerJSON{bigIntegerLiteral: (*bigIntegerLiteral)(bl)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	value, ok := new(big.Int).SetString(decoded.Value, 10)
+	if !ok {
+		return fmt.Errorf("big integer must be a JSON string of digits, got %q", decoded.Value)
+	}
+	bl.Value = value
+
+	return nil
+}
+
 type FloatLiteral struct {
 	// float_literal ::= FLOAT
 	Type  string  `json:"type"`
 	Value float64 `json:"value"`
+	Span
 }
 
 func (fl *FloatLiteral) expressionNode() {}
@@ -403,10 +656,29 @@ func NewFloatLiteral(value float64) *FloatLiteral {
 	return &FloatLiteral{Type: "FloatLiteral", Value: value}
 }
 
+// DecimalLiteral is an exact decimal such as 12.30d. Its value is encoded
+// in JSON as a string, which keeps its digits and its scale.
+type DecimalLiteral struct {
+	// decimal_literal ::= DECIMAL
+	Type  string          `json:"type"`
+	Value decimal.Decimal `json:"value"`
+	Span
+}
+
+func (dl *DecimalLiteral) expressionNode() {}
+func (dl *DecimalLiteral) String() string {
+	return fmt.Sprintf("(DecimalLiteral %s)", dl.Value)
+}
+
+func NewDecimalLiteral(value decimal.Decimal) *DecimalLiteral {
+	return &DecimalLiteral{Type: "DecimalLiteral", Value: value}
+}
+
 type StringLiteral struct {
 	// string_literal ::= STRING
 	Type  string `json:"type"`
 	Value string `json:"value"`
+	Span
 }
 
 func (sl *StringLiteral) expressionNode() {}
@@ -418,10 +690,33 @@ func NewStringLiteral(value string) *StringLiteral {
 	return &StringLiteral{Type: "StringLiteral", Value: value}
 }
 
+type InterpolatedString struct {
+	// interpolated_string ::= INTERP_START expression { INTERP_MID expression } INTERP_END
+	Type  string       `json:"type"`
+	Parts []Expression `json:"parts"`
+	Span
+}
+
+func (is *InterpolatedString) expressionNode() {}
+func (is *InterpolatedString) String() string {
+	var result strings.Builder
+	result.WriteString("(InterpolatedString ")
+	for _, part := range is.Parts {
+		result.WriteString(fmt.Sprintf("%v ", part))
+	}
+	result.WriteString(")")
+	return strings.TrimSpace(result.String())
+}
+
+func NewInterpolatedString(parts []Expression) *InterpolatedString {
+	return &InterpolatedString{Type: "InterpolatedString", Parts: parts}
+}
+
 type BoolLiteral struct {
 	// bool_literal ::= (TRUE | FALSE)
 	Type  string `json:"type"`
 	Value bool   `json:"value"`
+	Span
 }
 
 func (bl *BoolLiteral) expressionNode() {}
@@ -437,6 +732,7 @@ type NullLiteral struct {
 	// null_literal ::= NULL
 	Type  string      `json:"type"`
 	Value interface{} `json:"value"`
+	Span
 }
 
 func (nl *NullLiteral) expressionNode() {}
@@ -452,6 +748,7 @@ type Identifier struct {
 	// identifier ::= IDENT
 	Type string `json:"type"`
 	Name string `json:"name"`
+	Span
 }
 
 func (i *Identifier) expressionNode() {}
@@ -462,3 +759,19 @@ func (i *Identifier) String() string {
 func NewIdentifier(name string) *Identifier {
 	return &Identifier{Type: "Identifier", Name: name}
 }
+
+// BadExpression stands for an expression with a syntax error, such as a
+// token that cannot start an expression.
+type BadExpression struct {
+	Type string `json:"type"`
+	Span
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) String() string {
+	return "(BadExpression)"
+}
+
+func NewBadExpression() *BadExpression {
+	return &BadExpression{Type: "BadExpression"}
+}
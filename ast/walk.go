@@ -0,0 +1,88 @@
+package ast
+
+// Children returns the nodes directly under node, in the order they appear
+// in the source. The parts left out of the source, such as a missing else
+// branch, are skipped.
+func Children(node Node) []Node {
+	var children []Node
+	add := func(nodes ...Node) {
+		for _, n := range nodes {
+			if n != nil {
+				children = append(children, n)
+			}
+		}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			add(stmt)
+		}
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			add(stmt)
+		}
+	case *FunctionDeclaration:
+		add(&n.Name)
+		for i := range n.Parameters {
+			add(&n.Parameters[i])
+		}
+		add(n.Body)
+	case *ReturnStatement:
+		add(n.Value)
+	case *VariableStatement:
+		for _, dcl := range n.Declarations {
+			add(dcl)
+		}
+	case *VariableDeclaration:
+		add(n.Identifier, n.Initializer)
+	case *IfStatement:
+		add(n.Condition, n.Consequent, n.Alternate)
+	case *WhileStatement:
+		add(n.Condition, n.Body)
+	case *DoWhileStatement:
+		add(n.Body, n.Condition)
+	case *ForStatement:
+		add(n.Initializer, n.Condition, n.Iterator, n.Body)
+	case *ExpressionStatement:
+		add(n.Expression)
+	case *AssignmentExpression:
+		add(n.Left, n.Right)
+	case *LogicalExpression:
+		add(n.Left, n.Right)
+	case *BinaryExpression:
+		add(n.Left, n.Right)
+	case *UnaryExpression:
+		add(n.Right)
+	case *MemberExpression:
+		add(n.Object, n.Property)
+	case *CallExpression:
+		add(n.Callee)
+		for _, arg := range n.Arguments {
+			add(arg)
+		}
+	case *SliceExpression:
+		add(n.Object, n.Low, n.High)
+	case *ArrayLiteral:
+		for _, elem := range n.Elements {
+			add(elem)
+		}
+	case *MapLiteral:
+		for _, pair := range n.Pairs {
+			add(pair)
+		}
+	case *MapPair:
+		add(n.Key, n.Value)
+	case *FunctionLiteral:
+		for i := range n.Parameters {
+			add(&n.Parameters[i])
+		}
+		add(n.Body)
+	case *InterpolatedString:
+		for _, part := range n.Parts {
+			add(part)
+		}
+	}
+
+	return children
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/jellycat-io/eevee/config"
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/source"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Reports the syntax errors of files at given paths",
+	Long: `This command parses files without executing them and reports their errors.
+A path of - reads a program from the standard input.
+With --diagnostics-format=json or sarif it always writes a report, even an empty one,
+so that CI can upload it. It exits with status 1 if any file has an error.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := diagnosticsFormat(cmd)
+		cfg := config.GetConfig()
+		fset := source.NewFileSet(cfg.TabSize)
+
+		var diags diagnostics.List
+		for _, path := range args {
+			_, fileDiags := parseFile(fset, path, lexerMode(cfg))
+			diags = append(diags, fileDiags...)
+		}
+
+		if len(diags) != 0 || format != "text" {
+			writeDiagnostics(format, diags, fset)
+		}
+		if diags.HasErrors() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	addDiagnosticsFormatFlag(checkCmd)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jellycat-io/eevee/compiler"
+	"github.com/spf13/cobra"
+)
+
+// disasmCmd represents the disasm command
+var disasmCmd = &cobra.Command{
+	Use:   "disasm",
+	Short: "Prints the bytecode of file at given path",
+	Long:  `This command compiles a file and prints its bytecode with the source line of every instruction`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		program := loadProgram(cmd, args[0])
+
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			log.Error(fmt.Sprintf("compilation failed: %s", err))
+			os.Exit(1)
+		}
+
+		compiler.Disassemble(os.Stdout, c.Bytecode())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(disasmCmd)
+	addDiagnosticsFormatFlag(disasmCmd)
+}
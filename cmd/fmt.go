@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/eevee/config"
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/format"
+	"github.com/jellycat-io/eevee/source"
+	"github.com/spf13/cobra"
+)
+
+// fmtCmd represents the fmt command
+var fmtCmd = &cobra.Command{
+	Use:   "fmt",
+	Short: "Formats files at given paths in the canonical style",
+	Long: `This command parses files and prints them back in the canonical style,
+keeping their comments. A path of - reads a program from the standard input.
+The logical operators are spelled as set by logical_operators in eevee.toml.
+With --check it lists the files that are not formatted and exits with status 1
+if there is any, with --diff it prints the changes it would make, and with -w
+it rewrites the files, which it refuses for the standard input. It exits with
+status 1 if any file has a syntax error.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		write, _ := cmd.Flags().GetBool("write")
+		check, _ := cmd.Flags().GetBool("check")
+		diff, _ := cmd.Flags().GetBool("diff")
+
+		for _, path := range args {
+			if write && path == "-" {
+				log.Error(color.InRed("Cannot use -w with the standard input"))
+				os.Exit(1)
+			}
+		}
+
+		cfg := config.GetConfig()
+		fset := source.NewFileSet(cfg.TabSize)
+		style := format.Style{WordOperators: cfg.LogicalOperators == "words"}
+
+		var diags diagnostics.List
+		unformatted := false
+		for _, path := range args {
+			file, text := readSource(fset, path)
+			formatted, fileDiags := format.Source(file, lexerMode(cfg), style)
+			diags = append(diags, fileDiags...)
+			if fileDiags.HasErrors() {
+				continue
+			}
+
+			changed := formatted != text
+			if check && changed {
+				fmt.Println(path)
+				unformatted = true
+			}
+			if diff {
+				fmt.Print(format.Diff(path, text, formatted))
+			}
+			if write && changed {
+				writeSource(path, formatted)
+			}
+			if !check && !diff && !write {
+				fmt.Print(formatted)
+			}
+		}
+
+		if len(diags) != 0 {
+			writeDiagnostics("text", diags, fset)
+		}
+		if diags.HasErrors() || unformatted {
+			os.Exit(1)
+		}
+	},
+}
+
+// readSource registers the file at path in fset, or the standard input for
+// a path of -, and returns it with its text as read.
+func readSource(fset *source.FileSet, path string) (*source.File, string) {
+	var buf []byte
+	var err error
+	filename := path
+	if path == "-" {
+		filename = "<stdin>"
+		buf, err = io.ReadAll(os.Stdin)
+	} else {
+		buf, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		log.Error(fmt.Sprintf(color.InRed("Cannot read file: %q"), path))
+		os.Exit(1)
+	}
+
+	return fset.AddFile(filename, buf), string(buf)
+}
+
+// writeSource replaces the file at path with text, keeping its permissions.
+func writeSource(path, text string) {
+	info, err := os.Stat(path)
+	if err == nil {
+		err = os.WriteFile(path, []byte(text), info.Mode().Perm())
+	}
+
+	if err != nil {
+		log.Error(fmt.Sprintf(color.InRed("Cannot write file: %q"), path))
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+	fmtCmd.Flags().BoolP("write", "w", false, "Write the formatted source back to the files")
+	fmtCmd.Flags().Bool("check", false, "List the files that are not formatted and exit with status 1 if there is any")
+	fmtCmd.Flags().Bool("diff", false, "Print the changes that formatting would make as a unified diff")
+}
@@ -30,7 +30,19 @@ func Execute() {
 	}
 }
 
+// isTerminal reports whether f is a terminal rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func init() {
+	// Colors are only written to terminals, and never when NO_COLOR is set
+	// (https://no-color.org).
+	cobra.OnInitialize(func() {
+		color.Toggle(os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout))
+	})
+
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
@@ -4,13 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/compiler"
 	"github.com/jellycat-io/eevee/config"
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/evaluator"
 	"github.com/jellycat-io/eevee/lexer"
 	"github.com/jellycat-io/eevee/logger"
+	"github.com/jellycat-io/eevee/object"
 	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/source"
+	"github.com/jellycat-io/eevee/vm"
 	"github.com/spf13/cobra"
 )
 
@@ -20,43 +26,152 @@ var log = logger.New()
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Executes file at given path",
-	Long:  `This command takes a filepath as argument`,
+	Long:  `This command takes a filepath as argument, or - to read the program from the standard input`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		config := config.GetConfig()
+		program := loadProgram(cmd, args[0])
 
-		filepath := args[0]
-		if _, err := os.Stat(filepath); err != nil {
-			log.Error(fmt.Sprintf(color.InRed("Invalid filepath. got=%q"), filepath))
-			os.Exit(1)
-		}
+		if printAST, _ := cmd.Flags().GetBool("ast"); printAST {
+			json, err := json.MarshalIndent(program, "", "    ")
+			if err != nil {
+				log.Error(err.Error())
+			}
 
-		buf, err := os.ReadFile(filepath)
-		if err != nil {
-			log.Error(fmt.Sprintf(color.InRed("Cannot read file: %q"), filepath))
+			fmt.Printf("%s\n", json)
+			return
 		}
-		source := strings.TrimSpace(string(buf))
 
-		fmt.Println(source)
+		var result object.Object
+		engine, _ := cmd.Flags().GetString("engine")
+		switch engine {
+		case "eval":
+			result = evaluator.Eval(program, object.NewEnvironment())
+			if object.IsError(result) {
+				log.Error(result.Inspect())
+				os.Exit(1)
+			}
+		case "vm":
+			c := compiler.New()
+			if err := c.Compile(program); err != nil {
+				log.Error(fmt.Sprintf("compilation failed: %s", err))
+				os.Exit(1)
+			}
 
-		l := lexer.New(source, config.TabSize)
-		for _, t := range l.Tokens {
-			fmt.Println(t)
+			machine := vm.New(c.Bytecode())
+			if err := machine.Run(); err != nil {
+				log.Error(fmt.Sprintf("error: %s", err))
+				os.Exit(1)
+			}
+			result = machine.Result()
+		default:
+			log.Error(fmt.Sprintf("Unknown engine %q, expected \"eval\" or \"vm\"", engine))
+			os.Exit(1)
 		}
 
-		p := parser.New(l.Tokens, false)
-		ast := p.Parse()
-		json, err := json.MarshalIndent(ast, "", "    ")
-		if err != nil {
-			log.Error(err.Error())
+		if result != object.NULL {
+			fmt.Println(result.Inspect())
 		}
+	},
+}
+
+// loadProgram parses the file at filepath for cmd. It writes the
+// diagnostics in the format chosen with --diagnostics-format, and exits
+// with status 1 if there are errors.
+func loadProgram(cmd *cobra.Command, filepath string) *ast.Program {
+	format := diagnosticsFormat(cmd)
+	cfg := config.GetConfig()
+	fset := source.NewFileSet(cfg.TabSize)
+
+	program, diags := parseFile(fset, filepath, lexerMode(cfg))
+	if len(diags) != 0 {
+		writeDiagnostics(format, diags, fset)
+	}
+	if diags.HasErrors() {
+		os.Exit(1)
+	}
+
+	return program
+}
+
+// parseFile reads the file at filepath into fset and parses it, reading
+// its tokens with mode. A filepath of "-" streams the standard input to
+// the parser instead, so its lines are not kept in fset. It returns the
+// diagnostics of the lexer and of the parser, and a nil program if there
+// are errors.
+func parseFile(fset *source.FileSet, filepath string, mode lexer.Mode) (*ast.Program, diagnostics.List) {
+	if filepath == "-" {
+		return parseTokens(lexer.NewReader("<stdin>", os.Stdin, fset.TabSize(), mode))
+	}
+
+	if _, err := os.Stat(filepath); err != nil {
+		log.Error(fmt.Sprintf(color.InRed("Invalid filepath. got=%q"), filepath))
+		os.Exit(1)
+	}
+
+	buf, err := os.ReadFile(filepath)
+	if err != nil {
+		log.Error(fmt.Sprintf(color.InRed("Cannot read file: %q"), filepath))
+		os.Exit(1)
+	}
+
+	return parseTokens(lexer.NewWithMode(fset.AddFile(filepath, buf), mode))
+}
 
-		fmt.Printf("%s\n", json)
+// parseTokens parses the tokens of l. The errors of the parser on the lines
+// that errors of the lexer reach follow from them and are left out.
+func parseTokens(l *lexer.Lexer) (*ast.Program, diagnostics.List) {
+	p := parser.New(l, false)
+	program := p.Parse()
 
-		if len(p.Errors()) != 0 {
-			log.PrintParserErrors(p.Errors())
+	if err := l.Err(); err != nil {
+		log.Error(fmt.Sprintf(color.InRed("Cannot read %s: %s"), l.Filename(), err))
+		os.Exit(1)
+	}
+
+	diags := diagnostics.Combine(l.Diagnostics(), p.Diagnostics())
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return program, diags
+}
+
+// lexerMode returns the lexer flags set in cfg.
+func lexerMode(cfg config.Config) lexer.Mode {
+	var mode lexer.Mode
+	if cfg.StrictIndentation {
+		mode |= lexer.StrictIndentation
+	}
+
+	return mode
+}
+
+// addDiagnosticsFormatFlag adds the --diagnostics-format flag to a command
+// that checks source files.
+func addDiagnosticsFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().String("diagnostics-format", "text", fmt.Sprintf("Format of the errors: one of %q", diagnostics.Formats))
+}
+
+// diagnosticsFormat returns the format chosen with --diagnostics-format,
+// and exits if it is not known.
+func diagnosticsFormat(cmd *cobra.Command) string {
+	format, _ := cmd.Flags().GetString("diagnostics-format")
+	for _, known := range diagnostics.Formats {
+		if format == known {
+			return format
 		}
-	},
+	}
+
+	log.Error(fmt.Sprintf("Unknown diagnostics format %q, expected one of %q", format, diagnostics.Formats))
+	os.Exit(1)
+	return ""
+}
+
+func writeDiagnostics(format string, diags diagnostics.List, fset *source.FileSet) {
+	if err := diagnostics.Write(os.Stdout, format, diags, fset); err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
 }
 
 func init() {
@@ -70,5 +185,7 @@ func init() {
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
-	// runCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	runCmd.Flags().Bool("ast", false, "Print the JSON syntax tree instead of executing the file")
+	runCmd.Flags().String("engine", "eval", "Execution engine: \"eval\" walks the syntax tree, \"vm\" runs compiled bytecode")
+	addDiagnosticsFormatFlag(runCmd)
 }
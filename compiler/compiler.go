@@ -0,0 +1,736 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/code"
+	"github.com/jellycat-io/eevee/object"
+)
+
+// Frames address their locals and calls count their arguments with a
+// single byte operand.
+const (
+	maxLocals    = 256
+	maxArguments = 255
+)
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Lines        []code.LineInfo
+	Constants    []object.Object
+}
+
+type CompilationScope struct {
+	instructions code.Instructions
+	lines        []code.LineInfo
+}
+
+type Compiler struct {
+	constants     []object.Object
+	constantIndex map[interface{}]int
+	symbolTable   *SymbolTable
+	scopes        []CompilationScope
+	scopeIndex    int
+	line          int // line of the statement being compiled
+}
+
+func New() *Compiler {
+	return &Compiler{
+		constants:     []object.Object{},
+		constantIndex: make(map[interface{}]int),
+		symbolTable:   NewSymbolTable(),
+		scopes:        []CompilationScope{{}},
+	}
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentScope().instructions,
+		Lines:        c.currentScope().lines,
+		Constants:    c.constants,
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if _, ok := node.(ast.Statement); ok && node.Pos().Line > 0 {
+		c.line = node.Pos().Line
+	}
+
+	switch node := node.(type) {
+	// Statements
+	case *ast.Program:
+		return c.compileStatements(node.Statements)
+	case *ast.BlockStatement:
+		return c.compileBlockStatement(node)
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.VariableStatement:
+		return c.compileVariableStatement(node)
+	case *ast.IfStatement:
+		return c.compileIfStatement(node)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.DoWhileStatement:
+		return c.compileDoWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.FunctionDeclaration:
+		return c.compileFunctionDeclaration(node)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	// Expressions
+	case *ast.AssignmentExpression:
+		return c.compileAssignmentExpression(node)
+	case *ast.LogicalExpression:
+		return c.compileLogicalExpression(node)
+	case *ast.BinaryExpression:
+		return c.compileBinaryExpression(node)
+	case *ast.UnaryExpression:
+		return c.compileUnaryExpression(node)
+	case *ast.MemberExpression:
+		return c.compileMemberExpression(node)
+	case *ast.SliceExpression:
+		return c.compileSliceExpression(node)
+	case *ast.CallExpression:
+		return c.compileCallExpression(node)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Name)
+		if !ok && c.symbolTable.Hoisted(node.Name) {
+			// The function is declared further down, so this runs before
+			// its declaration and fails as in the evaluator.
+			c.emit(code.OpConstant, c.addConstant(nil, object.NewError("identifier not found: %s", node.Name)))
+			return nil
+		}
+		if !ok {
+			return fmt.Errorf("line %d: identifier not found: %s", c.line, node.Name)
+		}
+		c.loadSymbol(symbol)
+
+	// Literals
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(node.Value, &object.Integer{Value: node.Value}))
+	case *ast.BigIntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(nil, &object.BigInteger{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(node.Value, &object.Float{Value: node.Value}))
+	case *ast.DecimalLiteral:
+		c.emit(code.OpConstant, c.addConstant(nil, &object.Decimal{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(node.Value, &object.String{Value: node.Value}))
+	case *ast.BoolLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+	case *ast.ArrayLiteral:
+		for _, e := range node.Elements {
+			if err := c.Compile(e); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.FunctionLiteral:
+		return c.compileFunction("", node.Parameters, node.Body)
+	case *ast.MapLiteral:
+		return c.compileMapLiteral(node)
+
+	default:
+		return fmt.Errorf("line %d: cannot compile %T", c.line, node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	// Function declarations are hoisted so that functions declared later
+	// in the same block can already be referenced by earlier bodies.
+	for _, stmt := range stmts {
+		if fd, ok := stmt.(*ast.FunctionDeclaration); ok {
+			c.symbolTable.Hoist(fd.Name.Name)
+		}
+	}
+
+	for _, stmt := range stmts {
+		if err := c.Compile(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileBlockStatement(bs *ast.BlockStatement) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	start := c.symbolTable.NumDefinitions()
+	if err := c.compileStatements(bs.Statements); err != nil {
+		return err
+	}
+	c.closeCells(start)
+
+	return nil
+}
+
+// closeCells closes the cells of the variables from the slot start that
+// closures captured, at the end of the block declaring them. Each run of
+// the block then has variables of its own, as in the evaluator.
+func (c *Compiler) closeCells(start int) {
+	switch {
+	case !c.symbolTable.Captured(start):
+	case c.scopeIndex == 0:
+		c.emit(code.OpCloseGlobals, start)
+	default:
+		c.emit(code.OpCloseCells, start)
+	}
+}
+
+func (c *Compiler) compileVariableStatement(vs *ast.VariableStatement) error {
+	for _, decl := range vs.Declarations {
+		ident, ok := decl.Identifier.(*ast.Identifier)
+		if !ok {
+			return fmt.Errorf("line %d: invalid variable name: %v", c.line, decl.Identifier)
+		}
+
+		// A function is bound before its body is compiled, so that it can
+		// call itself like a function declaration. Other initializers still
+		// see the variable they shadow.
+		var symbol Symbol
+		_, isFunction := decl.Initializer.(*ast.FunctionLiteral)
+		if isFunction {
+			symbol = c.symbolTable.Define(ident.Name)
+		}
+
+		if err := c.Compile(decl.Initializer); err != nil {
+			return err
+		}
+
+		if !isFunction {
+			symbol = c.symbolTable.Define(ident.Name)
+		}
+		c.storeSymbol(symbol)
+		c.emit(code.OpPop)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfStatement(is *ast.IfStatement) error {
+	if err := c.Compile(is.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(is.Consequent); err != nil {
+		return err
+	}
+
+	if is.Alternate == nil {
+		c.patchJump(jumpNotTruthy)
+		return nil
+	}
+
+	jump := c.emit(code.OpJump, 9999)
+	c.patchJump(jumpNotTruthy)
+
+	if err := c.Compile(is.Alternate); err != nil {
+		return err
+	}
+	c.patchJump(jump)
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(ws *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(ws.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(ws.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	c.patchJump(exit)
+
+	return nil
+}
+
+func (c *Compiler) compileDoWhileStatement(dws *ast.DoWhileStatement) error {
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(dws.Body); err != nil {
+		return err
+	}
+
+	if err := c.Compile(dws.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+	c.emit(code.OpJump, start)
+	c.patchJump(exit)
+
+	return nil
+}
+
+func (c *Compiler) compileForStatement(fs *ast.ForStatement) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	start := c.symbolTable.NumDefinitions()
+	switch init := fs.Initializer.(type) {
+	case nil:
+	case ast.Statement:
+		if err := c.Compile(init); err != nil {
+			return err
+		}
+	default:
+		if err := c.Compile(init); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+
+	loop := len(c.currentInstructions())
+	exit := -1
+
+	if fs.Condition != nil {
+		if err := c.Compile(fs.Condition); err != nil {
+			return err
+		}
+		exit = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	if err := c.Compile(fs.Body); err != nil {
+		return err
+	}
+
+	if fs.Iterator != nil {
+		if err := c.Compile(fs.Iterator); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+
+	c.emit(code.OpJump, loop)
+	if exit != -1 {
+		c.patchJump(exit)
+	}
+	c.closeCells(start)
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionDeclaration(fd *ast.FunctionDeclaration) error {
+	symbol, ok := c.symbolTable.Declare(fd.Name.Name)
+	if !ok {
+		symbol = c.symbolTable.Define(fd.Name.Name)
+	}
+
+	if err := c.compileFunction(fd.Name.Name, fd.Parameters, fd.Body); err != nil {
+		return err
+	}
+
+	c.storeSymbol(symbol)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+// compileFunction emits an OpClosure for a function body. A body made of a
+// single expression returns the value of that expression. A function
+// refers to itself through the variable it is stored in, which it captures
+// like any other.
+func (c *Compiler) compileFunction(name string, params []ast.Identifier, body ast.Statement) error {
+	line := c.line
+	c.enterScope()
+
+	if name == "" {
+		name = object.AnonymousName
+	}
+	for _, param := range params {
+		c.symbolTable.Define(param.Name)
+	}
+
+	if es, ok := body.(*ast.ExpressionStatement); ok {
+		if err := c.Compile(es.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	} else {
+		if err := c.Compile(body); err != nil {
+			return err
+		}
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	if numLocals > maxLocals {
+		return fmt.Errorf("line %d: too many local variables in function %s", c.line, name)
+	}
+	scope := c.leaveScope()
+	c.line = line
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+
+	fn := &object.CompiledFunction{
+		Name:          name,
+		Instructions:  scope.instructions,
+		Lines:         scope.lines,
+		NumLocals:     numLocals,
+		NumParameters: len(params),
+	}
+	c.emit(code.OpClosure, c.addConstant(nil, fn), len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) compileAssignmentExpression(ae *ast.AssignmentExpression) error {
+	switch left := ae.Left.(type) {
+	case *ast.Identifier:
+		return c.compileIdentifierAssignment(left, ae)
+	case *ast.MemberExpression:
+		return c.compileMemberAssignment(left, ae)
+	default:
+		return fmt.Errorf("line %d: cannot assign to %v", c.line, ae.Left)
+	}
+}
+
+func (c *Compiler) compileIdentifierAssignment(ident *ast.Identifier, ae *ast.AssignmentExpression) error {
+	symbol, ok := c.symbolTable.Resolve(ident.Name)
+	if !ok {
+		return fmt.Errorf("line %d: identifier not found: %s", c.line, ident.Name)
+	}
+
+	if ae.Operator != "=" {
+		c.loadSymbol(symbol)
+	}
+
+	if err := c.compileAssignedValue(ae); err != nil {
+		return err
+	}
+
+	c.storeSymbol(symbol)
+
+	return nil
+}
+
+// compileMemberAssignment evaluates the object and the index of the target
+// once. Compound assignments duplicate them to read the current value.
+func (c *Compiler) compileMemberAssignment(me *ast.MemberExpression, ae *ast.AssignmentExpression) error {
+	if err := c.Compile(me.Object); err != nil {
+		return err
+	}
+
+	if !me.Computed {
+		prop, ok := me.Property.(*ast.Identifier)
+		if !ok {
+			return fmt.Errorf("line %d: invalid property: %v", c.line, me.Property)
+		}
+		name := c.addConstant(prop.Name, &object.String{Value: prop.Name})
+
+		if ae.Operator != "=" {
+			c.emit(code.OpDup, 1)
+			c.emit(code.OpProperty, name)
+		}
+		if err := c.compileAssignedValue(ae); err != nil {
+			return err
+		}
+		c.emit(code.OpSetProperty, name)
+
+		return nil
+	}
+
+	if err := c.Compile(me.Property); err != nil {
+		return err
+	}
+
+	if ae.Operator != "=" {
+		c.emit(code.OpDup, 2)
+		c.emit(code.OpIndex)
+	}
+	if err := c.compileAssignedValue(ae); err != nil {
+		return err
+	}
+	c.emit(code.OpSetIndex)
+
+	return nil
+}
+
+// compileAssignedValue compiles the right-hand side of ae. For compound
+// operators the current value of the target must already be on the stack.
+func (c *Compiler) compileAssignedValue(ae *ast.AssignmentExpression) error {
+	if err := c.Compile(ae.Right); err != nil {
+		return err
+	}
+
+	if ae.Operator != "=" {
+		op, ok := code.BinaryOps[ae.Operator[:len(ae.Operator)-1]]
+		if !ok {
+			return fmt.Errorf("line %d: unknown operator: %s", c.line, ae.Operator)
+		}
+		c.emit(op)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileLogicalExpression(le *ast.LogicalExpression) error {
+	if err := c.Compile(le.Left); err != nil {
+		return err
+	}
+
+	var jump int
+	switch le.Operator {
+	case "&&":
+		jump = c.emit(code.OpJumpFalsyKeep, 9999)
+	case "||":
+		jump = c.emit(code.OpJumpTruthyKeep, 9999)
+	default:
+		return fmt.Errorf("line %d: unknown operator: %s", c.line, le.Operator)
+	}
+
+	if err := c.Compile(le.Right); err != nil {
+		return err
+	}
+	c.patchJump(jump)
+
+	return nil
+}
+
+func (c *Compiler) compileBinaryExpression(be *ast.BinaryExpression) error {
+	op, ok := code.BinaryOps[be.Operator]
+	if !ok {
+		return fmt.Errorf("line %d: unknown operator: %s", c.line, be.Operator)
+	}
+
+	if err := c.Compile(be.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(be.Right); err != nil {
+		return err
+	}
+	c.emit(op)
+
+	return nil
+}
+
+func (c *Compiler) compileUnaryExpression(ue *ast.UnaryExpression) error {
+	op, ok := code.UnaryOps[ue.Operator]
+	if !ok {
+		return fmt.Errorf("line %d: unknown operator: %s", c.line, ue.Operator)
+	}
+
+	if err := c.Compile(ue.Right); err != nil {
+		return err
+	}
+	c.emit(op)
+
+	return nil
+}
+
+func (c *Compiler) compileMemberExpression(me *ast.MemberExpression) error {
+	if err := c.Compile(me.Object); err != nil {
+		return err
+	}
+
+	if !me.Computed {
+		prop, ok := me.Property.(*ast.Identifier)
+		if !ok {
+			return fmt.Errorf("line %d: invalid property: %v", c.line, me.Property)
+		}
+		c.emit(code.OpProperty, c.addConstant(prop.Name, &object.String{Value: prop.Name}))
+		return nil
+	}
+
+	if err := c.Compile(me.Property); err != nil {
+		return err
+	}
+	c.emit(code.OpIndex)
+
+	return nil
+}
+
+func (c *Compiler) compileSliceExpression(se *ast.SliceExpression) error {
+	if err := c.Compile(se.Object); err != nil {
+		return err
+	}
+
+	for _, bound := range []ast.Expression{se.Low, se.High} {
+		if bound == nil {
+			c.emit(code.OpNull)
+			continue
+		}
+		if err := c.Compile(bound); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpSlice)
+
+	return nil
+}
+
+func (c *Compiler) compileCallExpression(ce *ast.CallExpression) error {
+	if len(ce.Arguments) > maxArguments {
+		return fmt.Errorf("line %d: too many arguments in call to %v", c.line, ce.Callee)
+	}
+
+	if err := c.Compile(ce.Callee); err != nil {
+		return err
+	}
+
+	for _, arg := range ce.Arguments {
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpCall, len(ce.Arguments))
+
+	return nil
+}
+
+// compileMapLiteral pushes the keys and values of the pairs in order,
+// followed by OpMap with the number of pairs.
+func (c *Compiler) compileMapLiteral(ml *ast.MapLiteral) error {
+	for _, pair := range ml.Pairs {
+		if ident, ok := pair.Key.(*ast.Identifier); ok && !pair.Computed {
+			c.emit(code.OpConstant, c.addConstant(ident.Name, &object.String{Value: ident.Name}))
+		} else if err := c.Compile(pair.Key); err != nil {
+			return err
+		}
+
+		if err := c.Compile(pair.Value); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpMap, len(ml.Pairs))
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope, BlockScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+// storeSymbol emits the store for s. Stores leave the value on the stack.
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope, BlockScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// captureSymbol pushes the cell of the variable s for a closure being
+// created. The globals of the top level are never captured, since
+// closures read them directly.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.symbolTable.Capture(s)
+		c.emit(code.OpCaptureLocal, s.Index)
+	case BlockScope:
+		c.symbolTable.Capture(s)
+		c.emit(code.OpCaptureGlobal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	}
+}
+
+// addConstant appends obj to the constant pool. Constants with a non-nil
+// key are deduplicated.
+func (c *Compiler) addConstant(key interface{}, obj object.Object) int {
+	if key != nil {
+		if idx, ok := c.constantIndex[key]; ok {
+			return idx
+		}
+	}
+
+	c.constants = append(c.constants, obj)
+	idx := len(c.constants) - 1
+
+	if key != nil {
+		c.constantIndex[key] = idx
+	}
+
+	return idx
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	scope := &c.scopes[c.scopeIndex]
+
+	pos := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+
+	if n := len(scope.lines); n == 0 || scope.lines[n-1].Line != c.line {
+		scope.lines = append(scope.lines, code.LineInfo{Offset: pos, Line: c.line})
+	}
+
+	return pos
+}
+
+// patchJump points the jump emitted at pos to the next instruction.
+func (c *Compiler) patchJump(pos int) {
+	op := code.Opcode(c.currentInstructions()[pos])
+	ins := code.Make(op, len(c.currentInstructions()))
+	copy(c.scopes[c.scopeIndex].instructions[pos:], ins)
+}
+
+func (c *Compiler) currentScope() CompilationScope {
+	return c.scopes[c.scopeIndex]
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() CompilationScope {
+	scope := c.currentScope()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope
+}
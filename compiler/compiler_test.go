@@ -0,0 +1,124 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jellycat-io/eevee/code"
+	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/test"
+)
+
+func TestCompileWhileStatement(t *testing.T) {
+	input := test.MakeInput(
+		`let x = 0`,
+		`while x < 10 do`,
+		`	x += 1`,
+	)
+
+	expected := concatInstructions(
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpPop),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpConstant, 1),
+		code.Make(code.OpLess),
+		code.Make(code.OpJumpNotTruthy, 31),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpConstant, 2),
+		code.Make(code.OpAdd),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpPop),
+		code.Make(code.OpJump, 7),
+	)
+
+	bytecode := compile(t, input)
+	if bytecode.Instructions.String() != expected.String() {
+		t.Fatalf("Wrong instructions.\nExpected:\n%s\ngot:\n%s", expected, bytecode.Instructions)
+	}
+}
+
+func TestCompileClosure(t *testing.T) {
+	input := test.MakeInput(
+		`fn outer(a)`,
+		`	fn inner() return a`,
+		`	return inner`,
+	)
+
+	bytecode := compile(t, input)
+
+	var out strings.Builder
+	Disassemble(&out, bytecode)
+
+	expected := strings.Join([]string{
+		`== main ==`,
+		`0000    1 OpClosure 1 0 (compiled fn outer)`,
+		`0004    | OpSetGlobal 0`,
+		`0007    | OpPop`,
+		``,
+		`== fn inner ==`,
+		`0000    2 OpGetFree 0`,
+		`0002    | OpReturnValue`,
+		`0003    | OpReturn`,
+		``,
+		`== fn outer ==`,
+		`0000    2 OpCaptureLocal 0`,
+		`0002    | OpClosure 0 1 (compiled fn inner)`,
+		`0006    | OpSetLocal 1`,
+		`0008    | OpPop`,
+		`0009    3 OpGetLocal 1`,
+		`0011    | OpReturnValue`,
+		`0012    | OpReturn`,
+		``,
+	}, "\n")
+
+	if out.String() != expected {
+		t.Fatalf("Wrong disassembly.\nExpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{test.MakeInput(`let x = 1`, `y`), "line 2: identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(test.MakeFile(tt.input))
+		p := parser.New(l, false)
+		program := p.Parse()
+
+		err := New().Compile(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q - Expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	l := lexer.New(test.MakeFile(input))
+	p := parser.New(l, false)
+	program := p.Parse()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q - Parser errors: %q", input, p.Errors())
+	}
+
+	c := New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("%q - Compiler error: %s", input, err)
+	}
+
+	return c.Bytecode()
+}
+
+func concatInstructions(ins ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, i := range ins {
+		out = append(out, i...)
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jellycat-io/eevee/code"
+	"github.com/jellycat-io/eevee/object"
+)
+
+// Disassemble writes a listing of the main program followed by every
+// function in the constant pool. Each instruction is shown with its offset
+// and the source line it was compiled from; "|" repeats the previous line.
+func Disassemble(out io.Writer, bc *Bytecode) {
+	disassembleChunk(out, "main", bc.Instructions, bc.Lines, bc.Constants)
+
+	for _, constant := range bc.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fmt.Fprintln(out)
+			disassembleChunk(out, "fn "+fn.Name, fn.Instructions, fn.Lines, bc.Constants)
+		}
+	}
+}
+
+func disassembleChunk(out io.Writer, name string, ins code.Instructions, lines []code.LineInfo, constants []object.Object) {
+	fmt.Fprintf(out, "== %s ==\n", name)
+
+	previousLine := -1
+	i := 0
+	for i < len(ins) {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(out, "%04d ERROR: %s\n", i, err)
+			i++
+			continue
+		}
+
+		line := code.LineFor(lines, i)
+		if line == previousLine {
+			fmt.Fprintf(out, "%04d    | ", i)
+		} else {
+			fmt.Fprintf(out, "%04d %4d ", i, line)
+		}
+		previousLine = line
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		fmt.Fprint(out, def.Name)
+		for _, operand := range operands {
+			fmt.Fprintf(out, " %d", operand)
+		}
+
+		switch code.Opcode(ins[i]) {
+		case code.OpConstant, code.OpProperty, code.OpClosure:
+			fmt.Fprintf(out, " (%s)", constants[operands[0]].Inspect())
+		}
+		fmt.Fprintln(out)
+
+		i += 1 + read
+	}
+}
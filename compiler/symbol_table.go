@@ -0,0 +1,158 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope = SymbolScope("GLOBAL")
+	LocalScope  = SymbolScope("LOCAL")
+	FreeScope   = SymbolScope("FREE")
+	// BlockScope is a global slot declared in a block of the top level.
+	// Closures capture it like a local, so that each run of the block has
+	// a variable of its own.
+	BlockScope = SymbolScope("BLOCK")
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable resolves names to storage slots. Function tables own the
+// slots of their frame; block tables only scope names and allocate their
+// slots from the function (or global) table enclosing them.
+type SymbolTable struct {
+	Outer       *SymbolTable
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	owner          *SymbolTable
+	numDefinitions int
+	captured       int             // one past the highest slot captured by a closure
+	pending        map[string]bool // functions hoisted but not declared yet
+}
+
+func NewSymbolTable() *SymbolTable {
+	st := &SymbolTable{store: make(map[string]Symbol)}
+	st.owner = st
+	return st
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	st := NewSymbolTable()
+	st.Outer = outer
+	return st
+}
+
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	st := &SymbolTable{store: make(map[string]Symbol), Outer: outer}
+	st.owner = outer.owner
+	return st
+}
+
+// NumDefinitions is the number of slots allocated by the owning frame.
+func (st *SymbolTable) NumDefinitions() int {
+	return st.owner.numDefinitions
+}
+
+func (st *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: st.owner.numDefinitions}
+	switch {
+	case st.owner.Outer != nil:
+		symbol.Scope = LocalScope
+	case st.owner != st:
+		symbol.Scope = BlockScope
+	default:
+		symbol.Scope = GlobalScope
+	}
+
+	st.store[name] = symbol
+	st.owner.numDefinitions++
+	return symbol
+}
+
+// Capture records that a closure captured the local or block variable s.
+func (st *SymbolTable) Capture(s Symbol) {
+	if s.Index >= st.owner.captured {
+		st.owner.captured = s.Index + 1
+	}
+}
+
+// Captured reports whether a closure captured a local whose slot is start
+// or after it.
+func (st *SymbolTable) Captured(start int) bool {
+	return st.owner.captured > start
+}
+
+// Hoist defines the name of a function declared further down the block,
+// so that the bodies of the functions before it can refer to it.
+func (st *SymbolTable) Hoist(name string) Symbol {
+	if st.pending == nil {
+		st.pending = make(map[string]bool)
+	}
+	st.pending[name] = true
+
+	return st.Define(name)
+}
+
+// Declare returns the symbol hoisted for the function name, which the code
+// of the block sees from its declaration on.
+func (st *SymbolTable) Declare(name string) (Symbol, bool) {
+	if !st.pending[name] {
+		return Symbol{}, false
+	}
+	delete(st.pending, name)
+
+	return st.store[name], true
+}
+
+// Hoisted reports whether name is a function hoisted in the blocks of the
+// current function and not declared yet.
+func (st *SymbolTable) Hoisted(name string) bool {
+	for t := st; t != nil; t = t.Outer {
+		if t.pending[name] {
+			return true
+		}
+		if t.owner == t {
+			break
+		}
+	}
+
+	return false
+}
+
+// Resolve returns the symbol of name. The code of a function does not see
+// the functions hoisted in its blocks before their declaration, like the
+// evaluator, but the functions it defines do, as they only run later.
+func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
+	return st.resolve(name, false)
+}
+
+func (st *SymbolTable) resolve(name string, nested bool) (Symbol, bool) {
+	if symbol, ok := st.store[name]; ok && (nested || !st.pending[name]) {
+		return symbol, true
+	}
+
+	if st.Outer == nil {
+		return Symbol{}, false
+	}
+
+	if st.owner != st {
+		return st.Outer.resolve(name, nested)
+	}
+
+	symbol, ok := st.Outer.resolve(name, true)
+	if !ok || symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+
+	return st.defineFree(symbol), true
+}
+
+func (st *SymbolTable) defineFree(original Symbol) Symbol {
+	st.FreeSymbols = append(st.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(st.FreeSymbols) - 1, Scope: FreeScope}
+	st.store[original.Name] = symbol
+	return symbol
+}
@@ -8,6 +8,13 @@ import (
 
 type Config struct {
 	TabSize int `toml:"tab_size"`
+	// StrictIndentation requires each file to be indented with either
+	// tabs or spaces only.
+	StrictIndentation bool `toml:"strict_indentation"`
+	// LogicalOperators is how eevee fmt spells the logical operators:
+	// "symbols" for `&&`, `||` and `!`, or "words" for `and`, `or` and
+	// `not`.
+	LogicalOperators string `toml:"logical_operators"`
 }
 
 func GetConfig() Config {
@@ -17,5 +24,13 @@ func GetConfig() Config {
 		log.Fatal(err)
 	}
 
+	switch config.LogicalOperators {
+	case "":
+		config.LogicalOperators = "symbols"
+	case "symbols", "words":
+	default:
+		log.Fatalf("eevee.toml: logical_operators is %q, expected \"symbols\" or \"words\"", config.LogicalOperators)
+	}
+
 	return config
 }
@@ -0,0 +1,147 @@
+// Package cst builds concrete syntax trees, which hold every token of a
+// source with the whitespace and comments around it. The tokens are laid
+// out under the nodes of the syntax tree whose spans cover them, so a
+// tool can find the comments of a node, and the text of the tree is the
+// source as the lexer read it. The Denormalize method of the lexer turns
+// it back into the exact content, with its CRLF line breaks.
+package cst
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/token"
+)
+
+// Node is a node of a concrete syntax tree. An inner node stands for a
+// node of the syntax tree, and its children are the nodes and the tokens
+// within its span, in source order. A leaf holds a token, with its trivia.
+type Node struct {
+	AST      ast.Node // nil for a leaf
+	Token    token.Token
+	Children []*Node
+}
+
+// IsToken reports whether n is a leaf.
+func (n *Node) IsToken() bool {
+	return n.AST == nil
+}
+
+// Tokens returns the tokens under n, in source order.
+func (n *Node) Tokens() []token.Token {
+	var tokens []token.Token
+	n.walkTokens(func(tok token.Token) {
+		tokens = append(tokens, tok)
+	})
+
+	return tokens
+}
+
+// Text returns the source text of n: its tokens with their leading and
+// trailing trivia. The text of the root of a tree is the whole source.
+func (n *Node) Text() string {
+	var out strings.Builder
+	n.walkTokens(func(tok token.Token) {
+		if tok.Trivia != nil {
+			out.WriteString(token.TriviaText(tok.Trivia.Leading))
+		}
+		out.WriteString(tok.Literal)
+		if tok.Trivia != nil {
+			out.WriteString(token.TriviaText(tok.Trivia.Trailing))
+		}
+	})
+
+	return out.String()
+}
+
+func (n *Node) walkTokens(visit func(token.Token)) {
+	if n.IsToken() {
+		visit(n.Token)
+		return
+	}
+
+	for _, child := range n.Children {
+		child.walkTokens(visit)
+	}
+}
+
+// Parse parses the tokens of l, which must keep trivia, and returns the
+// concrete syntax tree of the source. It returns the diagnostics of the
+// lexer and of the parser, and a tree even when there are errors.
+func Parse(l *lexer.Lexer) (*Node, diagnostics.List) {
+	r := &recorder{Lexer: l}
+	p := parser.New(r, false)
+	program := p.Parse()
+
+	return Build(program, r.tokens), append(l.Diagnostics(), p.Diagnostics()...)
+}
+
+// recorder keeps the tokens that the parser reads from a lexer, up to the
+// first EOF.
+type recorder struct {
+	*lexer.Lexer
+	tokens []token.Token
+}
+
+func (r *recorder) NextToken() token.Token {
+	tok := r.Lexer.NextToken()
+	if len(r.tokens) == 0 || r.tokens[len(r.tokens)-1].Type != token.EOF {
+		r.tokens = append(r.tokens, tok)
+	}
+
+	return tok
+}
+
+// Build returns the concrete syntax tree of program, whose tokens are
+// tokens. Each token goes under the innermost node whose span holds it.
+// The tokens of no width, such as INDENT and EOL, go under the node that
+// holds their position strictly, so that a statement does not own the
+// line break after it. All the tokens make it into the tree, in order,
+// even when a span is off.
+func Build(program *ast.Program, tokens []token.Token) *Node {
+	return build(program, tokens)
+}
+
+func build(node ast.Node, tokens []token.Token) *Node {
+	n := &Node{AST: node}
+
+	children := ast.Children(node)
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].Pos().Offset < children[j].Pos().Offset
+	})
+
+	i := 0
+	for _, child := range children {
+		start, end := child.Pos().Offset, child.End().Offset
+		for i < len(tokens) && !within(tokens[i], start, end) && tokens[i].Offset <= start {
+			n.Children = append(n.Children, &Node{Token: tokens[i]})
+			i++
+		}
+
+		j := i
+		for j < len(tokens) && within(tokens[j], start, end) {
+			j++
+		}
+		n.Children = append(n.Children, build(child, tokens[i:j]))
+		i = j
+	}
+
+	for _, tok := range tokens[i:] {
+		n.Children = append(n.Children, &Node{Token: tok})
+	}
+
+	return n
+}
+
+// within reports whether tok is in the span from start to end.
+func within(tok token.Token, start, end int) bool {
+	if tok.Literal == "" {
+		return start < tok.Offset && tok.Offset < end
+	}
+
+	return start <= tok.Offset && tok.Offset+len(tok.Literal) <= end
+}
@@ -0,0 +1,112 @@
+package cst
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/source"
+	"github.com/jellycat-io/eevee/test"
+	"github.com/jellycat-io/eevee/token"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		test.MakeInput(
+			`# Pokémon`,
+			``,
+			`fn evolve(pokemon, stone)  # evolves with a stone`,
+			`	if stone == "fire" then`,
+			`		return { name: "flareon", level: pokemon.level }`,
+			`	# other stones`,
+			`	return pokemon`,
+			``,
+			`let team = [evolve(eevee, "fire"),`,
+			`            evolve(eevee, "water")]`,
+			`while x do { x -= 1; print("{x}  left") }`,
+			`let total = 1 + \`,
+			`	2`,
+		),
+		"\uFEFFlet x = 1\r\n\r\n# end\r\n",
+		"let a = 1\r\nlet b = 2\n",
+		"let s = \"\"\"a\r\nb\n\"\"\"\r\n\r\nif s then\n\tprint(s)  # s\r\n",
+		"let x = (1 +\n\tprint(x 1)\nfn (",
+		"",
+	}
+
+	for _, input := range tests {
+		file := source.NewFileSet(4).AddFile("main.eev", []byte(input))
+
+		l := lexer.NewWithMode(file, lexer.KeepTrivia)
+		tree, _ := Parse(l)
+		if text := l.Denormalize(tree.Text()); text != input {
+			t.Errorf("Expected the tree to hold %q, got %q", input, text)
+		}
+
+		l = lexer.NewReader("main.eev", strings.NewReader(input), 4, lexer.KeepTrivia)
+		tree, _ = Parse(l)
+		if text := l.Denormalize(tree.Text()); text != input {
+			t.Errorf("Expected the streamed tree to hold %q, got %q", input, text)
+		}
+	}
+}
+
+func TestTreeLayout(t *testing.T) {
+	input := test.MakeInput(
+		`# header`,
+		`let x = 1  # one`,
+		`if x then`,
+		`	print(x)`,
+	)
+
+	tree, diags := Parse(lexer.NewWithMode(test.MakeFile(input), lexer.KeepTrivia))
+	if len(diags) != 0 {
+		t.Fatalf("Unexpected diagnostics: %q", diags.Strings())
+	}
+
+	if _, ok := tree.AST.(*ast.Program); !ok {
+		t.Fatalf("Expected the root to be the program, got %T", tree.AST)
+	}
+
+	var statements []*Node
+	for _, child := range tree.Children {
+		if !child.IsToken() {
+			statements = append(statements, child)
+		}
+	}
+	if len(statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d", len(statements))
+	}
+
+	if text := statements[0].Text(); text != "let x = 1  # one" {
+		t.Errorf("Expected the let statement to hold its comment, got %q", text)
+	}
+	if text := statements[1].Text(); text != "if x then\n\tprint(x)" {
+		t.Errorf("Expected the if statement to hold its body, got %q", text)
+	}
+
+	first := tree.Children[0]
+	if !first.IsToken() || first.Token.Type != token.EOL || token.TriviaText(first.Token.Trivia.Leading) != "# header" {
+		t.Errorf("Expected the header comment to lead the first EOL, got %q", first.Token)
+	}
+
+	call := statements[1].Children[len(statements[1].Children)-1]
+	if _, ok := call.AST.(*ast.BlockStatement); !ok {
+		t.Fatalf("Expected the body to be last, got %T", call.AST)
+	}
+
+	var types []token.TokenType
+	for _, tok := range call.Tokens() {
+		types = append(types, tok.Type)
+	}
+	expected := []token.TokenType{token.IDENT, token.LPAREN, token.IDENT, token.RPAREN}
+	if len(types) != len(expected) {
+		t.Fatalf("Expected the body to hold %q, got %q", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("Expected the body to hold %q, got %q", expected, types)
+		}
+	}
+}
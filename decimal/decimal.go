@@ -0,0 +1,218 @@
+// Package decimal implements exact base 10 numbers of arbitrary size, used
+// by decimal literals such as 12.30d.
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DivisionDigits is the number of digits Quo keeps beyond the scales of
+// its operands when the quotient does not terminate.
+const DivisionDigits = 16
+
+var ten = big.NewInt(10)
+
+// Decimal is the number unscaled × 10^-scale. The scale is never negative
+// and is kept by the operations, so 12.30 and 12.3 are equal but print
+// differently. The zero value is 0.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int32
+}
+
+func New(unscaled *big.Int, scale int32) Decimal {
+	if scale < 0 {
+		return Decimal{unscaled: new(big.Int).Mul(unscaled, pow10(-scale))}
+	}
+
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// FromInt returns the decimal equal to the integer i.
+func FromInt(i *big.Int) Decimal {
+	return New(i, 0)
+}
+
+// Parse reads a decimal written as digits with an optional sign, fraction
+// and exponent, such as -12.30 or 1.5e3.
+func Parse(s string) (Decimal, error) {
+	mantissa, exponent := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		mantissa, exponent = s[:i], exp
+	}
+
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	unscaled, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	scale := int64(len(fraction)) - exponent
+	if scale < -1<<31 || scale >= 1<<31 {
+		return Decimal{}, fmt.Errorf("decimal %q is out of range", s)
+	}
+
+	return New(unscaled, int32(scale)), nil
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Sign returns -1, 0 or 1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsInt reports whether d has no fractional part.
+func (d Decimal) IsInt() bool {
+	return d.Rat().IsInt()
+}
+
+// Rat returns the exact value of d as a fraction.
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.int(), pow10(d.scale))
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	x, y, scale := align(d, other)
+	return Decimal{unscaled: x.Add(x, y), scale: scale}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	x, y, scale := align(d, other)
+	return Decimal{unscaled: x.Sub(x, y), scale: scale}
+}
+
+// Mul returns the exact product, whose scale is the sum of the scales.
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.int(), other.int()), scale: d.scale + other.scale}
+}
+
+// Quo returns d / other with the larger scale of the two, extended by up
+// to DivisionDigits digits when the quotient needs them. The last digit is
+// rounded half to even. Quo panics if other is zero.
+func (d Decimal) Quo(other Decimal) Decimal {
+	minScale := maxScale(d.scale, other.scale)
+	scale := minScale + DivisionDigits
+
+	// d / other = unscaled(d) × 10^(scale(other) + scale - scale(d)) / unscaled(other) × 10^-scale
+	num := new(big.Int).Mul(d.int(), pow10(other.scale+scale-d.scale))
+	den := other.int()
+
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	half := new(big.Int).Abs(r)
+	switch half.Lsh(half, 1).CmpAbs(den) {
+	case 1:
+		q.Add(q, big.NewInt(int64(num.Sign()*den.Sign())))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(num.Sign()*den.Sign())))
+		}
+	}
+
+	digit := new(big.Int)
+	for scale > minScale {
+		if _, digit = q.QuoRem(q, ten, digit); digit.Sign() != 0 {
+			q.Mul(q, ten).Add(q, digit)
+			break
+		}
+		scale--
+	}
+
+	return Decimal{unscaled: q, scale: scale}
+}
+
+// Rem returns the remainder of d / other truncated towards zero, which has
+// the sign of d. Rem panics if other is zero.
+func (d Decimal) Rem(other Decimal) Decimal {
+	x, y, scale := align(d, other)
+	return Decimal{unscaled: x.Rem(x, y), scale: scale}
+}
+
+// Cmp compares the values of d and other, ignoring their scales.
+func (d Decimal) Cmp(other Decimal) int {
+	x, y, _ := align(d, other)
+	return x.Cmp(y)
+}
+
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+
+	if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(d.scale)
+
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// MarshalJSON encodes d as a string, since JSON numbers are commonly
+// decoded as floats and would lose digits and trailing zeros.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("decimal must be a JSON string, got %s", data)
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+
+	return nil
+}
+
+func (d Decimal) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+
+	return d.unscaled
+}
+
+// align returns copies of the unscaled values of x and y brought to their
+// larger scale.
+func align(x, y Decimal) (*big.Int, *big.Int, int32) {
+	scale := maxScale(x.scale, y.scale)
+	a := new(big.Int).Mul(x.int(), pow10(scale-x.scale))
+	b := new(big.Int).Mul(y.int(), pow10(scale-y.scale))
+
+	return a, b, scale
+}
+
+func maxScale(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,123 @@
+package decimal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"12.30", "12.30"},
+		{"0.05", "0.05"},
+		{".5", "0.5"},
+		{"-7", "-7"},
+		{"-0.001", "-0.001"},
+		{"1.5e3", "1500"},
+		{"2.5e-3", "0.0025"},
+		{"123456789012345678901234567890.123", "123456789012345678901234567890.123"},
+	}
+
+	for _, tt := range tests {
+		d, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("%q - Unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if d.String() != tt.expected {
+			t.Errorf("%q - Expected %s, got %s", tt.input, tt.expected, d)
+		}
+	}
+
+	for _, input := range []string{"", ".", "1.2.3", "1e", "abc"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("%q - Expected an error", input)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		op       string
+		left     string
+		right    string
+		expected string
+	}{
+		{"+", "0.1", "0.2", "0.3"},
+		{"+", "12.30", "0.7", "13.00"},
+		{"-", "1", "0.01", "0.99"},
+		{"*", "1.10", "3", "3.30"},
+		{"*", "0.1", "0.1", "0.01"},
+		{"/", "12.30", "3", "4.10"},
+		{"/", "1", "4", "0.25"},
+		{"/", "1", "3", "0.3333333333333333"},
+		{"/", "2", "3", "0.6666666666666667"},
+		{"/", "-2", "3", "-0.6666666666666667"},
+		{"/", "0.5", "-0.25", "-2.00"},
+		{"%", "7.5", "2", "1.5"},
+		{"%", "-7.5", "2", "-1.5"},
+	}
+
+	for _, tt := range tests {
+		left, right := mustParse(t, tt.left), mustParse(t, tt.right)
+
+		var result Decimal
+		switch tt.op {
+		case "+":
+			result = left.Add(right)
+		case "-":
+			result = left.Sub(right)
+		case "*":
+			result = left.Mul(right)
+		case "/":
+			result = left.Quo(right)
+		case "%":
+			result = left.Rem(right)
+		}
+
+		if result.String() != tt.expected {
+			t.Errorf("%s %s %s - Expected %s, got %s", tt.left, tt.op, tt.right, tt.expected, result)
+		}
+	}
+}
+
+func TestCmp(t *testing.T) {
+	if mustParse(t, "12.30").Cmp(mustParse(t, "12.3")) != 0 {
+		t.Errorf("Expected 12.30 to equal 12.3")
+	}
+	if mustParse(t, "-0.5").Cmp(mustParse(t, "0.25")) != -1 {
+		t.Errorf("Expected -0.5 to be less than 0.25")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	d := mustParse(t, "12345678901234567890.10")
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if string(data) != `"12345678901234567890.10"` {
+		t.Fatalf("Expected a JSON string, got %s", data)
+	}
+
+	var decoded Decimal
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if decoded.String() != d.String() {
+		t.Fatalf("Expected %s after a round trip, got %s", d, decoded)
+	}
+}
+
+func mustParse(t *testing.T, s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		t.Fatalf("%q - Unexpected error: %s", s, err)
+	}
+
+	return d
+}
@@ -0,0 +1,202 @@
+// Package diagnostics describes the problems found in a source file, such
+// as lexer and parser errors, in a form that tools can consume: each
+// diagnostic has a severity, a stable code, the span it points at, and
+// optional labels, notes and suggested fixes.
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jellycat-io/eevee/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+var severityNames = map[Severity]string{
+	Error:   "error",
+	Warning: "warning",
+	Note:    "note",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Code identifies a kind of diagnostic. Codes never change meaning once
+// released, so they can be matched on instead of messages.
+type Code string
+
+const (
+	// Lexer errors
+	InvalidNumber             Code = "E0101"
+	UnterminatedString        Code = "E0102"
+	InvalidEscape             Code = "E0103"
+	UnterminatedInterpolation Code = "E0104"
+	UnclosedBracket           Code = "E0105"
+	UnmatchedBracket          Code = "E0106"
+	InconsistentIndentation   Code = "E0107"
+	MixedIndentation          Code = "E0108"
+
+	// Parser errors
+	UnexpectedToken         Code = "E0201"
+	UnclosedDelimiter       Code = "E0202"
+	InvalidMapKey           Code = "E0203"
+	InvalidNumberLiteral    Code = "E0204"
+	InvalidAssignmentTarget Code = "E0205"
+	MixedBlockStyle         Code = "E0206"
+)
+
+// Span is the part of a file between two positions. The end is exclusive,
+// so a span whose start and end are equal points between two characters.
+type Span struct {
+	Filename string         `json:"file,omitempty"`
+	Start    token.Position `json:"start"`
+	End      token.Position `json:"end"`
+}
+
+// Label points at a span related to a diagnostic, such as the opening
+// bracket of an unclosed list.
+type Label struct {
+	Span    Span   `json:"span"`
+	Message string `json:"message"`
+}
+
+// Fix is a suggested change that resolves a diagnostic.
+type Fix struct {
+	Message string `json:"message"`
+	Edits   []Edit `json:"edits"`
+}
+
+// Edit replaces the text of a span with NewText. An empty span inserts it.
+type Edit struct {
+	Span    Span   `json:"span"`
+	NewText string `json:"newText"`
+}
+
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     Code     `json:"code"`
+	Message  string   `json:"message"`
+	Span     Span     `json:"span"`
+	Labels   []Label  `json:"labels,omitempty"`
+	Notes    []string `json:"notes,omitempty"`
+	Fixes    []Fix    `json:"fixes,omitempty"`
+}
+
+// Errorf returns an error diagnostic at span with a formatted message.
+func Errorf(code Code, span Span, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{Severity: Error, Code: code, Message: fmt.Sprintf(format, args...), Span: span}
+}
+
+// Warningf returns a warning diagnostic at span with a formatted message.
+func Warningf(code Code, span Span, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{Severity: Warning, Code: code, Message: fmt.Sprintf(format, args...), Span: span}
+}
+
+// WithLabel returns d with a secondary span and its message.
+func (d Diagnostic) WithLabel(span Span, msg string) Diagnostic {
+	d.Labels = append(d.Labels, Label{Span: span, Message: msg})
+	return d
+}
+
+func (d Diagnostic) WithNote(note string) Diagnostic {
+	d.Notes = append(d.Notes, note)
+	return d
+}
+
+// WithFix returns d with a fix that replaces span with newText.
+func (d Diagnostic) WithFix(msg string, span Span, newText string) Diagnostic {
+	d.Fixes = append(d.Fixes, Fix{Message: msg, Edits: []Edit{{Span: span, NewText: newText}}})
+	return d
+}
+
+// Error returns the message of d prefixed with its file, line and column.
+func (d Diagnostic) Error() string {
+	msg := fmt.Sprintf("[%d, %d] %s", d.Span.Start.Line, d.Span.Start.Column, d.Message)
+	if d.Span.Filename != "" {
+		msg = d.Span.Filename + " " + msg
+	}
+
+	return msg
+}
+
+// List is a list of diagnostics, in the order they were reported.
+type List []Diagnostic
+
+// HasErrors reports whether l has a diagnostic of error severity.
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sort orders l by file and then by position, keeping the order of the
+// diagnostics reported at the same place.
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Span, l[j].Span
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+
+		return a.Start.Offset < b.Start.Offset
+	})
+}
+
+// Combine returns the diagnostics of two stages that read a file in turn,
+// in source order. From the first line an error of the first stage points
+// at, the errors of the second follow from it, so only the diagnostics of
+// the second stage before that line are kept.
+func Combine(first, second List) List {
+	line := 0
+	for _, d := range first {
+		if d.Severity != Error {
+			continue
+		}
+		spans := []Span{d.Span}
+		for _, label := range d.Labels {
+			spans = append(spans, label.Span)
+		}
+		for _, span := range spans {
+			if line == 0 || span.Start.Line < line {
+				line = span.Start.Line
+			}
+		}
+	}
+
+	combined := append(List{}, first...)
+	for _, d := range second {
+		if line == 0 || d.Span.Start.Line < line {
+			combined = append(combined, d)
+		}
+	}
+	combined.Sort()
+
+	return combined
+}
+
+// Strings returns the Error text of each diagnostic.
+func (l List) Strings() []string {
+	msgs := make([]string, len(l))
+	for i, d := range l {
+		msgs[i] = d.Error()
+	}
+
+	return msgs
+}
@@ -0,0 +1,91 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jellycat-io/eevee/token"
+)
+
+func TestDiagnostic(t *testing.T) {
+	span := Span{Filename: "main.eev", Start: token.Position{Line: 2, Column: 5, Offset: 14}, End: token.Position{Line: 2, Column: 6, Offset: 15}}
+	d := Errorf(UnexpectedToken, span, "Unexpected token: %q", "EOL").
+		WithLabel(span, "here").
+		WithNote("a note").
+		WithFix("remove it", span, "")
+
+	if d.Error() != `main.eev [2, 5] Unexpected token: "EOL"` {
+		t.Errorf("Wrong error text, got %q", d.Error())
+	}
+	if len(d.Labels) != 1 || len(d.Notes) != 1 || len(d.Fixes) != 1 || len(d.Fixes[0].Edits) != 1 {
+		t.Errorf("Expected one label, note and fix, got %+v", d)
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, field := range []string{`"severity":"error"`, `"code":"E0201"`, `"file":"main.eev"`, `"newText":""`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("Expected %s in %s", field, data)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	at := func(filename string, offset int) Span {
+		return Span{Filename: filename, Start: token.Position{Offset: offset}}
+	}
+	l := List{
+		{Severity: Warning, Message: "c", Span: at("b.eev", 0)},
+		{Severity: Warning, Message: "b", Span: at("a.eev", 9)},
+		{Severity: Warning, Message: "a", Span: at("a.eev", 3)},
+	}
+
+	if l.HasErrors() {
+		t.Errorf("Expected a list of warnings to have no errors")
+	}
+
+	l.Sort()
+	for i, msg := range []string{"a", "b", "c"} {
+		if l[i].Message != msg {
+			t.Errorf("Tests[%d] - Expected %q, got %q", i, msg, l[i].Message)
+		}
+	}
+
+	if !append(l, Errorf(InvalidNumber, at("", 0), "")).HasErrors() {
+		t.Errorf("Expected the list to have an error")
+	}
+}
+
+func TestCombine(t *testing.T) {
+	on := func(line int) Span {
+		return Span{Start: token.Position{Line: line, Offset: line * 10}}
+	}
+	lexer := List{
+		Errorf(UnmatchedBracket, on(6), "mismatch").WithLabel(on(4), "unclosed"),
+		Warningf(MixedIndentation, on(2), "mixed"),
+	}
+	parser := List{
+		Errorf(UnexpectedToken, on(1), "first"),
+		Errorf(UnexpectedToken, on(3), "third"),
+		Errorf(UnexpectedToken, on(4), "fourth"),
+		Errorf(UnexpectedToken, on(6), "sixth"),
+	}
+
+	combined := Combine(lexer, parser)
+	expected := []string{"first", "mixed", "third", "mismatch"}
+	if len(combined) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %q", len(expected), combined.Strings())
+	}
+	for i, msg := range expected {
+		if combined[i].Message != msg {
+			t.Errorf("Tests[%d] - Expected %q, got %q", i, msg, combined[i].Message)
+		}
+	}
+
+	if len(Combine(lexer[1:], parser)) != 5 {
+		t.Errorf("Expected every diagnostic without an error in the first stage, got %q", Combine(lexer[1:], parser).Strings())
+	}
+}
@@ -0,0 +1,85 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jellycat-io/eevee/source"
+)
+
+// Formats are the names of the output formats accepted by Write.
+var Formats = []string{"text", "json", "sarif"}
+
+// Write writes l to w in the named format: "text" renders code frames,
+// "json" writes the diagnostics as a JSON array and "sarif" writes a SARIF
+// 2.1.0 log. The files of the spans are looked up in fset.
+func Write(w io.Writer, format string, l List, fset *source.FileSet) error {
+	switch format {
+	case "text":
+		RenderAll(w, l, fset)
+		return nil
+	case "json":
+		return WriteJSON(w, l, fset)
+	case "sarif":
+		return WriteSARIF(w, l, fset)
+	default:
+		return fmt.Errorf("unknown diagnostics format %q, expected one of %q", format, Formats)
+	}
+}
+
+// WriteJSON writes l as an indented JSON array, which is empty rather than
+// null when there are no diagnostics. Like in SARIF, the offsets of the
+// spans in the files of fset are those of the file as read, with its byte
+// order mark and CRLF line breaks. The others are offsets in the text the
+// lexer reads, without them.
+func WriteJSON(w io.Writer, l List, fset *source.FileSet) error {
+	raw := make(List, len(l))
+	for i, d := range l {
+		raw[i] = rawOffsets(d, fset)
+	}
+
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// rawOffsets returns d with the offsets of its spans in the file as read.
+func rawOffsets(d Diagnostic, fset *source.FileSet) Diagnostic {
+	d.Span = rawSpan(d.Span, fset)
+
+	labels := make([]Label, len(d.Labels))
+	for i, label := range d.Labels {
+		labels[i] = Label{Span: rawSpan(label.Span, fset), Message: label.Message}
+	}
+	d.Labels = labels
+
+	fixes := make([]Fix, len(d.Fixes))
+	for i, fix := range d.Fixes {
+		edits := make([]Edit, len(fix.Edits))
+		for j, edit := range fix.Edits {
+			edits[j] = Edit{Span: rawSpan(edit.Span, fset), NewText: edit.NewText}
+		}
+		fixes[i] = Fix{Message: fix.Message, Edits: edits}
+	}
+	d.Fixes = fixes
+
+	return d
+}
+
+func rawSpan(span Span, fset *source.FileSet) Span {
+	if fset == nil {
+		return span
+	}
+
+	if file := fset.Lookup(span.Filename); file != nil {
+		span.Start.Offset = file.RawOffset(span.Start.Offset)
+		span.End.Offset = file.RawOffset(span.End.Offset)
+	}
+
+	return span
+}
@@ -0,0 +1,154 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jellycat-io/eevee/source"
+	"github.com/jellycat-io/eevee/token"
+)
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, "json", nil, nil); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if out.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %q", out.String())
+	}
+
+	if err := Write(&out, "xml", nil, nil); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	fset := source.NewFileSet(4)
+	fset.AddFile("examples/main.eev", []byte("if x\n\tadd(1 2)\n"))
+
+	span := func(column, offset, length int) Span {
+		return Span{
+			Filename: "examples/main.eev",
+			Start:    token.Position{Line: 2, Column: column, Offset: offset},
+			End:      token.Position{Line: 2, Column: column + length, Offset: offset + length},
+		}
+	}
+	l := List{
+		Errorf(UnexpectedToken, span(12, 12, 1), "Unexpected token").WithLabel(span(8, 9, 1), "starts here"),
+		Errorf(UnexpectedToken, span(5, 6, 3), "Again").WithNote("a note"),
+	}
+
+	var out bytes.Buffer
+	if err := Write(&out, "sarif", l, fset); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("Invalid SARIF: %s", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a SARIF 2.1.0 log with one run, got %s", out.String())
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "E0201" || run.Tool.Driver.Rules[0].Name != "UnexpectedToken" {
+		t.Errorf("Expected the single rule E0201, got %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(run.Results))
+	}
+
+	result := run.Results[0]
+	location := result.Locations[0].PhysicalLocation
+	if result.RuleID != "E0201" || result.Level != "error" || location.ArtifactLocation.URI != "examples/main.eev" {
+		t.Errorf("Wrong result: %+v", result)
+	}
+
+	// The tab counts as one code point in SARIF columns.
+	if r := location.Region; r.StartLine != 2 || r.StartColumn != 8 || r.EndColumn != 9 || *r.ByteOffset != 12 || *r.ByteLength != 1 {
+		t.Errorf("Wrong region: %+v", r)
+	}
+	if len(result.RelatedLocations) != 1 || result.RelatedLocations[0].PhysicalLocation.Region.StartColumn != 5 {
+		t.Errorf("Wrong related locations: %+v", result.RelatedLocations)
+	}
+	if notes := run.Results[1].Properties["notes"]; notes == nil {
+		t.Errorf("Expected the notes in the properties of the result")
+	}
+}
+
+func TestWriteByteOffsets(t *testing.T) {
+	tests := []struct {
+		input          string
+		offset, length int
+		byteOffset     int
+		byteLength     int
+	}{
+		{"let a = 1\nlet = 3\n", 14, 1, 14, 1},
+		{"let a = 1\r\nlet = 3\r\n", 14, 1, 15, 1},
+		{"\uFEFFlet a = 1\nlet = 3\n", 14, 1, 17, 1},
+		{"\uFEFFlet = 3\r\n", 4, 1, 7, 1},
+		{"let a = 1\r\nlet b = 2\nlet = 3\r\n", 24, 4, 25, 5},
+	}
+
+	for _, tt := range tests {
+		fset := source.NewFileSet(4)
+		file := fset.AddFile("main.eev", []byte(tt.input))
+		start := file.Position(file.Pos(tt.offset))
+		end := file.Position(file.Pos(tt.offset + tt.length))
+		span := Span{
+			Filename: "main.eev",
+			Start:    token.Position{Line: start.Line, Column: start.Column, Offset: start.Offset},
+			End:      token.Position{Line: end.Line, Column: end.Column, Offset: end.Offset},
+		}
+
+		var out bytes.Buffer
+		if err := Write(&out, "sarif", List{Errorf(UnexpectedToken, span, "Unexpected token")}, fset); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var log sarifLog
+		if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+			t.Fatalf("Invalid SARIF: %s", err)
+		}
+
+		r := log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region
+		if r.ByteOffset == nil || r.ByteLength == nil || *r.ByteOffset != tt.byteOffset || *r.ByteLength != tt.byteLength {
+			t.Errorf("%q - Expected byte offset %d and length %d, got %+v", tt.input, tt.byteOffset, tt.byteLength, r)
+		}
+
+		// Without the file, the offsets in the source cannot be mapped back.
+		out.Reset()
+		if err := Write(&out, "sarif", List{Errorf(UnexpectedToken, span, "Unexpected token")}, nil); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if bytes.Contains(out.Bytes(), []byte("byteOffset")) {
+			t.Errorf("%q - Expected no byte offset without the file, got %s", tt.input, out.String())
+		}
+
+		// The JSON output reports the same offsets.
+		out.Reset()
+		l := List{Errorf(UnexpectedToken, span, "Unexpected token").WithLabel(span, "here")}
+		if err := Write(&out, "json", l, fset); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		var decoded []struct {
+			Span   Span    `json:"span"`
+			Labels []Label `json:"labels"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+			t.Fatalf("Invalid JSON: %s", err)
+		}
+		for _, s := range []Span{decoded[0].Span, decoded[0].Labels[0].Span} {
+			if s.Start.Offset != tt.byteOffset || s.End.Offset-s.Start.Offset != tt.byteLength {
+				t.Errorf("%q - Expected offset %d and length %d in JSON, got %+v", tt.input, tt.byteOffset, tt.byteLength, s)
+			}
+		}
+		if l[0].Span.Start.Offset != tt.offset {
+			t.Errorf("%q - Expected the diagnostics to be left as they were, got %+v", tt.input, l[0].Span)
+		}
+	}
+}
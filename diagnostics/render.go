@@ -0,0 +1,196 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/eevee/source"
+)
+
+var severityColors = map[Severity]string{
+	Error:   color.Red,
+	Warning: color.Yellow,
+	Note:    color.Cyan,
+}
+
+// annotation is a span underlined in a code frame, with '^' for the span
+// of the diagnostic and '-' for its labels.
+type annotation struct {
+	span    Span
+	marker  string
+	message string
+	color   string
+}
+
+// Render writes d as a code frame in the style of rustc:
+//
+//	error[E0201]: Expected "THEN", but got "EOL"
+//	 --> main.eev:3:9
+//	  |
+//	3 | if x > 1
+//	  | -- this `if` started here
+//	...
+//
+// The source lines of the span and of the labels are looked up in fset
+// and have their columns underlined, and the notes and fixes follow them.
+// When the file is not in fset, only the message and its location are
+// written. Colors are left out when they are turned off in go-color.
+func Render(w io.Writer, d Diagnostic, fset *source.FileSet) {
+	severityColor := severityColors[d.Severity]
+	fmt.Fprintf(w, "%s%s\n",
+		color.Colorize(color.Bold+severityColor, fmt.Sprintf("%s[%s]", d.Severity, d.Code)),
+		color.InBold(": "+d.Message))
+
+	annotations := []annotation{{span: d.Span, marker: "^", color: severityColor}}
+	for _, label := range d.Labels {
+		annotations = append(annotations, annotation{span: label.Span, marker: "-", message: label.Message, color: color.Blue})
+	}
+
+	var file *source.File
+	if fset != nil {
+		file = fset.Lookup(d.Span.Filename)
+	}
+
+	lines := make([]int, 0, len(annotations))
+	for _, a := range annotations {
+		if a.span.Filename == d.Span.Filename && a.span.Start.Line > 0 {
+			lines = append(lines, a.span.Start.Line)
+		}
+	}
+	sort.Ints(lines)
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(maxLine(lines))))
+	bar := color.Colorize(color.Bold+color.Blue, "|")
+	fmt.Fprintf(w, "%s%s %s\n", gutter, color.Colorize(color.Bold+color.Blue, "-->"), location(d.Span))
+
+	if file != nil && len(lines) > 0 {
+		fmt.Fprintf(w, "%s %s\n", gutter, bar)
+
+		for i, line := range lines {
+			if i > 0 && line == lines[i-1] {
+				continue
+			}
+			if i > 0 && line > lines[i-1]+1 {
+				fmt.Fprintln(w, color.Colorize(color.Bold+color.Blue, "..."))
+			}
+
+			text := expandTabs(file.Line(line), file.TabSize())
+			number := fmt.Sprintf("%*d", len(gutter), line)
+			fmt.Fprintf(w, "%s %s %s\n", color.Colorize(color.Bold+color.Blue, number), bar, text)
+
+			for _, a := range annotations {
+				if a.span.Filename != d.Span.Filename || a.span.Start.Line != line {
+					continue
+				}
+				underline := strings.Repeat(" ", a.span.Start.Column-1) + strings.Repeat(a.marker, width(a.span, text))
+				if a.message != "" {
+					underline += " " + a.message
+				}
+				fmt.Fprintf(w, "%s %s %s\n", gutter, bar, color.Colorize(color.Bold+a.color, underline))
+			}
+		}
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s %s %s\n", gutter, color.Colorize(color.Bold+color.Blue, "="), color.InBold("note: ")+note)
+	}
+	for _, fix := range d.Fixes {
+		help := fix.Message
+		if fixed, ok := applyFix(fix, file); ok {
+			help += ": " + strings.TrimSpace(fixed)
+		}
+		fmt.Fprintf(w, "%s %s %s\n", gutter, color.Colorize(color.Bold+color.Blue, "="), color.InBold("help: ")+help)
+	}
+}
+
+// RenderAll renders each diagnostic of l, separated by blank lines.
+func RenderAll(w io.Writer, l List, fset *source.FileSet) {
+	for i, d := range l {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		Render(w, d, fset)
+	}
+}
+
+func location(span Span) string {
+	return source.Position{Filename: span.Filename, Line: span.Start.Line, Column: span.Start.Column}.String()
+}
+
+// width returns the number of columns to underline for span on a line
+// whose expanded text is text. A span that goes on to the next lines is
+// underlined up to the end of its first line, and an empty span gets a
+// single marker.
+func width(span Span, text string) int {
+	end := span.End.Column
+	if span.End.Line != span.Start.Line {
+		end = len([]rune(text)) + 1
+	}
+
+	if end <= span.Start.Column {
+		return 1
+	}
+
+	return end - span.Start.Column
+}
+
+// applyFix returns the line of file changed by fix, when all its edits are
+// on the same line.
+func applyFix(fix Fix, file *source.File) (string, bool) {
+	if file == nil || len(fix.Edits) == 0 {
+		return "", false
+	}
+
+	line := fix.Edits[0].Span.Start.Line
+	if line < 1 || line > file.LineCount() {
+		return "", false
+	}
+	start := file.LineStart(line)
+	text := file.Line(line)
+
+	edits := append([]Edit(nil), fix.Edits...)
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Span.Start.Offset > edits[j].Span.Start.Offset })
+	for _, edit := range edits {
+		if edit.Span.Start.Line != line || edit.Span.End.Line != line || edit.Span.End.Offset-start > len(text) {
+			return "", false
+		}
+		text = text[:edit.Span.Start.Offset-start] + edit.NewText + text[edit.Span.End.Offset-start:]
+	}
+
+	return text, true
+}
+
+// expandTabs replaces the tabs of text by the spaces that reach the next
+// tab stop, so that columns line up with the underlines.
+func expandTabs(text string, tabSize int) string {
+	if !strings.Contains(text, "\t") {
+		return text
+	}
+
+	var out strings.Builder
+	column := 0
+	for _, r := range text {
+		if r == '\t' {
+			spaces := tabSize - column%tabSize
+			out.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		}
+		out.WriteRune(r)
+		column++
+	}
+
+	return out.String()
+}
+
+func maxLine(lines []int) int {
+	if len(lines) == 0 {
+		return 0
+	}
+
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,59 @@
+package diagnostics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/eevee/source"
+	"github.com/jellycat-io/eevee/token"
+)
+
+func TestRender(t *testing.T) {
+	color.Toggle(false)
+	defer color.Toggle(true)
+
+	fset := source.NewFileSet(4)
+	fset.AddFile("main.eev", []byte("if hp > 10\n\tlet x = 1\n\tlet s = \"é\n"))
+
+	at := func(line, column, offset, length int) Span {
+		return Span{
+			Filename: "main.eev",
+			Start:    token.Position{Line: line, Column: column, Offset: offset},
+			End:      token.Position{Line: line, Column: column + length, Offset: offset + length},
+		}
+	}
+
+	d := Errorf(UnterminatedString, at(3, 13, 31, 1), "Unterminated string literal").
+		WithLabel(at(1, 1, 0, 2), "this `if` started here").
+		WithNote("a note").
+		WithFix("close the string", at(3, 15, 34, 0), `"`)
+
+	expected := strings.Join([]string{
+		`error[E0102]: Unterminated string literal`,
+		` --> main.eev:3:13`,
+		`  |`,
+		`1 | if hp > 10`,
+		`  | -- this ` + "`if`" + ` started here`,
+		`...`,
+		`3 |     let s = "é`,
+		`  |             ^`,
+		`  = note: a note`,
+		`  = help: close the string: let s = "é"`,
+		``,
+	}, "\n")
+
+	var out bytes.Buffer
+	Render(&out, d, fset)
+
+	if out.String() != expected {
+		t.Errorf("Wrong code frame. Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	Render(&out, d, source.NewFileSet(4))
+	if !strings.HasPrefix(out.String(), "error[E0102]: Unterminated string literal\n --> main.eev:3:13\n  = note") {
+		t.Errorf("Expected only the message and location for an unknown file, got:\n%s", out.String())
+	}
+}
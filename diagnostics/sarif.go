@@ -0,0 +1,267 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/jellycat-io/eevee/source"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// rule describes a code for the tools that list the rules of a run, such
+// as SARIF viewers.
+type rule struct {
+	name        string
+	description string
+}
+
+var rules = map[Code]rule{
+	InvalidNumber:             {"InvalidNumber", "A number literal is malformed."},
+	UnterminatedString:        {"UnterminatedString", "A string literal is not closed."},
+	InvalidEscape:             {"InvalidEscape", "A string contains an invalid escape sequence."},
+	UnterminatedInterpolation: {"UnterminatedInterpolation", "An expression embedded in a string is not closed."},
+	UnclosedBracket:           {"UnclosedBracket", "A bracket is not closed before the end of the file."},
+	UnmatchedBracket:          {"UnmatchedBracket", "A closing bracket does not match the last bracket opened."},
+	InconsistentIndentation:   {"InconsistentIndentation", "A line is unindented to a level that no enclosing block has."},
+	MixedIndentation:          {"MixedIndentation", "The indentation mixes tabs and spaces."},
+	UnexpectedToken:           {"UnexpectedToken", "A token appears where the grammar does not allow it."},
+	UnclosedDelimiter:         {"UnclosedDelimiter", "A bracket or brace is not closed."},
+	InvalidMapKey:             {"InvalidMapKey", "A map literal has a key that is not a name, a string or a computed key."},
+	InvalidNumberLiteral:      {"InvalidNumberLiteral", "A number literal cannot be represented."},
+	InvalidAssignmentTarget:   {"InvalidAssignmentTarget", "The left-hand side of an assignment cannot be assigned to."},
+	MixedBlockStyle:           {"MixedBlockStyle", "A block mixes braces and indentation."},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name,omitempty"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string                 `json:"ruleId"`
+	RuleIndex        int                    `json:"ruleIndex"`
+	Level            string                 `json:"level"`
+	Message          sarifMessage           `json:"message"`
+	Locations        []sarifLocation        `json:"locations"`
+	RelatedLocations []sarifLocation        `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix             `json:"fixes,omitempty"`
+	Properties       map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int  `json:"startLine"`
+	StartColumn int  `json:"startColumn"`
+	EndLine     int  `json:"endLine"`
+	EndColumn   int  `json:"endColumn"`
+	ByteOffset  *int `json:"byteOffset,omitempty"`
+	ByteLength  *int `json:"byteLength,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+var sarifLevels = map[Severity]string{
+	Error:   "error",
+	Warning: "warning",
+	Note:    "note",
+}
+
+// WriteSARIF writes l as a SARIF 2.1.0 log with a single run, which code
+// scanning services use to annotate the lines of a change. The rules of
+// the run are the codes of l. SARIF columns count Unicode code points
+// without expanding tabs, so they are computed from the files in fset when
+// they are known.
+func WriteSARIF(w io.Writer, l List, fset *source.FileSet) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "eevee",
+			InformationURI: "https://github.com/jellycat-io/eevee",
+			Rules:          []sarifRule{},
+		}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+
+	codes := make([]string, 0)
+	for _, d := range l {
+		codes = append(codes, string(d.Code))
+	}
+	sort.Strings(codes)
+
+	ruleIndex := make(map[Code]int)
+	for _, code := range codes {
+		if _, ok := ruleIndex[Code(code)]; ok {
+			continue
+		}
+		ruleIndex[Code(code)] = len(run.Tool.Driver.Rules)
+		r := rules[Code(code)]
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: code, Name: r.name, ShortDescription: sarifMessage{Text: r.description}})
+	}
+
+	for _, d := range l {
+		result := sarifResult{
+			RuleID:    string(d.Code),
+			RuleIndex: ruleIndex[d.Code],
+			Level:     sarifLevels[d.Severity],
+			Message:   sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: physicalLocation(d.Span, fset)}},
+		}
+
+		for i, label := range d.Labels {
+			id := i
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				ID:               &id,
+				PhysicalLocation: physicalLocation(label.Span, fset),
+				Message:          &sarifMessage{Text: label.Message},
+			})
+		}
+
+		for _, fix := range d.Fixes {
+			sf := sarifFix{Description: sarifMessage{Text: fix.Message}}
+			for _, edit := range fix.Edits {
+				sf.ArtifactChanges = append(sf.ArtifactChanges, sarifArtifactChange{
+					ArtifactLocation: sarifArtifactLocation{URI: fileURI(edit.Span.Filename)},
+					Replacements: []sarifReplacement{{
+						DeletedRegion:   region(edit.Span, fset),
+						InsertedContent: sarifMessage{Text: edit.NewText},
+					}},
+				})
+			}
+			result.Fixes = append(result.Fixes, sf)
+		}
+
+		if len(d.Notes) > 0 {
+			result.Properties = map[string]interface{}{"notes": d.Notes}
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	data, err := json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+func physicalLocation(span Span, fset *source.FileSet) sarifPhysicalLocation {
+	return sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: fileURI(span.Filename)},
+		Region:           region(span, fset),
+	}
+}
+
+// region returns the SARIF region of span. Its columns are recounted from
+// the source when the file is in fset, since spans expand tabs. Its byte
+// offsets are those of the file as read, with its byte order mark and
+// CRLF line breaks, so they are left out when the file is not in fset.
+func region(span Span, fset *source.FileSet) sarifRegion {
+	r := sarifRegion{
+		StartLine:   span.Start.Line,
+		StartColumn: span.Start.Column,
+		EndLine:     span.End.Line,
+		EndColumn:   span.End.Column,
+	}
+
+	var file *source.File
+	if fset != nil {
+		file = fset.Lookup(span.Filename)
+	}
+	if file != nil {
+		r.StartColumn = codePointColumn(file, span.Start.Line, span.Start.Offset)
+		r.EndColumn = codePointColumn(file, span.End.Line, span.End.Offset)
+
+		offset, end := file.RawOffset(span.Start.Offset), file.RawOffset(span.End.Offset)
+		length := end - offset
+		r.ByteOffset, r.ByteLength = &offset, &length
+	}
+
+	return r
+}
+
+func codePointColumn(file *source.File, line, offset int) int {
+	if line < 1 || line > file.LineCount() || offset > file.Size() {
+		return 1
+	}
+
+	start := file.LineStart(line)
+	if offset < start {
+		return 1
+	}
+
+	return 1 + utf8.RuneCountInString(file.Source()[start:offset])
+}
+
+// fileURI returns the URI of a file name: a relative reference for a
+// relative path, which SARIF resolves against the root of the project,
+// and a file URI for an absolute path.
+func fileURI(filename string) string {
+	u := url.URL{Path: filepath.ToSlash(filename)}
+	if filepath.IsAbs(filename) {
+		u.Scheme = "file"
+	}
+
+	return u.String()
+}
@@ -0,0 +1,525 @@
+package evaluator
+
+import (
+	"strings"
+
+	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/object"
+)
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	// Statements
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, object.NewEnclosedEnvironment(env))
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.VariableStatement:
+		return evalVariableStatement(node, env)
+	case *ast.IfStatement:
+		return evalIfStatement(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.DoWhileStatement:
+		return evalDoWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, object.NewEnclosedEnvironment(env))
+	case *ast.FunctionDeclaration:
+		return evalFunctionDeclaration(node, env)
+	case *ast.ReturnStatement:
+		return evalReturnStatement(node, env)
+
+	// Expressions
+	case *ast.AssignmentExpression:
+		return evalAssignmentExpression(node, env)
+	case *ast.LogicalExpression:
+		return evalLogicalExpression(node, env)
+	case *ast.BinaryExpression:
+		return evalBinaryExpression(node, env)
+	case *ast.UnaryExpression:
+		return evalUnaryExpression(node, env)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.CallExpression:
+		return evalCallExpression(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+
+	// Literals
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInteger{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		parts := evalExpressions(node.Parts, env)
+		if len(parts) == 1 && object.IsError(parts[0]) {
+			return parts[0]
+		}
+		return object.Interpolate(parts)
+	case *ast.BoolLiteral:
+		return object.NativeBool(node.Value)
+	case *ast.NullLiteral:
+		return object.NULL
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && object.IsError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.MapLiteral:
+		return evalMapLiteral(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{
+			Name:       object.AnonymousName,
+			Parameters: node.Parameters,
+			Body:       node.Body,
+			Env:        env,
+		}
+	}
+
+	return object.NewError("cannot evaluate %T", node)
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object = object.NULL
+
+	for _, stmt := range program.Statements {
+		result = Eval(stmt, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = object.NULL
+
+	for _, stmt := range block.Statements {
+		result = Eval(stmt, env)
+
+		if isUnwinding(result) {
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalVariableStatement(vs *ast.VariableStatement, env *object.Environment) object.Object {
+	for _, decl := range vs.Declarations {
+		ident, ok := decl.Identifier.(*ast.Identifier)
+		if !ok {
+			return object.NewError("invalid variable name: %v", decl.Identifier)
+		}
+
+		value := Eval(decl.Initializer, env)
+		if object.IsError(value) {
+			return value
+		}
+
+		env.Define(ident.Name, value)
+	}
+
+	return object.NULL
+}
+
+func evalIfStatement(is *ast.IfStatement, env *object.Environment) object.Object {
+	condition := Eval(is.Condition, env)
+	if object.IsError(condition) {
+		return condition
+	}
+
+	if object.IsTruthy(condition) {
+		return Eval(is.Consequent, env)
+	} else if is.Alternate != nil {
+		return Eval(is.Alternate, env)
+	}
+
+	return object.NULL
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if object.IsError(condition) {
+			return condition
+		}
+		if !object.IsTruthy(condition) {
+			return object.NULL
+		}
+
+		if result := Eval(ws.Body, env); isUnwinding(result) {
+			return result
+		}
+	}
+}
+
+func evalDoWhileStatement(dws *ast.DoWhileStatement, env *object.Environment) object.Object {
+	for {
+		if result := Eval(dws.Body, env); isUnwinding(result) {
+			return result
+		}
+
+		condition := Eval(dws.Condition, env)
+		if object.IsError(condition) {
+			return condition
+		}
+		if !object.IsTruthy(condition) {
+			return object.NULL
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	if fs.Initializer != nil {
+		if init := Eval(fs.Initializer, env); object.IsError(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := Eval(fs.Condition, env)
+			if object.IsError(condition) {
+				return condition
+			}
+			if !object.IsTruthy(condition) {
+				return object.NULL
+			}
+		}
+
+		if result := Eval(fs.Body, env); isUnwinding(result) {
+			return result
+		}
+
+		if fs.Iterator != nil {
+			if iter := Eval(fs.Iterator, env); object.IsError(iter) {
+				return iter
+			}
+		}
+	}
+}
+
+func evalFunctionDeclaration(fd *ast.FunctionDeclaration, env *object.Environment) object.Object {
+	fn := &object.Function{
+		Name:       fd.Name.Name,
+		Parameters: fd.Parameters,
+		Body:       fd.Body,
+		Env:        env,
+	}
+	env.Define(fd.Name.Name, fn)
+
+	return object.NULL
+}
+
+func evalReturnStatement(rs *ast.ReturnStatement, env *object.Environment) object.Object {
+	value := Eval(rs.Value, env)
+	if object.IsError(value) {
+		return value
+	}
+
+	return &object.ReturnValue{Value: value}
+}
+
+func evalAssignmentExpression(ae *ast.AssignmentExpression, env *object.Environment) object.Object {
+	switch left := ae.Left.(type) {
+	case *ast.Identifier:
+		return evalIdentifierAssignment(left, ae, env)
+	case *ast.MemberExpression:
+		return evalMemberAssignment(left, ae, env)
+	default:
+		return object.NewError("cannot assign to %v", ae.Left)
+	}
+}
+
+func evalIdentifierAssignment(ident *ast.Identifier, ae *ast.AssignmentExpression, env *object.Environment) object.Object {
+	value := Eval(ae.Right, env)
+	if object.IsError(value) {
+		return value
+	}
+
+	if ae.Operator != "=" {
+		current := evalIdentifier(ident, env)
+		if object.IsError(current) {
+			return current
+		}
+
+		value = object.BinaryOp(strings.TrimSuffix(ae.Operator, "="), current, value)
+		if object.IsError(value) {
+			return value
+		}
+	}
+
+	if !env.Assign(ident.Name, value) {
+		return object.NewError("identifier not found: %s", ident.Name)
+	}
+
+	return value
+}
+
+// evalMemberAssignment evaluates the object and the index of the target
+// once, so that `xs[next()] += 1` only calls next a single time.
+func evalMemberAssignment(me *ast.MemberExpression, ae *ast.AssignmentExpression, env *object.Environment) object.Object {
+	obj := Eval(me.Object, env)
+	if object.IsError(obj) {
+		return obj
+	}
+
+	var index object.Object
+	if me.Computed {
+		index = Eval(me.Property, env)
+		if object.IsError(index) {
+			return index
+		}
+	} else {
+		prop, ok := me.Property.(*ast.Identifier)
+		if !ok {
+			return object.NewError("invalid property: %v", me.Property)
+		}
+		index = &object.String{Value: prop.Name}
+	}
+
+	var current object.Object
+	if ae.Operator != "=" {
+		if me.Computed {
+			current = object.IndexOp(obj, index)
+		} else {
+			current = object.PropertyOp(obj, index.(*object.String).Value)
+		}
+		if object.IsError(current) {
+			return current
+		}
+	}
+
+	value := Eval(ae.Right, env)
+	if object.IsError(value) {
+		return value
+	}
+
+	if current != nil {
+		value = object.BinaryOp(strings.TrimSuffix(ae.Operator, "="), current, value)
+		if object.IsError(value) {
+			return value
+		}
+	}
+
+	if me.Computed {
+		return object.SetIndexOp(obj, index, value)
+	}
+
+	return object.SetPropertyOp(obj, index.(*object.String).Value, value)
+}
+
+func evalLogicalExpression(le *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := Eval(le.Left, env)
+	if object.IsError(left) {
+		return left
+	}
+
+	switch le.Operator {
+	case "&&":
+		if !object.IsTruthy(left) {
+			return left
+		}
+	case "||":
+		if object.IsTruthy(left) {
+			return left
+		}
+	default:
+		return object.NewError("unknown operator: %s", le.Operator)
+	}
+
+	return Eval(le.Right, env)
+}
+
+func evalBinaryExpression(be *ast.BinaryExpression, env *object.Environment) object.Object {
+	left := Eval(be.Left, env)
+	if object.IsError(left) {
+		return left
+	}
+
+	right := Eval(be.Right, env)
+	if object.IsError(right) {
+		return right
+	}
+
+	return object.BinaryOp(be.Operator, left, right)
+}
+
+func evalUnaryExpression(ue *ast.UnaryExpression, env *object.Environment) object.Object {
+	right := Eval(ue.Right, env)
+	if object.IsError(right) {
+		return right
+	}
+
+	return object.UnaryOp(ue.Operator, right)
+}
+
+func evalMemberExpression(me *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(me.Object, env)
+	if object.IsError(obj) {
+		return obj
+	}
+
+	if !me.Computed {
+		prop, ok := me.Property.(*ast.Identifier)
+		if !ok {
+			return object.NewError("invalid property: %v", me.Property)
+		}
+		return object.PropertyOp(obj, prop.Name)
+	}
+
+	index := Eval(me.Property, env)
+	if object.IsError(index) {
+		return index
+	}
+
+	return object.IndexOp(obj, index)
+}
+
+func evalSliceExpression(se *ast.SliceExpression, env *object.Environment) object.Object {
+	obj := Eval(se.Object, env)
+	if object.IsError(obj) {
+		return obj
+	}
+
+	bounds := []object.Object{object.NULL, object.NULL}
+	for i, bound := range []ast.Expression{se.Low, se.High} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if object.IsError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	return object.SliceOp(obj, bounds[0], bounds[1])
+}
+
+func evalCallExpression(ce *ast.CallExpression, env *object.Environment) object.Object {
+	callee := Eval(ce.Callee, env)
+	if object.IsError(callee) {
+		return callee
+	}
+
+	args := evalExpressions(ce.Arguments, env)
+	if len(args) == 1 && object.IsError(args[0]) {
+		return args[0]
+	}
+
+	return applyFunction(callee, args)
+}
+
+// evalExpressions evaluates exprs from left to right. On error it returns
+// a slice holding only that error.
+func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.Object {
+	result := make([]object.Object, len(exprs))
+
+	for i, expr := range exprs {
+		result[i] = Eval(expr, env)
+		if object.IsError(result[i]) {
+			return []object.Object{result[i]}
+		}
+	}
+
+	return result
+}
+
+// applyFunction calls fn with args. A body made of a single expression
+// returns the value of that expression.
+func applyFunction(callee object.Object, args []object.Object) object.Object {
+	fn, ok := callee.(*object.Function)
+	if !ok {
+		return object.NewError("%s is not callable", callee.Type())
+	}
+
+	if len(args) != len(fn.Parameters) {
+		return object.NewError("wrong number of arguments for %s: want=%d, got=%d", fn.Name, len(fn.Parameters), len(args))
+	}
+
+	env := object.NewEnclosedEnvironment(fn.Env)
+	for i, param := range fn.Parameters {
+		env.Define(param.Name, args[i])
+	}
+
+	result := Eval(fn.Body, env)
+
+	switch result := result.(type) {
+	case *object.ReturnValue:
+		return result.Value
+	case *object.Error:
+		return result
+	}
+
+	if _, ok := fn.Body.(*ast.ExpressionStatement); ok {
+		return result
+	}
+
+	return object.NULL
+}
+
+func evalMapLiteral(ml *ast.MapLiteral, env *object.Environment) object.Object {
+	m := object.NewMap()
+
+	for _, pair := range ml.Pairs {
+		var key object.Object
+		if ident, ok := pair.Key.(*ast.Identifier); ok && !pair.Computed {
+			key = &object.String{Value: ident.Name}
+		} else {
+			key = Eval(pair.Key, env)
+			if object.IsError(key) {
+				return key
+			}
+		}
+
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as map key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if object.IsError(value) {
+			return value
+		}
+
+		m.Set(hashable, value)
+	}
+
+	return m
+}
+
+func evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
+	if value, ok := env.Get(ident.Name); ok {
+		return value
+	}
+
+	return object.NewError("identifier not found: %s", ident.Name)
+}
+
+// isUnwinding reports whether result must stop the evaluation of the
+// enclosing statements, either because of a return or of an error.
+func isUnwinding(result object.Object) bool {
+	if result == nil {
+		return false
+	}
+
+	rt := result.Type()
+	return rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ
+}
@@ -0,0 +1,541 @@
+package evaluator
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jellycat-io/eevee/decimal"
+	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/object"
+	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/test"
+)
+
+func TestEvalLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`42`, int64(42)},
+		{`3.14`, 3.14},
+		{`"eevee"`, "eevee"},
+		{`true`, true},
+		{`false`, false},
+		{`null`, nil},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalBinaryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`2 + 2 * 3`, int64(8)},
+		{`10 - 2 - 3`, int64(5)},
+		{`100 / 10 / 5`, int64(2)},
+		{`(2 + 2) * 3`, int64(12)},
+		{`7 / 2`, int64(3)},
+		{`7 % 2`, int64(1)},
+		{`7 / 2.0`, 3.5},
+		{`-2 + 1.5`, -0.5},
+		{`"eev" + "ee"`, "eevee"},
+		{`1 < 2`, true},
+		{`2 <= 1`, false},
+		{`1 == 1.0`, true},
+		{`"a" is "a"`, true},
+		{`"a" != "b"`, true},
+		{`null == null`, true},
+		{`!true`, false},
+		{`!null`, true},
+		{`not true`, false},
+		{`not 1 == 2`, true},
+		{`1 is not 2`, true},
+		{`null is not null`, false},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalLogicalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`true and false`, false},
+		{`true or false`, true},
+		{`null or "default"`, "default"},
+		{`1 and 2`, int64(2)},
+		{`false && undefined`, false},
+		{`true || undefined`, true},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalVariableStatement(t *testing.T) {
+	input := test.MakeInput(
+		`let x = 40, y`,
+		`y = 2`,
+		`x += y`,
+		`x`,
+	)
+
+	checkObject(t, input, testEval(t, input), int64(42))
+}
+
+func TestEvalBlockScope(t *testing.T) {
+	input := test.MakeInput(
+		`let x = 1`,
+		`if true then`,
+		`	let x = 2`,
+		`	x = 3`,
+		`x`,
+	)
+
+	checkObject(t, input, testEval(t, input), int64(1))
+}
+
+func TestEvalIfStatement(t *testing.T) {
+	input := test.MakeInput(
+		`let level = 16, pokemon`,
+		`if level >= 16 then`,
+		`	pokemon = "ivysaur"`,
+		`else`,
+		`	pokemon = "bulbasaur"`,
+		`pokemon`,
+	)
+
+	checkObject(t, input, testEval(t, input), "ivysaur")
+}
+
+func TestEvalIterationStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			test.MakeInput(
+				`let x = 0`,
+				`while x < 10 do`,
+				`	x += 3`,
+				`x`,
+			),
+			int64(12),
+		},
+		{
+			test.MakeInput(
+				`let x = 10`,
+				`do x += 1 while x < 5`,
+				`x`,
+			),
+			int64(11),
+		},
+		{
+			test.MakeInput(
+				`let sum = 0`,
+				`for let i = 0; i < 5; i += 1 do`,
+				`	sum += i`,
+				`sum`,
+			),
+			int64(10),
+		},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalReturnStatement(t *testing.T) {
+	input := test.MakeInput(
+		`let x = 0`,
+		`while true do`,
+		`	x += 1`,
+		`	if x == 3 then return x * 10`,
+		`x`,
+	)
+
+	checkObject(t, input, testEval(t, input), int64(30))
+}
+
+func TestEvalFunctionDeclaration(t *testing.T) {
+	input := test.MakeInput(
+		`fn square(x) return x * x`,
+		`square`,
+	)
+
+	result := testEval(t, input)
+	fn, ok := result.(*object.Function)
+	if !ok {
+		t.Fatalf("Expected *object.Function, got %T (%+v)", result, result)
+	}
+
+	if fn.Name != "square" || len(fn.Parameters) != 1 || fn.Parameters[0].Name != "x" {
+		t.Fatalf("Wrong function. got %q", fn.Inspect())
+	}
+}
+
+func TestEvalCallExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			test.MakeInput(
+				`fn add(x, y) x + y`,
+				`add(40, 2)`,
+			),
+			int64(42),
+		},
+		{
+			test.MakeInput(
+				`fn fib(n)`,
+				`	if n < 2 then return n`,
+				`	return fib(n - 1) + fib(n - 2)`,
+				`fib(15)`,
+			),
+			int64(610),
+		},
+		{
+			test.MakeInput(
+				`fn nothing()`,
+				`	1`,
+				`nothing()`,
+			),
+			nil,
+		},
+		{
+			test.MakeInput(
+				`fn counter()`,
+				`	let count = 0`,
+				`	fn increment()`,
+				`		count += 1`,
+				`		return count`,
+				`	return increment`,
+				`let next = counter()`,
+				`next()`,
+				`next()`,
+			),
+			int64(2),
+		},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalFunctionLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			test.MakeInput(
+				`let add = fn (a) fn (b) a + b`,
+				`add(40)(2)`,
+			),
+			int64(42),
+		},
+		{
+			test.MakeInput(
+				`let twice = fn (f, x)`,
+				`	return f(f(x))`,
+				`twice(fn (x) x * 3, 2)`,
+			),
+			int64(18),
+		},
+		{
+			test.MakeInput(
+				`fn counter()`,
+				`	let count = [0]`,
+				`	return fn ()`,
+				`		count[0] += 1`,
+				`		return count[0]`,
+				`let next = counter()`,
+				`next()`,
+				`next()`,
+			),
+			int64(2),
+		},
+		{`(fn () return)()`, nil},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalMemberExpression(t *testing.T) {
+	checkObject(t, `"eevee"[1]`, testEval(t, `"eevee"[1]`), "e")
+	checkObject(t, `"eevee"[-1]`, testEval(t, `"eevee"[-1]`), "e")
+}
+
+func TestEvalArrays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[]`, []interface{}{}},
+		{`[1, "eevee", 2 * 3]`, []interface{}{int64(1), "eevee", int64(6)}},
+		{`[1, 2] + [3]`, []interface{}{int64(1), int64(2), int64(3)}},
+		{`[1, [2, 3]] == [1, [2, 3.0]]`, true},
+		{`[1, 2, 3][1]`, int64(2)},
+		{`[1, 2, 3][-1]`, int64(3)},
+		{`[1, 2, 3, 4][1:3]`, []interface{}{int64(2), int64(3)}},
+		{`[1, 2, 3, 4][:-1]`, []interface{}{int64(1), int64(2), int64(3)}},
+		{`[1, 2, 3, 4][2:]`, []interface{}{int64(3), int64(4)}},
+		{`[1, 2][5:]`, []interface{}{}},
+		{`"eevee"[1:3]`, "ev"},
+		{`"eevee"[:]`, "eevee"},
+		{
+			test.MakeInput(
+				`let xs = [1, 2, 3]`,
+				`xs[0] = 10`,
+				`xs[-1] *= 2`,
+				`xs`,
+			),
+			[]interface{}{int64(10), int64(2), int64(6)},
+		},
+		{
+			test.MakeInput(
+				`let i = 0, xs = [1, 1]`,
+				`fn next()`,
+				`	i += 1`,
+				`	return i`,
+				`xs[next()] += 1`,
+				`[xs, i]`,
+			),
+			[]interface{}{[]interface{}{int64(1), int64(2)}, int64(1)},
+		},
+		{
+			test.MakeInput(
+				`let xs = [1], ys = [1]`,
+				`xs[0] = xs`,
+				`ys[0] = ys`,
+				`[xs == xs, xs == ys, xs == [xs]]`,
+			),
+			[]interface{}{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+
+	input := test.MakeInput(
+		`let xs = [1, 2]`,
+		`xs[0] = xs`,
+		`xs[1] = { self: xs }`,
+		`xs[1]["map"] = xs[1]`,
+		`xs`,
+	)
+	if inspect := testEval(t, input).Inspect(); inspect != `[[...], {"self": [...], "map": {...}}]` {
+		t.Errorf("%q - Wrong inspect output, got %q", input, inspect)
+	}
+}
+
+func TestEvalMaps(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{ "name": "eevee", level: 5 }["name"]`, "eevee"},
+		{`{ "name": "eevee", level: 5 }.level`, int64(5)},
+		{`{ [1 + 1]: "two" }[2.0]`, "two"},
+		{`{ level: 5 }.name`, nil},
+		{`{ a: 1, b: [2] } == { b: [2], a: 1.0 }`, true},
+		{
+			test.MakeInput(
+				`let pokemon = {`,
+				`	name: "eevee",`,
+				`	stats: { hp: 55 },`,
+				`}`,
+				`pokemon.stats.hp += 5`,
+				`pokemon["level"] = 5`,
+				`[pokemon.stats.hp, pokemon.level]`,
+			),
+			[]interface{}{int64(60), int64(5)},
+		},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+
+	input := `{ "name": "eevee", level: 5, [1]: [true] }`
+	if inspect := testEval(t, input).Inspect(); inspect != `{"name": "eevee", "level": 5, 1: [true]}` {
+		t.Errorf("%q - Wrong inspect output, got %q", input, inspect)
+	}
+}
+
+func TestEvalExactNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`9223372036854775807 + 1`, bigInteger("9223372036854775808")},
+		{`-9223372036854775807 - 2`, bigInteger("-9223372036854775809")},
+		{`4611686018427387904 * 4`, bigInteger("18446744073709551616")},
+		{`(-9223372036854775807 - 1) / -1`, bigInteger("9223372036854775808")},
+		{`-(-9223372036854775807 - 1)`, bigInteger("9223372036854775808")},
+		{`9223372036854775808 - 1`, int64(9223372036854775807)},
+		{`18446744073709551616 % 10`, int64(6)},
+		{`18446744073709551616 > 9223372036854775807`, true},
+		{`18446744073709551616 == 18446744073709551616.0`, true},
+		{`{ [18446744073709551616]: "big" }[18446744073709551616.0]`, "big"},
+		{`0.1d + 0.2d`, decimalValue("0.3")},
+		{`0.1d + 0.2d == 0.3d`, true},
+		{`12.30d * 3`, decimalValue("36.90")},
+		{`10d / 3`, decimalValue("3.3333333333333333")},
+		{`-2.50d % 1`, decimalValue("-0.50")},
+		{`1.5d == 1.5`, true},
+		{`0.1d < 0.1`, true},
+		{`{ [1.50d]: "x", [2d]: "y" }[1.5] + { [2d]: "y" }[2]`, "xy"},
+		{`"total: {19.99d * 2}"`, "total: 39.98"},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"level {1 + 1}"`, "level 2"},
+		{test.MakeInput(`let name = "eevee"`, `"hi {name}!"`), "hi eevee!"},
+		{`"{[1, "a"]} {null} {true}"`, `[1, "a"] null true`},
+		{test.MakeInput(`let m = {hp: 40}`, `"{m.hp}/{m["hp"] + 10} hp"`), "40/50 hp"},
+		{`"\{literal}"`, "{literal}"},
+		{test.MakeInput(`let x = 1`, `"""{x}"""`), "1"},
+		{test.MakeInput(`let x = 1`, `"""a{x}b"""`), "a1b"},
+		{`'''{"q"}'''`, "q"},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`eevee`, "identifier not found: eevee"},
+		{`eevee = 1`, "identifier not found: eevee"},
+		{`1 / 0`, "division by zero"},
+		{`18446744073709551616 % 0`, "division by zero"},
+		{`1.5d / 0`, "division by zero"},
+		{`1.5d + 1.0`, "unsupported operand types for +: DECIMAL and FLOAT"},
+		{`1 + "eevee"`, "unsupported operand types for +: INTEGER and STRING"},
+		{`-"eevee"`, "unsupported operand type for -: STRING"},
+		{`"eevee"[5]`, "string index out of range: 5"},
+		{`[1, 2][2]`, "array index out of range: 2"},
+		{`[1, 2]["a"]`, "ARRAY is not indexable by STRING"},
+		{`[1, 2][0:"a"]`, "slice bounds must be integers, got STRING"},
+		{`{ [[1]]: 1 }`, "unusable as map key: ARRAY"},
+		{`{}[[1]]`, "unusable as map key: ARRAY"},
+		{`"eevee"[0] = "E"`, "STRING does not support index assignment by INTEGER"},
+		{`"eevee".level`, `STRING has no property "level"`},
+		{`"eevee"()`, "STRING is not callable"},
+		{test.MakeInput(`fn f(x) x`, `f(1, 2)`), "wrong number of arguments for f: want=1, got=2"},
+		{`(fn (x) x)()`, "wrong number of arguments for anonymous: want=1, got=0"},
+	}
+
+	for _, tt := range tests {
+		result := testEval(t, tt.input)
+		err, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("%q - Expected error, got %T (%+v)", tt.input, result, result)
+			continue
+		}
+
+		if err.Message != tt.expected {
+			t.Errorf("%q - Wrong error. Expected %q, got %q", tt.input, tt.expected, err.Message)
+		}
+	}
+}
+
+func bigInteger(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func decimalValue(s string) decimal.Decimal {
+	d, _ := decimal.Parse(s)
+	return d
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	l := lexer.New(test.MakeFile(input))
+	p := parser.New(l, false)
+	program := p.Parse()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q - Parser errors: %q", input, p.Errors())
+	}
+
+	return Eval(program, object.NewEnvironment())
+}
+
+func checkObject(t *testing.T, input string, obj object.Object, expected interface{}) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int64:
+		result, ok := obj.(*object.Integer)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected integer %d, got %q", input, expected, obj.Inspect())
+		}
+	case float64:
+		result, ok := obj.(*object.Float)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected float %v, got %q", input, expected, obj.Inspect())
+		}
+	case *big.Int:
+		result, ok := obj.(*object.BigInteger)
+		if !ok || result.Value.Cmp(expected) != 0 {
+			t.Errorf("%q - Expected big integer %s, got %q", input, expected, obj.Inspect())
+		}
+	case decimal.Decimal:
+		result, ok := obj.(*object.Decimal)
+		if !ok || result.Value.String() != expected.String() {
+			t.Errorf("%q - Expected decimal %s, got %q", input, expected, obj.Inspect())
+		}
+	case string:
+		result, ok := obj.(*object.String)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected string %q, got %q", input, expected, obj.Inspect())
+		}
+	case bool:
+		result, ok := obj.(*object.Bool)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected bool %t, got %q", input, expected, obj.Inspect())
+		}
+	case []interface{}:
+		result, ok := obj.(*object.Array)
+		if !ok || len(result.Elements) != len(expected) {
+			t.Errorf("%q - Expected array of %d elements, got %q", input, len(expected), obj.Inspect())
+			return
+		}
+		for i, e := range expected {
+			checkObject(t, input, result.Elements[i], e)
+		}
+	case nil:
+		if obj != object.NULL {
+			t.Errorf("%q - Expected null, got %q", input, obj.Inspect())
+		}
+	}
+}
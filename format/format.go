@@ -0,0 +1,95 @@
+// Package format prints programs in the canonical style of eevee fmt:
+// blocks indented with one tab, one space around binary operators and
+// after commas, at most one blank line between statements, and only the
+// parentheses that the precedence of the operators requires. Comments are
+// kept, and the blocks in braces that fit on their own lines become
+// indented blocks.
+package format
+
+import (
+	"strings"
+
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/source"
+	"github.com/jellycat-io/eevee/token"
+)
+
+// Style holds the choices of the canonical style that can be configured.
+type Style struct {
+	// WordOperators spells the logical operators `and`, `or` and `not`
+	// rather than `&&`, `||` and `!`.
+	WordOperators bool
+}
+
+// Source returns the source of file in the canonical style, with the line
+// endings and the byte order mark it was read with. The file is read with
+// the lexer flags of mode. A program with syntax errors cannot be printed
+// back, so Source returns its diagnostics and an empty string instead.
+func Source(file *source.File, mode lexer.Mode, style Style) (string, diagnostics.List) {
+	l := lexer.NewWithMode(file, mode|lexer.KeepTrivia)
+	p := parser.New(l, false)
+	program := p.Parse()
+	diags := diagnostics.Combine(l.Diagnostics(), p.Diagnostics())
+	if diags.HasErrors() {
+		return "", diags
+	}
+
+	pr := &printer{
+		style:    style,
+		file:     file,
+		src:      file.Source(),
+		comments: collectComments(l.Tokens, file.TabSize()),
+	}
+	pr.program(program)
+
+	return file.Denormalize(pr.out.String()), diags
+}
+
+// comment is a comment of the source, which the printer writes before the
+// statement that follows it, or at the end of the line it trails.
+type comment struct {
+	text     string
+	line     int
+	column   int  // column of an own-line comment
+	trailing bool // code comes before it on its line
+	prevCode int  // line of the last code before it
+}
+
+// collectComments returns the comments in the trivia of tokens, in source
+// order.
+func collectComments(tokens []token.Token, tabSize int) []comment {
+	var comments []comment
+	line, column, code, prevCode := 1, 1, false, 0
+
+	walk := func(pieces []token.TriviaPiece) {
+		for _, piece := range pieces {
+			switch piece.Kind {
+			case token.NewlineTrivia:
+				line, column, code = line+1, 1, false
+			case token.CommentTrivia:
+				comments = append(comments, comment{
+					text:     strings.TrimRight(piece.Text, " \t"),
+					line:     line,
+					column:   column,
+					trailing: code,
+					prevCode: prevCode,
+				})
+			default:
+				column = source.Advance(column, piece.Text, tabSize)
+			}
+		}
+	}
+
+	for _, tok := range tokens {
+		walk(tok.Trivia.Leading)
+		if tok.Literal != "" {
+			line += strings.Count(tok.Literal, "\n")
+			code, prevCode = true, line
+		}
+		walk(tok.Trivia.Trailing)
+	}
+
+	return comments
+}
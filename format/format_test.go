@@ -0,0 +1,416 @@
+package format
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/source"
+	"github.com/jellycat-io/eevee/test"
+)
+
+func TestSource(t *testing.T) {
+	words := Style{WordOperators: true}
+
+	tests := []struct {
+		input    string
+		style    Style
+		expected string
+	}{
+		{
+			test.MakeInput(
+				`let  a=1,b`,
+				`x+=a*2`,
+				`print( a ,b )`,
+			),
+			Style{},
+			test.MakeInput(
+				`let a = 1, b`,
+				`x += a * 2`,
+				`print(a, b)`,
+			),
+		},
+		{
+			test.MakeInput(
+				`fn evolve(pokemon)`,
+				`    if pokemon.level > 20 then`,
+				`        return {name: "flareon"}`,
+				``,
+				``,
+				``,
+				`    return pokemon`,
+			),
+			Style{},
+			test.MakeInput(
+				`fn evolve(pokemon)`,
+				`	if pokemon.level > 20 then`,
+				`		return { name: "flareon" }`,
+				``,
+				`	return pokemon`,
+			),
+		},
+		{
+			test.MakeInput(
+				`while x do { x -= 1; print(x) }`,
+				`for let i = 0;i<3;i+=1 do {}`,
+				`let f = fn (x) { return x }`,
+				`print(fn (x) { return x })`,
+			),
+			Style{},
+			test.MakeInput(
+				`while x do`,
+				`	x -= 1`,
+				`	print(x)`,
+				`for let i = 0; i < 3; i += 1 do {}`,
+				`let f = fn (x)`,
+				`	return x`,
+				`print(fn (x) { return x })`,
+			),
+		},
+		{
+			test.MakeInput(
+				`if a then { if b then c } else d`,
+				`if a then { return } else b`,
+				`if a then x else if b then y else z`,
+				`do x += 1 while x < 10`,
+			),
+			Style{},
+			test.MakeInput(
+				`if a then`,
+				`	if b then c`,
+				`else d`,
+				`if a then`,
+				`	return`,
+				`else b`,
+				`if a then x else if b then y else z`,
+				`do x += 1 while x < 10`,
+			),
+		},
+		{
+			test.MakeInput(
+				`let a = (1 + 2) * (3 - (4 - 5))`,
+				`let b = ((1 * 2)) + 3`,
+				`x = (y = 2)`,
+				`-(a + b) * -c`,
+				`(fn (x) x)(1)`,
+				`({a: 1}).a`,
+			),
+			Style{},
+			test.MakeInput(
+				`let a = (1 + 2) * (3 - (4 - 5))`,
+				`let b = 1 * 2 + 3`,
+				`x = y = 2`,
+				`-(a + b) * -c`,
+				`(fn (x) x)(1)`,
+				`({ a: 1 }.a)`,
+			),
+		},
+		{
+			test.MakeInput(
+				`a and b or not c`,
+				`not a == b`,
+				`(not a) == b`,
+				`a is b`,
+			),
+			Style{},
+			test.MakeInput(
+				`a && b || !c`,
+				`!(a == b)`,
+				`!a == b`,
+				`a == b`,
+			),
+		},
+		{
+			test.MakeInput(
+				`a && b || !c`,
+				`!(a == b)`,
+				`!a == b`,
+				`(a || b) && c`,
+			),
+			words,
+			test.MakeInput(
+				`a and b or not c`,
+				`not a == b`,
+				`(not a) == b`,
+				`(a or b) and c`,
+			),
+		},
+		{
+			test.MakeInput(
+				`# Pokémon`,
+				``,
+				``,
+				`fn evolve(pokemon)  # evolves`,
+				`    # first`,
+				`    if pokemon.level > 20 then`,
+				`        return pokemon  # trailing`,
+				`        # inner tail`,
+				`    # outer tail`,
+				`let team = [eevee,  # one`,
+				`    flareon]`,
+				`# end`,
+			),
+			Style{},
+			test.MakeInput(
+				`# Pokémon`,
+				``,
+				`fn evolve(pokemon)  # evolves`,
+				`	# first`,
+				`	if pokemon.level > 20 then`,
+				`		return pokemon  # trailing`,
+				`		# inner tail`,
+				`	# outer tail`,
+				`let team = [`,
+				`	eevee,  # one`,
+				`	flareon,`,
+				`]`,
+				`# end`,
+			),
+		},
+		{
+			test.MakeInput(
+				`let stats = {`,
+				`  hp: 65, # hp`,
+				`  # speed`,
+				`  speed: 55, attack: 65 # other`,
+				`}`,
+				`print(stats, # first`,
+				`  [1, 2]) # end`,
+				`let x = f([ # open`,
+				`    1, 2], 3)`,
+			),
+			Style{},
+			test.MakeInput(
+				`let stats = {`,
+				`	hp: 65,  # hp`,
+				`	# speed`,
+				`	speed: 55, attack: 65,  # other`,
+				`}`,
+				`print(`,
+				`	stats,  # first`,
+				`	[1, 2],`,
+				`)  # end`,
+				`let x = f(`,
+				`	[  # open`,
+				`		1, 2,`,
+				`	], 3,`,
+				`)`,
+			),
+		},
+		{
+			test.MakeInput(
+				`if a then`,
+				`    x`,
+				`else if b then  # elif`,
+				`    y`,
+				`else  # else`,
+				`    z`,
+			),
+			Style{},
+			test.MakeInput(
+				`if a then`,
+				`	x`,
+				`else if b then  # elif`,
+				`	y`,
+				`else  # else`,
+				`	z`,
+			),
+		},
+		{
+			"\uFEFFlet x = 1 # one\r\n\r\n\r\nprint(x)",
+			Style{},
+			"\uFEFFlet x = 1  # one\r\n\r\nprint(x)\r\n",
+		},
+		{
+			test.MakeInput(
+				`let a = (fn (x) { return x })`,
+				`let b = (fn (x) { return x }), c = 1`,
+				`return (fn (x) fn (y) { return y })`,
+			),
+			Style{},
+			test.MakeInput(
+				`let a = fn (x)`,
+				`	return x`,
+				`let b = fn (x) { return x }, c = 1`,
+				`return fn (x) fn (y)`,
+				`	return y`,
+			),
+		},
+		{"", Style{}, ""},
+	}
+
+	for i, tt := range tests {
+		file := test.MakeFile(tt.input)
+		formatted, diags := Source(file, 0, tt.style)
+		if len(diags) != 0 {
+			t.Fatalf("Tests[%d] - Unexpected diagnostics: %q", i, diags.Strings())
+		}
+		if formatted != tt.expected {
+			t.Errorf("Tests[%d] - Expected:\n%s\ngot:\n%s", i, tt.expected, formatted)
+			continue
+		}
+
+		if program(t, formatted) != program(t, tt.input) {
+			t.Errorf("Tests[%d] - Formatting changed the program:\n%s", i, formatted)
+		}
+		if again, _ := Source(test.MakeFile(formatted), 0, tt.style); again != formatted {
+			t.Errorf("Tests[%d] - Expected formatting to be stable, got:\n%s", i, again)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	file := source.NewFileSet(4).AddFile("main.eev", []byte("let x = (1 +\n"))
+
+	formatted, diags := Source(file, lexer.StrictIndentation, Style{})
+	if formatted != "" {
+		t.Errorf("Expected no source, got %q", formatted)
+	}
+	if !diags.HasErrors() {
+		t.Errorf("Expected the syntax error, got %q", diags.Strings())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{test.MakeInput(`a`, `b`), test.MakeInput(`a`, `b`), ""},
+		{
+			test.MakeInput(`1`, `2`, `3`, `4`, `5`, `6`, `7`, `8`, `9`, `10`, `11`, `12`),
+			test.MakeInput(`1`, `two`, `3`, `4`, `5`, `6`, `7`, `8`, `9`, `10`, `12`),
+			test.MakeInput(
+				`--- a/main.eev`,
+				`+++ b/main.eev`,
+				`@@ -1,5 +1,5 @@`,
+				` 1`,
+				`-2`,
+				`+two`,
+				` 3`,
+				` 4`,
+				` 5`,
+				`@@ -8,5 +8,4 @@`,
+				` 8`,
+				` 9`,
+				` 10`,
+				`-11`,
+				` 12`,
+			),
+		},
+		{
+			"x",
+			test.MakeInput(`x`, `y`),
+			test.MakeInput(
+				`--- a/main.eev`,
+				`+++ b/main.eev`,
+				`@@ -1 +1,2 @@`,
+				`-x`,
+				`\ No newline at end of file`,
+				`+x`,
+				`+y`,
+			),
+		},
+	}
+
+	for i, tt := range tests {
+		if diff := Diff("main.eev", tt.a, tt.b); diff != tt.expected {
+			t.Errorf("Tests[%d] - Expected:\n%s\ngot:\n%s", i, tt.expected, diff)
+		}
+	}
+}
+
+// program returns the syntax tree of input, as text.
+func program(t *testing.T, input string) string {
+	t.Helper()
+
+	l := lexer.New(test.MakeFile(input))
+	p := parser.New(l, false)
+	program := p.Parse()
+	if diags := append(l.Diagnostics(), p.Diagnostics()...); diags.HasErrors() {
+		t.Fatalf("Unexpected diagnostics: %q", diags.Strings())
+	}
+
+	return program.String()
+}
+
+// TestSourceStable formats the programs of the tests of the other packages
+// twice, and checks that the second pass changes nothing.
+func TestSourceStable(t *testing.T) {
+	inputs := testInputs(t)
+	if len(inputs) == 0 {
+		t.Fatal("Expected programs in the tests of the other packages")
+	}
+
+	for _, input := range inputs {
+		for _, style := range []Style{{}, {WordOperators: true}} {
+			formatted, diags := Source(test.MakeFile(input), 0, style)
+			if diags.HasErrors() {
+				break
+			}
+
+			again, diags := Source(test.MakeFile(formatted), 0, style)
+			if diags.HasErrors() {
+				t.Errorf("%q - Formatted to a program with errors %q:\n%s", input, diags.Strings(), formatted)
+				continue
+			}
+			if again != formatted {
+				t.Errorf("%q - Expected formatting to be stable. Formatted once:\n%s\ntwice:\n%s", input, formatted, again)
+			}
+		}
+	}
+}
+
+// testInputs returns the string literals of the test files of the other
+// packages, and the inputs they build with test.MakeInput.
+func testInputs(t *testing.T) []string {
+	paths, err := filepath.Glob("../*/*_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inputs []string
+	fset := gotoken.NewFileSet()
+	for _, path := range paths {
+		if filepath.Dir(path) == "../format" {
+			continue
+		}
+
+		file, err := goparser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.CallExpr:
+				if sel, ok := n.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "MakeInput" {
+					return true
+				}
+				var lines []string
+				for _, arg := range n.Args {
+					lit, ok := arg.(*ast.BasicLit)
+					if !ok || lit.Kind != gotoken.STRING {
+						return true
+					}
+					line, _ := strconv.Unquote(lit.Value)
+					lines = append(lines, line)
+				}
+				inputs = append(inputs, test.MakeInput(lines...))
+			case *ast.BasicLit:
+				if n.Kind == gotoken.STRING {
+					input, _ := strconv.Unquote(n.Value)
+					inputs = append(inputs, input)
+				}
+			}
+			return true
+		})
+	}
+
+	return inputs
+}
@@ -0,0 +1,752 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/source"
+)
+
+const (
+	lowest  = 0
+	primary = int(parser.CALL)
+)
+
+// wordOperators spells the logical operators with words.
+var wordOperators = map[string]string{
+	"&&": "and",
+	"||": "or",
+	"!":  "not",
+}
+
+type printer struct {
+	style Style
+	file  *source.File
+	src   string
+	out   strings.Builder
+
+	depth       int                  // indentation of the statements printed
+	inline      int                  // blocks in braces being printed on one line
+	brackets    int                  // brackets open in the expression being printed
+	atLineStart bool                 // the last line was ended
+	trailing    *ast.FunctionLiteral // function that ends the statement being printed
+
+	comments []comment
+	next     int // index of the next comment to print
+	line     int // last source line printed
+	codeLine int // last source line of a statement printed
+}
+
+func (p *printer) print(s string) {
+	p.out.WriteString(s)
+	p.atLineStart = false
+}
+
+// startLine starts a line for an item of a block at the given source line,
+// after a blank line if the source has one before it.
+func (p *printer) startLine(line int, first bool) {
+	if !first && line > p.line+1 {
+		p.out.WriteString("\n")
+	}
+	p.out.WriteString(strings.Repeat("\t", p.depth))
+}
+
+// endLine ends the line printed for the source up to line, with the comment
+// that trails that line.
+func (p *printer) endLine(line int) {
+	if line > p.line {
+		p.line = line
+	}
+
+	if p.next < len(p.comments) {
+		c := p.comments[p.next]
+		if c.trailing && c.line == line {
+			p.out.WriteString("  " + c.text)
+			p.next++
+		}
+	}
+
+	p.out.WriteString("\n")
+	p.atLineStart = true
+}
+
+// commentsBefore prints the comments before line on lines of their own,
+// for an item of a block that starts on the line start. It returns whether
+// the block is still without any item.
+func (p *printer) commentsBefore(start, line int, first bool) bool {
+	for p.next < len(p.comments) && p.comments[p.next].line < line {
+		p.comment(start, first)
+		first = false
+	}
+
+	return first
+}
+
+// comment prints the next comment on a line of its own. A comment that
+// comes from inside an item starting on the line start goes before it.
+func (p *printer) comment(start int, first bool) {
+	c := p.comments[p.next]
+	p.next++
+
+	if c.line < start {
+		start = c.line
+	}
+	p.startLine(start, first)
+	p.print(c.text)
+	p.endLine(c.line)
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements)
+	end := len(p.src) + 1
+	p.commentsBefore(end, end, p.out.Len() == 0)
+}
+
+// statements prints the statements of a block on lines of their own.
+func (p *printer) statements(stmts []ast.Statement) {
+	first := true
+
+	for _, stmt := range stmts {
+		first = p.commentsBefore(stmt.Pos().Line, p.hoistLine(stmt), first)
+
+		if block, ok := stmt.(*ast.BlockStatement); ok {
+			p.block(block)
+			continue
+		}
+
+		p.startLine(stmt.Pos().Line, first)
+		first = false
+
+		p.trailing = trailingFunction(stmt)
+		p.statement(stmt)
+		if !p.atLineStart {
+			p.endLine(stmt.End().Line)
+		}
+		if end := stmt.End().Line; end > p.line {
+			p.line = end
+		}
+		if p.line > p.codeLine {
+			p.codeLine = p.line
+		}
+	}
+}
+
+// block prints the statements of an indented block, and the comments that
+// are indented like them after the last one.
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		return
+	}
+
+	p.depth++
+	p.statements(block.Statements)
+
+	column := block.Statements[0].Pos().Column
+	for p.next < len(p.comments) {
+		c := p.comments[p.next]
+		if c.prevCode > p.codeLine || c.column < column {
+			break
+		}
+		p.comment(c.line, false)
+	}
+	p.depth--
+}
+
+// inlineStatements prints the statements of a block in braces on one line.
+func (p *printer) inlineStatements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		if i > 0 {
+			p.print("; ")
+		}
+		p.statement(stmt)
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.ExpressionStatement:
+		p.expressionStatement(s.Expression)
+	case *ast.VariableStatement:
+		p.print("let ")
+		for i, dcl := range s.Declarations {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.expression(dcl.Identifier, lowest)
+			if !isImplicit(dcl.Initializer) {
+				p.print(" = ")
+				p.expression(dcl.Initializer, lowest)
+			}
+		}
+	case *ast.ReturnStatement:
+		p.print("return")
+		if !isImplicit(s.Value) {
+			p.print(" ")
+			p.expression(s.Value, lowest)
+		}
+	case *ast.FunctionDeclaration:
+		p.print("fn " + s.Name.Name)
+		p.parameters(s.Parameters)
+		p.body(s.Body, p.headerLine(s), false)
+	case *ast.IfStatement:
+		p.print("if ")
+		p.expression(s.Condition, lowest)
+		p.print(" then")
+		p.body(s.Consequent, p.headerLine(s), s.Alternate != nil && needsBraces(s.Consequent))
+		if s.Alternate != nil {
+			p.clause("else")
+			p.body(s.Alternate, p.lineOf(s.Consequent.End().Offset, s.Alternate.Pos().Offset, "else"), false)
+		}
+	case *ast.WhileStatement:
+		p.print("while ")
+		p.expression(s.Condition, lowest)
+		p.print(" do")
+		p.body(s.Body, p.headerLine(s), false)
+	case *ast.DoWhileStatement:
+		p.print("do")
+		p.body(s.Body, p.headerLine(s), needsBraces(s.Body))
+		p.clause("while ")
+		p.expression(s.Condition, lowest)
+	case *ast.ForStatement:
+		p.print("for ")
+		if s.Initializer != nil {
+			if init, ok := s.Initializer.(*ast.VariableStatement); ok {
+				p.statement(init)
+			} else {
+				p.expression(s.Initializer.(ast.Expression), lowest)
+			}
+		}
+		p.print(";")
+		if s.Condition != nil {
+			p.print(" ")
+			p.expression(s.Condition, lowest)
+		}
+		p.print(";")
+		if s.Iterator != nil {
+			p.print(" ")
+			p.expression(s.Iterator, lowest)
+		}
+		p.print(" do")
+		p.body(s.Body, p.headerLine(s), false)
+	case *ast.BlockStatement:
+		p.print("{ ")
+		p.inline++
+		p.inlineStatements(s.Statements)
+		p.inline--
+		p.print(" }")
+	}
+}
+
+// expressionStatement prints an expression used as a statement. One that
+// starts with a brace is put in parentheses, as the brace would start a
+// block after a line ending with a parenthesis.
+func (p *printer) expressionStatement(expr ast.Expression) {
+	if startsWithBrace(expr) {
+		p.print("(")
+		p.expression(expr, lowest)
+		p.print(")")
+		return
+	}
+
+	p.expression(expr, lowest)
+}
+
+// body prints the body of a compound statement whose header ends on the
+// source line header. A block is indented on the lines that follow,
+// unless it is printed on one line, and a single statement stays on the
+// line of the header, in braces if braced is true.
+func (p *printer) body(body ast.Statement, header int, braced bool) {
+	block, ok := body.(*ast.BlockStatement)
+	switch {
+	case ok && len(block.Statements) == 0:
+		p.print(" {}")
+	case ok && p.inline == 0:
+		p.endLine(header)
+		p.block(block)
+	case ok || braced:
+		p.print(" ")
+		p.statement(ast.NewBlockStatement(statementsOf(body)))
+	default:
+		p.print(" ")
+		p.statement(body)
+	}
+}
+
+// clause prints the keyword that goes on with a compound statement after a
+// body, on a line of its own after an indented block.
+func (p *printer) clause(keyword string) {
+	if p.atLineStart {
+		p.out.WriteString(strings.Repeat("\t", p.depth))
+		p.print(keyword)
+		return
+	}
+
+	p.print(" " + keyword)
+}
+
+func (p *printer) parameters(params []ast.Identifier) {
+	p.print("(")
+	for i, param := range params {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.print(param.Name)
+	}
+	p.print(")")
+}
+
+// expression prints expr, in parentheses if it binds looser than min.
+func (p *printer) expression(expr ast.Expression, min int) {
+	if precedence := p.precedence(expr); precedence < min {
+		p.print("(")
+		p.brackets++
+		p.expression(expr, lowest)
+		p.brackets--
+		p.print(")")
+		return
+	}
+
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		p.print(e.Name)
+	case *ast.NullLiteral:
+		p.print("null")
+	case *ast.AssignmentExpression:
+		precedence, _ := parser.InfixPrecedence(e.Operator)
+		p.expression(e.Left, precedence+1)
+		p.print(" " + e.Operator + " ")
+		p.expression(e.Right, precedence)
+	case *ast.LogicalExpression:
+		p.infix(e.Operator, e.Left, e.Right)
+	case *ast.BinaryExpression:
+		p.infix(e.Operator, e.Left, e.Right)
+	case *ast.UnaryExpression:
+		op := p.operator(e.Operator)
+		if _, ok := wordOperators[e.Operator]; ok && op != e.Operator {
+			op += " "
+		}
+		p.print(op)
+		p.expression(e.Right, parser.PrefixPrecedence(strings.TrimSpace(op)))
+	case *ast.MemberExpression:
+		p.expression(e.Object, primary)
+		if e.Computed {
+			p.bracketed("[", "]", e.Property)
+		} else {
+			p.print(".")
+			p.expression(e.Property, primary)
+		}
+	case *ast.SliceExpression:
+		p.expression(e.Object, primary)
+		p.print("[")
+		p.brackets++
+		if e.Low != nil {
+			p.expression(e.Low, lowest)
+		}
+		p.print(":")
+		if e.High != nil {
+			p.expression(e.High, lowest)
+		}
+		p.brackets--
+		p.print("]")
+	case *ast.CallExpression:
+		p.expression(e.Callee, primary)
+		if p.commented(e) {
+			p.elementLines(e, "(", ")", nodes(e.Arguments), func(i int) { p.expression(e.Arguments[i], lowest) })
+		} else {
+			p.bracketed("(", ")", e.Arguments...)
+		}
+	case *ast.ArrayLiteral:
+		if p.commented(e) {
+			p.elementLines(e, "[", "]", nodes(e.Elements), func(i int) { p.expression(e.Elements[i], lowest) })
+		} else {
+			p.bracketed("[", "]", e.Elements...)
+		}
+	case *ast.MapLiteral:
+		if p.commented(e) {
+			pairs := make([]ast.Node, len(e.Pairs))
+			for i, pair := range e.Pairs {
+				pairs[i] = pair
+			}
+			p.elementLines(e, "{", "}", pairs, func(i int) { p.mapPair(e.Pairs[i]) })
+		} else {
+			p.mapLiteral(e)
+		}
+	case *ast.FunctionLiteral:
+		p.print("fn ")
+		p.parameters(e.Parameters)
+		p.functionBody(e)
+	default:
+		// Literals are written as in the source, which keeps the spelling
+		// of numbers and the quotes and escapes of strings.
+		p.print(p.src[expr.Pos().Offset:expr.End().Offset])
+	}
+}
+
+// infix prints a left-associative binary operation.
+func (p *printer) infix(op string, left, right ast.Expression) {
+	precedence, _ := parser.InfixPrecedence(op)
+	p.expression(left, precedence)
+	p.print(" " + p.operator(op) + " ")
+	p.expression(right, precedence+1)
+}
+
+// bracketed prints exprs separated by commas between open and close.
+func (p *printer) bracketed(open, close string, exprs ...ast.Expression) {
+	p.print(open)
+	p.brackets++
+	for i, expr := range exprs {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.expression(expr, lowest)
+	}
+	p.brackets--
+	p.print(close)
+}
+
+func (p *printer) mapLiteral(m *ast.MapLiteral) {
+	if len(m.Pairs) == 0 {
+		p.print("{}")
+		return
+	}
+
+	p.print("{ ")
+	p.brackets++
+	for i, pair := range m.Pairs {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.mapPair(pair)
+	}
+	p.brackets--
+	p.print(" }")
+}
+
+func (p *printer) mapPair(pair *ast.MapPair) {
+	if pair.Computed {
+		p.bracketed("[", "]", pair.Key)
+	} else {
+		p.expression(pair.Key, primary)
+	}
+	p.print(": ")
+	p.expression(pair.Value, lowest)
+}
+
+// elementLines prints the elements of the literal expr between open and
+// close on lines of their own, each followed by a comma and the comment
+// that trails it. Elements that share a line in the source share it here
+// too, and the comments on lines of their own stay between them.
+func (p *printer) elementLines(expr ast.Expression, open, close string, elements []ast.Node, element func(int)) {
+	openLine, closeLine := p.literalLines(expr)
+
+	p.print(open)
+	if len(elements) > 0 && elements[0].Pos().Line == openLine {
+		p.out.WriteString("\n")
+	} else {
+		p.endLine(openLine)
+	}
+
+	p.depth++
+	p.brackets++
+	first := true
+	for i, el := range elements {
+		if i > 0 && el.Pos().Line == elements[i-1].End().Line {
+			p.print(" ")
+		} else {
+			first = p.commentsBefore(el.Pos().Line, el.Pos().Line, first)
+			p.startLine(el.Pos().Line, first)
+			first = false
+		}
+		element(i)
+		p.print(",")
+		switch {
+		case i+1 < len(elements) && elements[i+1].Pos().Line == el.End().Line:
+		case el.End().Line == closeLine:
+			// The comment of that line comes after the closing bracket.
+			p.out.WriteString("\n")
+		default:
+			p.endLine(el.End().Line)
+		}
+	}
+	p.commentsBefore(closeLine, closeLine, first)
+	p.brackets--
+	p.depth--
+
+	p.out.WriteString(strings.Repeat("\t", p.depth))
+	p.print(close)
+}
+
+// commented reports whether a comment lies between the brackets of the
+// literal expr, which is then printed with each element on a line of its
+// own to keep the comment on the line of its element. The literals of the
+// blocks printed on one line keep theirs on one line too.
+func (p *printer) commented(expr ast.Expression) bool {
+	if p.inline > 0 {
+		return false
+	}
+
+	open, close := p.literalLines(expr)
+	for _, c := range p.comments[p.next:] {
+		if c.line >= close {
+			break
+		}
+		if c.line >= open {
+			return true
+		}
+	}
+
+	return false
+}
+
+// literalLines returns the source lines of the brackets of an array or map
+// literal, or of the parentheses around the arguments of a call.
+func (p *printer) literalLines(expr ast.Expression) (int, int) {
+	if call, ok := expr.(*ast.CallExpression); ok {
+		return p.lineOf(call.Callee.End().Offset, call.End().Offset, "("), call.End().Line
+	}
+
+	return expr.Pos().Line, expr.End().Line
+}
+
+// functionBody prints the body of a function literal. A block that ends
+// the statement is indented, and the others are printed on one line, in
+// braces.
+func (p *printer) functionBody(fl *ast.FunctionLiteral) {
+	block, ok := fl.Body.(*ast.BlockStatement)
+	if ok && len(block.Statements) > 0 && p.inline == 0 && p.brackets == 0 && fl == p.trailing {
+		p.endLine(p.functionHeaderLine(fl))
+		p.block(block)
+		return
+	}
+
+	p.print(" ")
+	switch body := fl.Body.(type) {
+	case *ast.ExpressionStatement:
+		p.expression(body.Expression, p.bodyPrecedence(body.Expression))
+	case *ast.BlockStatement:
+		if len(body.Statements) == 0 {
+			p.print("{}")
+			return
+		}
+		p.inline++
+		p.statement(body)
+		p.inline--
+	default:
+		p.statement(body)
+	}
+}
+
+// bodyPrecedence returns the precedence an expression body must have: one
+// that starts with a brace is put in parentheses, as it would start a block.
+func (p *printer) bodyPrecedence(expr ast.Expression) int {
+	if startsWithBrace(expr) {
+		return primary + 1
+	}
+
+	return lowest
+}
+
+func (p *printer) operator(op string) string {
+	if word, ok := wordOperators[op]; ok && p.style.WordOperators {
+		return word
+	}
+
+	return op
+}
+
+// precedence returns how tightly expr binds, as printed.
+func (p *printer) precedence(expr ast.Expression) int {
+	switch e := expr.(type) {
+	case *ast.AssignmentExpression:
+		precedence, _ := parser.InfixPrecedence(e.Operator)
+		return precedence
+	case *ast.LogicalExpression:
+		precedence, _ := parser.InfixPrecedence(e.Operator)
+		return precedence
+	case *ast.BinaryExpression:
+		precedence, _ := parser.InfixPrecedence(e.Operator)
+		return precedence
+	case *ast.UnaryExpression:
+		return parser.PrefixPrecedence(p.operator(e.Operator))
+	case *ast.FunctionLiteral:
+		return lowest
+	default:
+		return primary
+	}
+}
+
+// headerLine returns the source line where the header of stmt ends, which
+// is the line its trailing comment is on. The comments before it are
+// printed before the statement.
+func (p *printer) headerLine(stmt ast.Statement) int {
+	switch s := stmt.(type) {
+	case *ast.FunctionDeclaration:
+		return p.lineOf(s.Name.End().Offset, s.Body.Pos().Offset, ")")
+	case *ast.IfStatement:
+		return p.lineOf(s.Condition.End().Offset, s.Consequent.Pos().Offset, "then")
+	case *ast.WhileStatement:
+		return p.lineOf(s.Condition.End().Offset, s.Body.Pos().Offset, "do")
+	case *ast.DoWhileStatement:
+		return s.Pos().Line
+	case *ast.ForStatement:
+		return p.lineOf(s.Pos().Offset, s.Body.Pos().Offset, " do")
+	case *ast.BlockStatement:
+		return s.Pos().Line
+	}
+
+	// A statement ending with a function whose body is an indented block
+	// is printed up to the parameters of the function first.
+	if fl := trailingFunction(stmt); fl != nil {
+		return p.functionHeaderLine(fl)
+	}
+
+	return stmt.End().Line
+}
+
+// hoistLine returns the line that the comments printed before stmt come
+// before: the line its header ends on, or the first line of a literal that
+// keeps its comments on the lines of its elements, if that comes first.
+func (p *printer) hoistLine(stmt ast.Statement) int {
+	line := p.headerLine(stmt)
+
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.BlockStatement:
+			// The statements of a block print their own comments.
+			return
+		case *ast.CallExpression, *ast.ArrayLiteral, *ast.MapLiteral:
+			if expr := n.(ast.Expression); p.commented(expr) {
+				if open, _ := p.literalLines(expr); open < line {
+					line = open
+				}
+			}
+		}
+		for _, child := range ast.Children(node) {
+			visit(child)
+		}
+	}
+	visit(stmt)
+
+	return line
+}
+
+// trailingFunction returns the function literal that stmt ends with when
+// its body is a block that is not empty, or nil. Its body is printed as an
+// indented block, since nothing follows it. Parentheses around it are left
+// out when it is printed, so they do not matter.
+func trailingFunction(stmt ast.Statement) *ast.FunctionLiteral {
+	var node ast.Node = stmt
+	for {
+		switch n := node.(type) {
+		case *ast.VariableStatement:
+			if len(n.Declarations) == 0 {
+				return nil
+			}
+			node = n.Declarations[len(n.Declarations)-1].Initializer
+		case *ast.ExpressionStatement:
+			node = n.Expression
+		case *ast.ReturnStatement:
+			node = n.Value
+		case *ast.FunctionLiteral:
+			if block, ok := n.Body.(*ast.BlockStatement); ok {
+				if len(block.Statements) == 0 {
+					return nil
+				}
+				return n
+			}
+			node = n.Body
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *printer) functionHeaderLine(fl *ast.FunctionLiteral) int {
+	return p.lineOf(fl.Pos().Offset, fl.Body.Pos().Offset, ")")
+}
+
+// lineOf returns the line of the first text between the offsets from and
+// to that is outside comments, or the line of from if there is none.
+func (p *printer) lineOf(from, to int, text string) int {
+	offset := from
+	for offset < to {
+		line := p.src[offset:to]
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+
+		code := line
+		if i := strings.IndexByte(code, '#'); i >= 0 {
+			code = code[:i]
+		}
+		if i := strings.Index(code, text); i >= 0 {
+			return p.file.Position(p.file.Pos(offset + i)).Line
+		}
+		offset += len(line)
+	}
+
+	return p.file.Position(p.file.Pos(from)).Line
+}
+
+// isImplicit reports whether expr is the null that the parser puts in place
+// of a missing initializer or return value.
+func isImplicit(expr ast.Expression) bool {
+	_, ok := expr.(*ast.NullLiteral)
+	return ok && expr.Pos() == expr.End()
+}
+
+// needsBraces reports whether a single statement must be put in braces to
+// be followed by a clause on the same line: a compound statement would
+// take the clause, and a bare return would take it as its value.
+func needsBraces(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.IfStatement, *ast.WhileStatement, *ast.DoWhileStatement, *ast.ForStatement, *ast.FunctionDeclaration:
+		return true
+	case *ast.ReturnStatement:
+		return isImplicit(s.Value)
+	default:
+		return false
+	}
+}
+
+// startsWithBrace reports whether the text of expr starts with a map
+// literal.
+func startsWithBrace(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.MapLiteral:
+		return true
+	case *ast.AssignmentExpression:
+		return startsWithBrace(e.Left)
+	case *ast.LogicalExpression:
+		return startsWithBrace(e.Left)
+	case *ast.BinaryExpression:
+		return startsWithBrace(e.Left)
+	case *ast.MemberExpression:
+		return startsWithBrace(e.Object)
+	case *ast.SliceExpression:
+		return startsWithBrace(e.Object)
+	case *ast.CallExpression:
+		return startsWithBrace(e.Callee)
+	default:
+		return false
+	}
+}
+
+func nodes(exprs []ast.Expression) []ast.Node {
+	nodes := make([]ast.Node, len(exprs))
+	for i, expr := range exprs {
+		nodes[i] = expr
+	}
+
+	return nodes
+}
+
+func statementsOf(stmt ast.Statement) []ast.Statement {
+	if block, ok := stmt.(*ast.BlockStatement); ok {
+		return block.Statements
+	}
+
+	return []ast.Statement{stmt}
+}
@@ -0,0 +1,115 @@
+package lexer
+
+import (
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/token"
+)
+
+// bracket is an opening bracket whose closing bracket has not been read
+// yet.
+type bracket struct {
+	token.Token
+	block  bool // a brace that opens a block rather than a map literal
+	params bool // a parenthesis around the parameters of a function
+	depth  int  // number of indentation levels when it was opened
+}
+
+var openingBrackets = map[token.TokenType]token.TokenType{
+	token.RPAREN:   token.LPAREN,
+	token.RBRACKET: token.LBRACKET,
+	token.RBRACE:   token.LBRACE,
+}
+
+var closingBrackets = map[token.TokenType]token.TokenType{
+	token.LPAREN:   token.RPAREN,
+	token.LBRACKET: token.RBRACKET,
+	token.LBRACE:   token.RBRACE,
+}
+
+// joinsLines reports whether the innermost open bracket joins the lines
+// inside it into one. Parentheses, square brackets and the braces of map
+// literals do, while the lines of a block in braces end its statements.
+func (l *Lexer) joinsLines() bool {
+	n := len(l.brackets)
+	return n > 0 && !l.brackets[n-1].block
+}
+
+// freeIndent reports whether the innermost open bracket is a brace around
+// a block and the indented blocks opened inside it are closed. The brace
+// delimits its block, so its lines may then be indented at any level.
+func (l *Lexer) freeIndent() bool {
+	n := len(l.brackets)
+	return n > 0 && l.brackets[n-1].block && len(l.indentStack) <= l.brackets[n-1].depth
+}
+
+// matchBracket keeps track of the brackets opened and closed by tok, the
+// last token added. A closing bracket that does not match the innermost
+// open bracket is reported with it. When it matches an outer one, the
+// brackets opened since are dropped with the error, otherwise the closing
+// bracket is ignored.
+func (l *Lexer) matchBracket(tok token.Token) {
+	if _, ok := closingBrackets[tok.Type]; ok {
+		l.brackets = append(l.brackets, bracket{
+			Token:  tok,
+			block:  tok.Type == token.LBRACE && l.opensBlock(),
+			params: tok.Type == token.LPAREN && l.opensParams(),
+			depth:  len(l.indentStack),
+		})
+		return
+	}
+
+	opening, ok := openingBrackets[tok.Type]
+	if !ok {
+		return
+	}
+
+	if len(l.brackets) == 0 {
+		l.report(diagnostics.Errorf(diagnostics.UnmatchedBracket, l.tokenSpan(tok), "Unmatched %q", tok.Literal))
+		return
+	}
+
+	innermost := l.brackets[len(l.brackets)-1]
+	if innermost.Type == opening {
+		l.brackets = l.brackets[:len(l.brackets)-1]
+		l.params = innermost.params
+		return
+	}
+
+	l.report(diagnostics.Errorf(diagnostics.UnmatchedBracket, l.tokenSpan(tok),
+		"Expected %q to close %q, but got %q", closingBrackets[innermost.Type], innermost.Literal, tok.Literal).
+		WithLabel(l.tokenSpan(innermost.Token), "unclosed "+innermost.Literal))
+
+	for i := len(l.brackets) - 2; i >= 0; i-- {
+		if l.brackets[i].Type == opening {
+			l.brackets = l.brackets[:i]
+			return
+		}
+	}
+}
+
+// opensBlock reports whether the brace just added opens the body of a
+// compound statement or of a function. The body follows the header on the
+// same line or on the next one. A closing parenthesis only ends a header
+// when it closes the parameters of a function, since a map literal can
+// follow a call on the next line.
+func (l *Lexer) opensBlock() bool {
+	i := 1
+	if l.recent[i] == token.EOL {
+		i--
+	}
+
+	switch l.recent[i] {
+	case token.THEN, token.ELSE, token.DO:
+		return true
+	case token.RPAREN:
+		return l.params
+	default:
+		return false
+	}
+}
+
+// opensParams reports whether the parenthesis just added opens the
+// parameters of a function, after fn or after fn and its name.
+func (l *Lexer) opensParams() bool {
+	return l.recent[1] == token.FUNCTION || l.recent[1] == token.IDENT && l.recent[0] == token.FUNCTION
+}
@@ -1,199 +1,599 @@
 package lexer
 
 import (
+	"bufio"
 	"fmt"
-	"regexp"
+	"io"
+	"strconv"
 	"strings"
+	"unicode"
 
-	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/source"
 	"github.com/jellycat-io/eevee/token"
 )
 
+// Mode is a set of flags that change how a file is read.
+type Mode uint
+
+const (
+	// StrictIndentation requires all the indented lines of a file to use
+	// the same character, tabs or spaces, and makes mixed indentation an
+	// error rather than a warning.
+	StrictIndentation Mode = 1 << iota
+	// KeepTrivia attaches the whitespace and comments around each token to
+	// it, so that the tokens hold every byte of the source.
+	KeepTrivia
+)
+
+// Lexer reads the tokens of a source one line at a time. It either reads
+// a whole file registered in a source.FileSet, whose tokens are all in
+// Tokens, or streams a reader through NextToken, in which case Tokens only
+// holds the tokens scanned and not returned yet.
 type Lexer struct {
-	source      string
+	file        *source.File // nil when reading a stream
+	filename    string
 	tabSize     int
+	mode        Mode
 	Tokens      []token.Token
-	indentStack []int
-	patterns    []struct {
-		regex   *regexp.Regexp
-		tokType token.TokenType
-	}
-}
-
-func New(source string, tabSize int) *Lexer {
-	patternList := []struct {
-		pattern string
-		tokType token.TokenType
-	}{
-		/****************************************
-		 * Whitespaces
-		 ***************************************/
-		{`^[ \t]+`, token.WHITESPACE},
-		/****************************************
-		 * Comments
-		 ***************************************/
-		{`^#[^\n]*`, token.COMMENT},
-		/****************************************
-		 * Comparison operators
-		 ***************************************/
-		{`^==`, token.EQ},
-		{`^!=`, token.NOT_EQ},
-		{`^<=`, token.LT_EQ},
-		{`^>=`, token.GT_EQ},
-		{`^<`, token.LT},
-		{`^>`, token.GT},
-		/****************************************
-		 * Logical operators
-		 ***************************************/
-		{`^&&`, token.AND},
-		{`^\|\|`, token.OR},
-		{`^!`, token.BANG},
-		/****************************************
-		 * Symbols, delimiters
-		 ***************************************/
-		{`^;`, token.SEMI},
-		{`^,`, token.COMMA},
-		{`^\.`, token.DOT},
-		{`^:`, token.COLON},
-		{`^\(`, token.LPAREN},
-		{`^\)`, token.RPAREN},
-		{`^{`, token.LBRACE},
-		{`^}`, token.RBRACE},
-		{`^\[`, token.LBRACKET},
-		{`^]`, token.RBRACKET},
-		/****************************************
-		 * Identifiers
-		 ***************************************/
-		{`^[a-zA-Z_][a-zA-Z0-9_]*`, token.IDENT},
-		/****************************************
-		 * Assignment operators
-		 ***************************************/
-		{`^=`, token.ASSIGN},
-		{`^\+=`, token.PLUS_ASSIGN},
-		{`^-=`, token.MINUS_ASSIGN},
-		{`^\*=`, token.STAR_ASSIGN},
-		{`^/=`, token.SLASH_ASSIGN},
-		{`^%=`, token.PERCENT_ASSIGN},
-		/****************************************
-		 * Math operators
-		 ***************************************/
-		{`^\+`, token.PLUS},
-		{`^-`, token.MINUS},
-		{`^\*`, token.STAR},
-		{`^/`, token.SLASH},
-		{`^%`, token.PERCENT},
-		/****************************************
-		 * Literals
-		 ***************************************/
-		{`^\d+\.\d+`, token.FLOAT},
-		{`^\d+`, token.INT},
-		{`^\".*?\"`, token.STRING},
-	}
-
-	compiledPatterns := make([]struct {
-		regex   *regexp.Regexp
-		tokType token.TokenType
-	}, len(patternList))
-
-	for i, pat := range patternList {
-		compiled, err := regexp.Compile(pat.pattern)
-		if err != nil {
-			fmt.Printf(color.InRed("Failed to compile regex pattern %q: %v\n"), pat.pattern, err)
-			continue
-		}
-		compiledPatterns[i].regex = compiled
-		compiledPatterns[i].tokType = pat.tokType
+	indentStack []int            // indentation levels of the open blocks, in columns
+	indentStyle diagnostics.Span // indentation of the first indented line
+	indentChars string           // what the first indented line is indented with
+	brackets    []bracket        // brackets opened and not closed yet
+	diagnostics diagnostics.List
+
+	reader *bufio.Reader // nil once the whole source is in source
+	err    error         // error that stopped the reader, other than io.EOF
+	bom    bool          // the stream started with a byte order mark
+	crs    []int         // offsets of the CRLF line breaks of the stream, kept with trivia
+
+	// source holds the lines being scanned, starting at offset base of the
+	// whole source. The offsets below are relative to it.
+	source    string
+	base      int
+	pos       int // offset of the next byte to scan
+	line      int // line of the byte at pos
+	lineStart int // offset of the first byte of line
+
+	next    int                // index in Tokens of the token NextToken returns
+	lastEnd int                // offset after the last token added, in the whole source
+	recent  [3]token.TokenType // types of the last tokens scanned
+	joined  bool               // the current line continues the previous one
+	params  bool               // the last parenthesis closed held the parameters of a function
+	eof     token.Token        // the EOF token, once scanned
+	done    bool
+}
+
+// New tokenizes a file registered in a source.FileSet. Token columns
+// count runes and expand tabs to the tab size of the file set.
+func New(file *source.File) *Lexer {
+	return NewWithMode(file, 0)
+}
+
+// NewWithMode tokenizes a file like New, with the flags of mode.
+func NewWithMode(file *source.File, mode Mode) *Lexer {
+	l := newLexer(file.Name(), file.TabSize(), mode)
+	l.file = file
+	l.source = file.Source()
+	l.Tokens = make([]token.Token, 0, file.Size()/4)
+
+	for !l.done {
+		l.scanLine()
 	}
 
-	l := &Lexer{
-		source:      source,
-		indentStack: []int{0},
-		tabSize:     tabSize,
-		patterns:    compiledPatterns,
+	return l
+}
+
+// NewReader returns a lexer that reads the source from r as NextToken
+// needs it, so that only the lines being scanned are kept in memory. The
+// positions of its tokens are computed as in a file named filename with
+// the given tab size, or source.DefaultTabSize if it is not positive.
+func NewReader(filename string, r io.Reader, tabSize int, mode Mode) *Lexer {
+	if tabSize <= 0 {
+		tabSize = source.DefaultTabSize
 	}
 
-	l.tokenize()
+	l := newLexer(filename, tabSize, mode)
+	l.reader = bufio.NewReader(r)
 
 	return l
 }
 
-func (l *Lexer) tokenize() {
-	lines := strings.Split(l.source, "\n")
-	for lineNum, line := range lines {
-		lineNum++
-		column := 1
-		indentLevel := len(line) - len(strings.TrimSpace(line))
-		indentString := line[:indentLevel]
+func newLexer(filename string, tabSize int, mode Mode) *Lexer {
+	return &Lexer{
+		filename:    filename,
+		tabSize:     tabSize,
+		mode:        mode,
+		indentStack: []int{0},
+		line:        1,
+	}
+}
+
+// File returns the file the tokens were read from, or nil for a lexer
+// that reads a stream.
+func (l *Lexer) File() *source.File {
+	return l.file
+}
+
+// Denormalize returns text, the source the tokens were read from, with the
+// byte order mark and the CRLF line breaks it was read with, as
+// source.File.Denormalize does. A lexer that reads a stream only records
+// the CRLF line breaks in KeepTrivia mode.
+func (l *Lexer) Denormalize(text string) string {
+	if l.file != nil {
+		return l.file.Denormalize(text)
+	}
+
+	return source.Denormalize(text, l.bom, l.crs)
+}
+
+// Filename returns the name of the source in the positions of the
+// diagnostics.
+func (l *Lexer) Filename() string {
+	return l.filename
+}
+
+// Err returns the error that stopped reading the stream, if any. The
+// tokens end as if the source ended there.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Diagnostics returns the problems found in the source, in the order
+// they were found. When streaming, they only cover the tokens read so far.
+func (l *Lexer) Diagnostics() diagnostics.List {
+	return l.diagnostics
+}
+
+// Errors returns the diagnostics as text.
+func (l *Lexer) Errors() []string {
+	return l.diagnostics.Strings()
+}
+
+// NextToken returns the next token of the source, scanning the next lines
+// when needed. Once the source is over, it keeps returning the EOF token.
+func (l *Lexer) NextToken() token.Token {
+	// The trailing trivia of a token is only known once the next token is
+	// scanned.
+	pending := 1
+	if l.mode&KeepTrivia != 0 {
+		pending = 2
+	}
+
+	for len(l.Tokens)-l.next < pending && !l.done {
+		l.scanLine()
+	}
+	if l.next == len(l.Tokens) {
+		return l.eof
+	}
+
+	l.next++
+
+	return l.Tokens[l.next-1]
+}
+
+// scanLine scans the tokens of the current line. A line inside brackets,
+// or after a line ending with a backslash, continues the logical line
+// before it: no EOL ends that one, and its indentation is ignored.
+func (l *Lexer) scanLine() {
+	l.discard()
+	if l.lineStart == len(l.source) {
+		l.fill()
+	}
+
+	line := l.source[l.lineStart:l.lineEnd()]
+	leading := line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+
+	column := l.advance(1, leading)
+	if !l.joined {
+		l.indent(line, leading)
+	}
+
+	l.pos += len(leading)
+	column, continued := l.tokenizeLine(column)
+
+	end := l.lineEnd()
+	if end == len(l.source) { // Skip adding EOL token for the last line
+		l.finish()
+		return
+	}
+
+	l.joined = continued || l.joinsLines()
+	if !l.joined {
+		l.addToken(token.EOL, "", l.line, column, l.pos)
+	}
+	l.newLine(end + 1)
+}
+
+// finish reports the brackets left open and closes the blocks at the end
+// of the source. They close on the empty line after a final line ending,
+// or past the last line otherwise.
+func (l *Lexer) finish() {
+	for _, b := range l.brackets {
+		l.report(diagnostics.Errorf(diagnostics.UnclosedBracket, l.tokenSpan(b.Token), "Unclosed %q", b.Literal))
+	}
 
-		if indentLevel == l.indentStack[len(l.indentStack)-1] {
-			column += len(indentString)
+	line := l.line + 1
+	if l.lineStart == len(l.source) {
+		line = l.line
+	}
+	for range l.indentStack[1:] {
+		l.addToken(token.DEDENT, "", line, 1, len(l.source))
+	}
+
+	l.addToken(token.EOF, "", l.line+1, 1, len(l.source))
+	l.eof = l.Tokens[len(l.Tokens)-1]
+	l.done = true
+}
+
+// fill reads the next line of the stream into source, and reports whether
+// there was one. Its line ending is normalized as in a source.File.
+func (l *Lexer) fill() bool {
+	if l.reader == nil {
+		return false
+	}
+
+	text, err := l.reader.ReadString('\n')
+	if l.base == 0 && len(l.source) == 0 && strings.HasPrefix(text, source.BOM) {
+		text = text[len(source.BOM):]
+		l.bom = true
+	}
+	if strings.HasSuffix(text, "\r\n") {
+		text = text[:len(text)-2] + "\n"
+		if l.mode&KeepTrivia != 0 {
+			l.crs = append(l.crs, l.base+len(l.source)+len(text)-1)
 		}
+	}
+	l.source += text
 
-		for indentLevel < l.indentStack[len(l.indentStack)-1] {
-			l.Tokens = append(l.Tokens, token.NewToken(token.DEDENT, "", lineNum, column))
-			l.indentStack = l.indentStack[:len(l.indentStack)-1]
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
 		}
+		l.reader = nil
+	}
+
+	return text != ""
+}
 
-		if indentLevel > l.indentStack[len(l.indentStack)-1] {
-			l.Tokens = append(l.Tokens, token.NewToken(token.INDENT, "", lineNum, column))
-			l.indentStack = append(l.indentStack, indentLevel)
-			for _, char := range indentString {
-				if char == '\t' {
-					spacesNeeded := l.tabSize - ((column - 1) % l.tabSize)
-					column += spacesNeeded
-				} else if char == ' ' {
-					column++
-				}
+// discard drops the lines of a stream that were scanned already, and the
+// tokens that were returned.
+func (l *Lexer) discard() {
+	if l.file != nil {
+		return
+	}
+
+	l.Tokens = append(l.Tokens[:0], l.Tokens[l.next:]...)
+	l.next = 0
+
+	// Trivia starts at the end of the last token.
+	cut := l.lineStart
+	if l.mode&KeepTrivia != 0 && l.lastEnd-l.base < cut {
+		cut = l.lastEnd - l.base
+	}
+	if cut == 0 {
+		return
+	}
+
+	l.source = strings.Clone(l.source[cut:])
+	l.base += cut
+	l.pos -= cut
+	l.lineStart -= cut
+}
+
+// indent emits the INDENT and DEDENT tokens that open the current line,
+// whose indentation is leading, at its first column. The level of a line
+// is the number of columns before its first token, with tabs expanded to
+// the tab size of the file, so a tab and as many spaces are the same
+// level. Blank lines and lines holding only a comment keep the level.
+func (l *Lexer) indent(line, leading string) {
+	if rest := line[len(leading):]; rest == "" || rest[0] == '#' {
+		return
+	}
+	l.checkIndentStyle(leading)
+
+	level := l.advance(1, leading) - 1
+	stack := l.indentStack
+	top := len(stack) - 1
+	dedented := false
+
+	for level < l.indentStack[top] {
+		l.addToken(token.DEDENT, "", l.line, 1, l.lineStart)
+		l.indentStack = l.indentStack[:top]
+		top--
+		dedented = true
+	}
+
+	if level > l.indentStack[top] {
+		// A line cannot open a block when it closes another: its level is
+		// between two levels of the stack, unless a brace delimits the
+		// block, as when its closing brace is less indented than its lines.
+		if dedented {
+			if l.freeIndent() {
+				return
 			}
+			l.report(diagnostics.Errorf(diagnostics.InconsistentIndentation, l.indentSpan(leading),
+				"Unindent does not match any outer indentation level").
+				WithNote(fmt.Sprintf("The enclosing blocks are indented by %s columns", levels(stack))))
+			return
 		}
 
-		column = l.tokenizeLine(line, lineNum, column)
+		l.addToken(token.INDENT, "", l.line, 1, l.lineStart)
+		l.indentStack = append(l.indentStack, level)
+	}
+}
+
+// checkIndentStyle reports the indentation that mixes tabs and spaces. In
+// strict mode, it also reports the lines not indented like the first
+// indented line of the file.
+func (l *Lexer) checkIndentStyle(leading string) {
+	if leading == "" {
+		return
+	}
 
-		if lineNum != len(lines) { // Skip adding EOL token for the last line
-			l.Tokens = append(l.Tokens, token.NewToken(token.EOL, "", lineNum, column))
+	strict := l.mode&StrictIndentation != 0
+	tabs, spaces := strings.Contains(leading, "\t"), strings.Contains(leading, " ")
+
+	if tabs && spaces {
+		d := diagnostics.Warningf(diagnostics.MixedIndentation, l.indentSpan(leading), "Indentation mixes tabs and spaces")
+		if strict {
+			d.Severity = diagnostics.Error
 		}
+		l.report(d)
+		return
 	}
 
-	for range l.indentStack[1:] {
-		l.Tokens = append(l.Tokens, token.NewToken(token.DEDENT, "", len(lines)+1, 1))
+	if !strict {
+		return
+	}
+	if l.indentChars == "" {
+		l.indentStyle, l.indentChars = l.indentSpan(leading), indentChars(leading)
+		return
 	}
 
-	l.Tokens = append(l.Tokens, token.NewToken(token.EOF, "", len(lines)+1, 1))
+	style, fileStyle := indentChars(leading), l.indentChars
+	if style != fileStyle {
+		l.report(diagnostics.Errorf(diagnostics.MixedIndentation, l.indentSpan(leading),
+			"Indentation uses %s, but the file is indented with %s", style, fileStyle).
+			WithLabel(l.indentStyle, "the first indented line uses "+fileStyle))
+	}
+}
+
+// indentSpan returns the span of leading, the indentation of the current
+// line.
+func (l *Lexer) indentSpan(leading string) diagnostics.Span {
+	return diagnostics.Span{
+		Filename: l.filename,
+		Start:    token.Position{Line: l.line, Column: 1, Offset: l.base + l.lineStart},
+		End:      token.Position{Line: l.line, Column: l.advance(1, leading), Offset: l.base + l.lineStart + len(leading)},
+	}
 }
 
-func (l *Lexer) tokenizeLine(line string, lineNum, column int) int {
-	line = strings.TrimSpace(line)
+// indentChars names the character that leading is made of.
+func indentChars(leading string) string {
+	if leading[0] == '\t' {
+		return "tabs"
+	}
 
-	for line != "" {
-		matched := false
-		for _, pattern := range l.patterns {
-			loc := pattern.regex.FindStringIndex(line)
-			if loc != nil {
-				lexeme := line[loc[0]:loc[1]]
+	return "spaces"
+}
 
-				tokenType := pattern.tokType
-				if tokenType == token.IDENT {
-					tokenType = lookupIdent(lexeme)
-				}
+// levels lists the indentation levels of stack for a message, as in
+// "0, 4 or 8".
+func levels(stack []int) string {
+	words := make([]string, len(stack))
+	for i, level := range stack {
+		words[i] = strconv.Itoa(level)
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
 
-				if tokenType != token.WHITESPACE && tokenType != token.COMMENT {
-					l.Tokens = append(l.Tokens, token.NewToken(tokenType, lexeme, lineNum, column))
-				}
-				line = line[loc[1]:]
-				column += loc[1]
-				matched = true
-				break
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+// tokenizeLine scans the tokens from pos to the end of the line, starting
+// at column, and returns the column after the last one. A multi-line
+// string moves the scan to the line it ends on. It also reports whether
+// the line ends with a backslash, which continues it on the next line.
+func (l *Lexer) tokenizeLine(column int) (int, bool) {
+	end := l.contentEnd()
+
+	for l.pos < end {
+		start, line := l.pos, l.line
+
+		if l.source[l.pos] == '\\' && l.pos+1 == end {
+			l.pos++
+			return column, true
+		}
+
+		if isQuote(l.source[l.pos]) {
+			l.scanString(column)
+		} else {
+			tokenType, length := scan(l.source[l.pos:end])
+			lexeme := l.source[l.pos : l.pos+length]
+
+			switch tokenType {
+			case token.IDENT:
+				tokenType = lookupIdent(lexeme)
+			case token.INT, token.FLOAT, token.DECIMAL:
+				l.checkNumber(lexeme, anchor{pos: start, line: l.line, column: column})
+			}
+
+			if tokenType != token.WHITESPACE && tokenType != token.COMMENT {
+				l.addToken(tokenType, lexeme, l.line, column, start)
+				l.matchBracket(l.Tokens[len(l.Tokens)-1])
 			}
+			l.pos += length
 		}
 
-		if !matched {
-			l.Tokens = append(l.Tokens, token.NewToken(token.ILLEGAL, line[0:1], lineNum, column))
-			line = line[1:]
-			column++
+		if l.line != line {
+			column = l.advance(1, l.source[l.lineStart:l.pos])
+			end = l.contentEnd()
+		} else {
+			column = l.advance(column, l.source[start:l.pos])
 		}
 	}
 
-	return column
+	return column, false
+}
+
+// lineEnd returns the offset of the newline that ends the current line,
+// or the length of the source on the last line.
+func (l *Lexer) lineEnd() int {
+	if i := strings.IndexByte(l.source[l.pos:], '\n'); i >= 0 {
+		return l.pos + i
+	}
+
+	return len(l.source)
+}
+
+// contentEnd returns the offset where the current line ends, ignoring
+// its trailing whitespace.
+func (l *Lexer) contentEnd() int {
+	return l.pos + len(strings.TrimRightFunc(l.source[l.pos:l.lineEnd()], unicode.IsSpace))
+}
+
+// newLine moves the scan to the line starting at offset.
+func (l *Lexer) newLine(offset int) {
+	l.pos = offset
+	l.line++
+	l.lineStart = offset
+}
+
+// addToken adds a token that starts at offset in source.
+func (l *Lexer) addToken(tokenType token.TokenType, literal string, line, column, offset int) {
+	tok := token.NewToken(tokenType, literal, line, column)
+	tok.Offset = l.base + offset
+	if l.mode&KeepTrivia != 0 {
+		l.attachTrivia(&tok)
+	}
+	l.lastEnd = tok.Offset + len(literal)
+	l.Tokens = append(l.Tokens, tok)
+	l.recent = [3]token.TokenType{l.recent[1], l.recent[2], tokenType}
+}
+
+// advance returns the column reached after text when it starts at column.
+func (l *Lexer) advance(column int, text string) int {
+	return source.Advance(column, text, l.tabSize)
+}
+
+// span returns the span of the bytes from start to end, which come after
+// the anchor a.
+func (l *Lexer) span(a anchor, start, end int) diagnostics.Span {
+	startLine, startColumn := l.positionOf(start, a)
+	endLine, endColumn := l.positionOf(end, a)
+
+	return diagnostics.Span{
+		Filename: l.filename,
+		Start:    token.Position{Line: startLine, Column: startColumn, Offset: l.base + start},
+		End:      token.Position{Line: endLine, Column: endColumn, Offset: l.base + end},
+	}
+}
+
+func (l *Lexer) tokenSpan(tok token.Token) diagnostics.Span {
+	return diagnostics.Span{Filename: l.filename, Start: tok.Pos(), End: tok.End()}
+}
+
+func (l *Lexer) report(d diagnostics.Diagnostic) {
+	l.diagnostics = append(l.diagnostics, d)
+}
+
+// scan reads the token at the start of src, which is never empty, and
+// returns its type and its length in bytes. Anything that does not start
+// a token is a one byte ILLEGAL token.
+func scan(src string) (token.TokenType, int) {
+	c := src[0]
+
+	switch {
+	case c == ' ' || c == '\t':
+		return token.WHITESPACE, 1 + skip(src[1:], isBlank)
+	case c == '#':
+		return token.COMMENT, len(src)
+	case isLetter(c):
+		return token.IDENT, 1 + skip(src[1:], isIdentChar)
+	case isDigit(c), c == '.' && len(src) > 1 && isDigit(src[1]):
+		return scanNumber(src)
+	}
+
+	if len(src) > 1 {
+		if tokenType, ok := doubleCharTokens[src[:2]]; ok {
+			return tokenType, 2
+		}
+	}
+
+	if tokenType, ok := singleCharTokens[c]; ok {
+		return tokenType, 1
+	}
+
+	return token.ILLEGAL, 1
+}
+
+// skip returns the length of the longest prefix of src whose bytes all
+// satisfy accept.
+func skip(src string, accept func(byte) bool) int {
+	n := 0
+	for n < len(src) && accept(src[n]) {
+		n++
+	}
+
+	return n
+}
+
+var doubleCharTokens = map[string]token.TokenType{
+	"==": token.EQ,
+	"!=": token.NOT_EQ,
+	"<=": token.LT_EQ,
+	">=": token.GT_EQ,
+	"&&": token.AND,
+	"||": token.OR,
+	"+=": token.PLUS_ASSIGN,
+	"-=": token.MINUS_ASSIGN,
+	"*=": token.STAR_ASSIGN,
+	"/=": token.SLASH_ASSIGN,
+	"%=": token.PERCENT_ASSIGN,
+}
+
+var singleCharTokens = map[byte]token.TokenType{
+	'<': token.LT,
+	'>': token.GT,
+	'!': token.BANG,
+	';': token.SEMI,
+	',': token.COMMA,
+	'.': token.DOT,
+	':': token.COLON,
+	'(': token.LPAREN,
+	')': token.RPAREN,
+	'{': token.LBRACE,
+	'}': token.RBRACE,
+	'[': token.LBRACKET,
+	']': token.RBRACKET,
+	'=': token.ASSIGN,
+	'+': token.PLUS,
+	'-': token.MINUS,
+	'*': token.STAR,
+	'/': token.SLASH,
+	'%': token.PERCENT,
+}
+
+func isQuote(c byte) bool {
+	return c == '"' || c == '\'' || c == '`'
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return isLetter(c) || isDigit(c)
 }
 
 func lookupIdent(ident string) token.TokenType {
@@ -1,8 +1,14 @@
 package lexer
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/source"
 	"github.com/jellycat-io/eevee/test"
 	"github.com/jellycat-io/eevee/token"
 )
@@ -37,11 +43,1071 @@ func TestTokenizer(t *testing.T) {
 		token.NewToken(token.EOF, "", 9, 1),
 	}
 
-	l := New(input, 4)
+	l := New(test.MakeFile(input))
 
 	for i, tok := range expected {
-		if tok != l.Tokens[i] {
+		if !sameToken(tok, l.Tokens[i]) {
 			t.Fatalf("Tests[%d] - Wrong token. Expected = %q, got = %q", i, tok, l.Tokens[i])
 		}
 	}
 }
+
+// TestRegexLexerOutput checks that the scanner gives the tokens of the
+// regex lexer it replaced, with the same positions, on programs that lex
+// the same in both.
+func TestRegexLexerOutput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{
+			test.MakeInput(
+				`# pokemon stats`,
+				`let hp = 55, level = 5`,
+				`fn attack(target, power)`,
+				`    return power >= 10 && !target.fainted`,
+				`if hp > 0 then`,
+				`    while hp < 100 do`,
+				`        hp -= attack(hp, 2) * 3`,
+				`else`,
+				`    hp = 0`,
+			),
+			[]token.Token{
+				token.NewToken(token.EOL, "", 1, 16),
+				token.NewToken(token.LET, "let", 2, 1),
+				token.NewToken(token.IDENT, "hp", 2, 5),
+				token.NewToken(token.ASSIGN, "=", 2, 8),
+				token.NewToken(token.INT, "55", 2, 10),
+				token.NewToken(token.COMMA, ",", 2, 12),
+				token.NewToken(token.IDENT, "level", 2, 14),
+				token.NewToken(token.ASSIGN, "=", 2, 20),
+				token.NewToken(token.INT, "5", 2, 22),
+				token.NewToken(token.EOL, "", 2, 23),
+				token.NewToken(token.FUNCTION, "fn", 3, 1),
+				token.NewToken(token.IDENT, "attack", 3, 4),
+				token.NewToken(token.LPAREN, "(", 3, 10),
+				token.NewToken(token.IDENT, "target", 3, 11),
+				token.NewToken(token.COMMA, ",", 3, 17),
+				token.NewToken(token.IDENT, "power", 3, 19),
+				token.NewToken(token.RPAREN, ")", 3, 24),
+				token.NewToken(token.EOL, "", 3, 25),
+				token.NewToken(token.INDENT, "", 4, 1),
+				token.NewToken(token.RETURN, "return", 4, 5),
+				token.NewToken(token.IDENT, "power", 4, 12),
+				token.NewToken(token.GT_EQ, ">=", 4, 18),
+				token.NewToken(token.INT, "10", 4, 21),
+				token.NewToken(token.AND, "&&", 4, 24),
+				token.NewToken(token.BANG, "!", 4, 27),
+				token.NewToken(token.IDENT, "target", 4, 28),
+				token.NewToken(token.DOT, ".", 4, 34),
+				token.NewToken(token.IDENT, "fainted", 4, 35),
+				token.NewToken(token.EOL, "", 4, 42),
+				token.NewToken(token.DEDENT, "", 5, 1),
+				token.NewToken(token.IF, "if", 5, 1),
+				token.NewToken(token.IDENT, "hp", 5, 4),
+				token.NewToken(token.GT, ">", 5, 7),
+				token.NewToken(token.INT, "0", 5, 9),
+				token.NewToken(token.THEN, "then", 5, 11),
+				token.NewToken(token.EOL, "", 5, 15),
+				token.NewToken(token.INDENT, "", 6, 1),
+				token.NewToken(token.WHILE, "while", 6, 5),
+				token.NewToken(token.IDENT, "hp", 6, 11),
+				token.NewToken(token.LT, "<", 6, 14),
+				token.NewToken(token.INT, "100", 6, 16),
+				token.NewToken(token.DO, "do", 6, 20),
+				token.NewToken(token.EOL, "", 6, 22),
+				token.NewToken(token.INDENT, "", 7, 1),
+				token.NewToken(token.IDENT, "hp", 7, 9),
+				token.NewToken(token.MINUS_ASSIGN, "-=", 7, 12),
+				token.NewToken(token.IDENT, "attack", 7, 15),
+				token.NewToken(token.LPAREN, "(", 7, 21),
+				token.NewToken(token.IDENT, "hp", 7, 22),
+				token.NewToken(token.COMMA, ",", 7, 24),
+				token.NewToken(token.INT, "2", 7, 26),
+				token.NewToken(token.RPAREN, ")", 7, 27),
+				token.NewToken(token.STAR, "*", 7, 29),
+				token.NewToken(token.INT, "3", 7, 31),
+				token.NewToken(token.EOL, "", 7, 32),
+				token.NewToken(token.DEDENT, "", 8, 1),
+				token.NewToken(token.DEDENT, "", 8, 1),
+				token.NewToken(token.ELSE, "else", 8, 1),
+				token.NewToken(token.EOL, "", 8, 5),
+				token.NewToken(token.INDENT, "", 9, 1),
+				token.NewToken(token.IDENT, "hp", 9, 5),
+				token.NewToken(token.ASSIGN, "=", 9, 8),
+				token.NewToken(token.INT, "0", 9, 10),
+				token.NewToken(token.EOL, "", 9, 11),
+				token.NewToken(token.DEDENT, "", 10, 1),
+				token.NewToken(token.EOF, "", 11, 1),
+			},
+		},
+		{
+			test.MakeInput(
+				`let team = ["eevee", "flareon"]`,
+				`let stats = { hp: 55, "speed": 3.5 }`,
+				`for let i = 0; i < 2; i += 1 do`,
+				`    print(team[i])`,
+				`while level <= 100 || not done do`,
+				`  level *= 2; level /= 3`,
+				`  level %= 7`,
+			),
+			[]token.Token{
+				token.NewToken(token.LET, "let", 1, 1),
+				token.NewToken(token.IDENT, "team", 1, 5),
+				token.NewToken(token.ASSIGN, "=", 1, 10),
+				token.NewToken(token.LBRACKET, "[", 1, 12),
+				token.NewToken(token.STRING, "\"eevee\"", 1, 13),
+				token.NewToken(token.COMMA, ",", 1, 20),
+				token.NewToken(token.STRING, "\"flareon\"", 1, 22),
+				token.NewToken(token.RBRACKET, "]", 1, 31),
+				token.NewToken(token.EOL, "", 1, 32),
+				token.NewToken(token.LET, "let", 2, 1),
+				token.NewToken(token.IDENT, "stats", 2, 5),
+				token.NewToken(token.ASSIGN, "=", 2, 11),
+				token.NewToken(token.LBRACE, "{", 2, 13),
+				token.NewToken(token.IDENT, "hp", 2, 15),
+				token.NewToken(token.COLON, ":", 2, 17),
+				token.NewToken(token.INT, "55", 2, 19),
+				token.NewToken(token.COMMA, ",", 2, 21),
+				token.NewToken(token.STRING, "\"speed\"", 2, 23),
+				token.NewToken(token.COLON, ":", 2, 30),
+				token.NewToken(token.FLOAT, "3.5", 2, 32),
+				token.NewToken(token.RBRACE, "}", 2, 36),
+				token.NewToken(token.EOL, "", 2, 37),
+				token.NewToken(token.FOR, "for", 3, 1),
+				token.NewToken(token.LET, "let", 3, 5),
+				token.NewToken(token.IDENT, "i", 3, 9),
+				token.NewToken(token.ASSIGN, "=", 3, 11),
+				token.NewToken(token.INT, "0", 3, 13),
+				token.NewToken(token.SEMI, ";", 3, 14),
+				token.NewToken(token.IDENT, "i", 3, 16),
+				token.NewToken(token.LT, "<", 3, 18),
+				token.NewToken(token.INT, "2", 3, 20),
+				token.NewToken(token.SEMI, ";", 3, 21),
+				token.NewToken(token.IDENT, "i", 3, 23),
+				token.NewToken(token.PLUS_ASSIGN, "+=", 3, 25),
+				token.NewToken(token.INT, "1", 3, 28),
+				token.NewToken(token.DO, "do", 3, 30),
+				token.NewToken(token.EOL, "", 3, 32),
+				token.NewToken(token.INDENT, "", 4, 1),
+				token.NewToken(token.IDENT, "print", 4, 5),
+				token.NewToken(token.LPAREN, "(", 4, 10),
+				token.NewToken(token.IDENT, "team", 4, 11),
+				token.NewToken(token.LBRACKET, "[", 4, 15),
+				token.NewToken(token.IDENT, "i", 4, 16),
+				token.NewToken(token.RBRACKET, "]", 4, 17),
+				token.NewToken(token.RPAREN, ")", 4, 18),
+				token.NewToken(token.EOL, "", 4, 19),
+				token.NewToken(token.DEDENT, "", 5, 1),
+				token.NewToken(token.WHILE, "while", 5, 1),
+				token.NewToken(token.IDENT, "level", 5, 7),
+				token.NewToken(token.LT_EQ, "<=", 5, 13),
+				token.NewToken(token.INT, "100", 5, 16),
+				token.NewToken(token.OR, "||", 5, 20),
+				token.NewToken(token.NOT, "not", 5, 23),
+				token.NewToken(token.IDENT, "done", 5, 27),
+				token.NewToken(token.DO, "do", 5, 32),
+				token.NewToken(token.EOL, "", 5, 34),
+				token.NewToken(token.INDENT, "", 6, 1),
+				token.NewToken(token.IDENT, "level", 6, 3),
+				token.NewToken(token.STAR_ASSIGN, "*=", 6, 9),
+				token.NewToken(token.INT, "2", 6, 12),
+				token.NewToken(token.SEMI, ";", 6, 13),
+				token.NewToken(token.IDENT, "level", 6, 15),
+				token.NewToken(token.SLASH_ASSIGN, "/=", 6, 21),
+				token.NewToken(token.INT, "3", 6, 24),
+				token.NewToken(token.EOL, "", 6, 25),
+				token.NewToken(token.IDENT, "level", 7, 3),
+				token.NewToken(token.PERCENT_ASSIGN, "%=", 7, 9),
+				token.NewToken(token.INT, "7", 7, 12),
+				token.NewToken(token.EOL, "", 7, 13),
+				token.NewToken(token.DEDENT, "", 8, 1),
+				token.NewToken(token.EOF, "", 9, 1),
+			},
+		},
+		{
+			test.MakeInput(
+				`module pokedex`,
+				`import stats`,
+				``,
+				`fn evolve(pokemon)`,
+				`  let next = null`,
+				`  if pokemon.name == "eevee" then next = "vaporeon"`,
+				`  return next != null and true or false`,
+			),
+			[]token.Token{
+				token.NewToken(token.MODULE, "module", 1, 1),
+				token.NewToken(token.IDENT, "pokedex", 1, 8),
+				token.NewToken(token.EOL, "", 1, 15),
+				token.NewToken(token.IMPORT, "import", 2, 1),
+				token.NewToken(token.IDENT, "stats", 2, 8),
+				token.NewToken(token.EOL, "", 2, 13),
+				token.NewToken(token.EOL, "", 3, 1),
+				token.NewToken(token.FUNCTION, "fn", 4, 1),
+				token.NewToken(token.IDENT, "evolve", 4, 4),
+				token.NewToken(token.LPAREN, "(", 4, 10),
+				token.NewToken(token.IDENT, "pokemon", 4, 11),
+				token.NewToken(token.RPAREN, ")", 4, 18),
+				token.NewToken(token.EOL, "", 4, 19),
+				token.NewToken(token.INDENT, "", 5, 1),
+				token.NewToken(token.LET, "let", 5, 3),
+				token.NewToken(token.IDENT, "next", 5, 7),
+				token.NewToken(token.ASSIGN, "=", 5, 12),
+				token.NewToken(token.NULL, "null", 5, 14),
+				token.NewToken(token.EOL, "", 5, 18),
+				token.NewToken(token.IF, "if", 6, 3),
+				token.NewToken(token.IDENT, "pokemon", 6, 6),
+				token.NewToken(token.DOT, ".", 6, 13),
+				token.NewToken(token.IDENT, "name", 6, 14),
+				token.NewToken(token.EQ, "==", 6, 19),
+				token.NewToken(token.STRING, "\"eevee\"", 6, 22),
+				token.NewToken(token.THEN, "then", 6, 30),
+				token.NewToken(token.IDENT, "next", 6, 35),
+				token.NewToken(token.ASSIGN, "=", 6, 40),
+				token.NewToken(token.STRING, "\"vaporeon\"", 6, 42),
+				token.NewToken(token.EOL, "", 6, 52),
+				token.NewToken(token.RETURN, "return", 7, 3),
+				token.NewToken(token.IDENT, "next", 7, 10),
+				token.NewToken(token.NOT_EQ, "!=", 7, 15),
+				token.NewToken(token.NULL, "null", 7, 18),
+				token.NewToken(token.AND, "and", 7, 23),
+				token.NewToken(token.TRUE, "true", 7, 27),
+				token.NewToken(token.OR, "or", 7, 32),
+				token.NewToken(token.FALSE, "false", 7, 35),
+				token.NewToken(token.EOL, "", 7, 40),
+				token.NewToken(token.DEDENT, "", 8, 1),
+				token.NewToken(token.EOF, "", 9, 1),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := New(test.MakeFile(tt.input))
+
+		if len(l.Tokens) != len(tt.expected) {
+			t.Errorf("%q - Expected %d tokens, got %d", tt.input, len(tt.expected), len(l.Tokens))
+			continue
+		}
+
+		for i, tok := range tt.expected {
+			if !sameToken(tok, l.Tokens[i]) {
+				t.Errorf("%q - Tests[%d] - Wrong token. Expected = %q, got = %q", tt.input, i, tok, l.Tokens[i])
+				break
+			}
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected token.Token
+	}{
+		{`42`, token.NewToken(token.INT, "42", 1, 1)},
+		{`0`, token.NewToken(token.INT, "0", 1, 1)},
+		{`0xFF`, token.NewToken(token.INT, "0xFF", 1, 1)},
+		{`0o755`, token.NewToken(token.INT, "0o755", 1, 1)},
+		{`0b1010`, token.NewToken(token.INT, "0b1010", 1, 1)},
+		{`0x_dead_BEEF`, token.NewToken(token.INT, "0x_dead_BEEF", 1, 1)},
+		{`1_000_000`, token.NewToken(token.INT, "1_000_000", 1, 1)},
+		{`3.14`, token.NewToken(token.FLOAT, "3.14", 1, 1)},
+		{`1e9`, token.NewToken(token.FLOAT, "1e9", 1, 1)},
+		{`2.5e-3`, token.NewToken(token.FLOAT, "2.5e-3", 1, 1)},
+		{`6.02E+23`, token.NewToken(token.FLOAT, "6.02E+23", 1, 1)},
+		{`.5`, token.NewToken(token.FLOAT, ".5", 1, 1)},
+		{`0.000_1`, token.NewToken(token.FLOAT, "0.000_1", 1, 1)},
+		{`12.30d`, token.NewToken(token.DECIMAL, "12.30d", 1, 1)},
+		{`3d`, token.NewToken(token.DECIMAL, "3d", 1, 1)},
+		{`.5d`, token.NewToken(token.DECIMAL, ".5d", 1, 1)},
+		{`1_000.50d`, token.NewToken(token.DECIMAL, "1_000.50d", 1, 1)},
+		{`1.5e3d`, token.NewToken(token.DECIMAL, "1.5e3d", 1, 1)},
+	}
+
+	for _, tt := range tests {
+		l := New(test.MakeFile(tt.input))
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("%q - Unexpected lexer errors: %q", tt.input, l.Errors())
+			continue
+		}
+
+		if !sameToken(l.Tokens[0], tt.expected) || l.Tokens[1].Type != token.EOF {
+			t.Errorf("%q - Expected only %q, got %q", tt.input, tt.expected, l.Tokens)
+		}
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x = 0x`, `[1, 5] Invalid hexadecimal literal: it has no digits`},
+		{`0b_`, `[1, 1] Invalid binary literal: it has no digits`},
+		{`0b102`, `[1, 5] Invalid digit '2' in binary literal`},
+		{`0o78`, `[1, 4] Invalid digit '8' in octal literal`},
+		{`0xFG`, `[1, 4] Invalid digit 'G' in hexadecimal literal`},
+		{`12abc`, `[1, 3] Invalid digit 'a' in decimal literal`},
+		{`1e`, `[1, 2] Exponent has no digits`},
+		{`2.5e+x`, `[1, 4] Exponent has no digits`},
+		{`1__000`, `[1, 2] "_" must separate successive digits`},
+		{`1000_`, `[1, 5] "_" must separate successive digits`},
+		{`1_.5`, `[1, 2] "_" must separate successive digits`},
+		{`0x1__F`, `[1, 4] "_" must separate successive digits`},
+		{`12.3dx`, `[1, 5] Invalid digit 'd' in decimal literal`},
+		{`1__0d`, `[1, 2] "_" must separate successive digits`},
+		{`0755`, `[1, 1] Leading zeros are not allowed in decimal integers, use the 0o prefix for octal`},
+		{`"hp {0b2}"`, `[1, 8] Invalid digit '2' in binary literal`},
+	}
+
+	for _, tt := range tests {
+		l := New(test.MakeFile(tt.input))
+		errors := l.Errors()
+
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q - Expected error %q, got %q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	input := test.MakeInput(
+		`if x then`,
+		"\tlet s = \"\"\"a",
+		`b""" + "{x}"`,
+	)
+
+	expected := []struct {
+		tokenType token.TokenType
+		pos       token.Position
+		end       token.Position
+	}{
+		{token.IF, token.Position{Line: 1, Column: 1, Offset: 0}, token.Position{Line: 1, Column: 3, Offset: 2}},
+		{token.IDENT, token.Position{Line: 1, Column: 4, Offset: 3}, token.Position{Line: 1, Column: 5, Offset: 4}},
+		{token.THEN, token.Position{Line: 1, Column: 6, Offset: 5}, token.Position{Line: 1, Column: 10, Offset: 9}},
+		{token.EOL, token.Position{Line: 1, Column: 10, Offset: 9}, token.Position{Line: 1, Column: 10, Offset: 9}},
+		{token.INDENT, token.Position{Line: 2, Column: 1, Offset: 10}, token.Position{Line: 2, Column: 1, Offset: 10}},
+		{token.LET, token.Position{Line: 2, Column: 5, Offset: 11}, token.Position{Line: 2, Column: 8, Offset: 14}},
+		{token.IDENT, token.Position{Line: 2, Column: 9, Offset: 15}, token.Position{Line: 2, Column: 10, Offset: 16}},
+		{token.ASSIGN, token.Position{Line: 2, Column: 11, Offset: 17}, token.Position{Line: 2, Column: 12, Offset: 18}},
+		{token.STRING, token.Position{Line: 2, Column: 13, Offset: 19}, token.Position{Line: 3, Column: 5, Offset: 28}},
+		{token.PLUS, token.Position{Line: 3, Column: 6, Offset: 29}, token.Position{Line: 3, Column: 7, Offset: 30}},
+		{token.INTERP_START, token.Position{Line: 3, Column: 8, Offset: 31}, token.Position{Line: 3, Column: 10, Offset: 33}},
+		{token.IDENT, token.Position{Line: 3, Column: 10, Offset: 33}, token.Position{Line: 3, Column: 11, Offset: 34}},
+		{token.INTERP_END, token.Position{Line: 3, Column: 11, Offset: 34}, token.Position{Line: 3, Column: 13, Offset: 36}},
+		{token.EOL, token.Position{Line: 3, Column: 13, Offset: 36}, token.Position{Line: 3, Column: 13, Offset: 36}},
+		{token.DEDENT, token.Position{Line: 4, Column: 1, Offset: 37}, token.Position{Line: 4, Column: 1, Offset: 37}},
+		{token.EOF, token.Position{Line: 5, Column: 1, Offset: 37}, token.Position{Line: 5, Column: 1, Offset: 37}},
+	}
+
+	l := New(test.MakeFile(input))
+
+	for i, tt := range expected {
+		tok := l.Tokens[i]
+		if tok.Type != tt.tokenType || tok.Pos() != tt.pos || tok.End() != tt.end {
+			t.Fatalf("Tests[%d] - Expected %s from %+v to %+v, got %s from %+v to %+v", i, tt.tokenType, tt.pos, tt.end, tok.Type, tok.Pos(), tok.End())
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := test.MakeInput(
+		`"say \"hi\"" 'it\'s'`,
+		"`C:\\path`",
+		`if x then`,
+		`	let s = """one`,
+		`two`,
+		`		three""" + 1`,
+		`x`,
+	)
+
+	expected := []token.Token{
+		token.NewToken(token.STRING, `"say \"hi\""`, 1, 1),
+		token.NewToken(token.STRING, `'it\'s'`, 1, 14),
+		token.NewToken(token.EOL, "", 1, 21),
+		token.NewToken(token.STRING, "`C:\\path`", 2, 1),
+		token.NewToken(token.EOL, "", 2, 10),
+		token.NewToken(token.IF, "if", 3, 1),
+		token.NewToken(token.IDENT, "x", 3, 4),
+		token.NewToken(token.THEN, "then", 3, 6),
+		token.NewToken(token.EOL, "", 3, 10),
+		token.NewToken(token.INDENT, "", 4, 1),
+		token.NewToken(token.LET, "let", 4, 5),
+		token.NewToken(token.IDENT, "s", 4, 9),
+		token.NewToken(token.ASSIGN, "=", 4, 11),
+		token.NewToken(token.STRING, "\"\"\"one\ntwo\n\t\tthree\"\"\"", 4, 13),
+		token.NewToken(token.PLUS, "+", 6, 18),
+		token.NewToken(token.INT, "1", 6, 20),
+		token.NewToken(token.EOL, "", 6, 21),
+		token.NewToken(token.DEDENT, "", 7, 1),
+		token.NewToken(token.IDENT, "x", 7, 1),
+		token.NewToken(token.EOL, "", 7, 2),
+		token.NewToken(token.EOF, "", 9, 1),
+	}
+
+	l := New(test.MakeFile(input))
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("Unexpected lexer errors: %q", l.Errors())
+	}
+
+	for i, tok := range expected {
+		if !sameToken(tok, l.Tokens[i]) {
+			t.Fatalf("Tests[%d] - Wrong token. Expected = %q, got = %q", i, tok, l.Tokens[i])
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	input := strings.ReplaceAll(test.MakeInput(
+		`let s = "héllo" + x`,
+		`if x then`,
+		"\tif y then",
+		"\t\ts",
+		"\tx",
+	), "\n", "\r\n")
+
+	expected := []token.Token{
+		token.NewToken(token.LET, "let", 1, 1),
+		token.NewToken(token.IDENT, "s", 1, 5),
+		token.NewToken(token.ASSIGN, "=", 1, 7),
+		token.NewToken(token.STRING, `"héllo"`, 1, 9),
+		token.NewToken(token.PLUS, "+", 1, 17),
+		token.NewToken(token.IDENT, "x", 1, 19),
+		token.NewToken(token.EOL, "", 1, 20),
+		token.NewToken(token.IF, "if", 2, 1),
+		token.NewToken(token.IDENT, "x", 2, 4),
+		token.NewToken(token.THEN, "then", 2, 6),
+		token.NewToken(token.EOL, "", 2, 10),
+		token.NewToken(token.INDENT, "", 3, 1),
+		token.NewToken(token.IF, "if", 3, 5),
+		token.NewToken(token.IDENT, "y", 3, 8),
+		token.NewToken(token.THEN, "then", 3, 10),
+		token.NewToken(token.EOL, "", 3, 14),
+		token.NewToken(token.INDENT, "", 4, 1),
+		token.NewToken(token.IDENT, "s", 4, 9),
+		token.NewToken(token.EOL, "", 4, 10),
+		token.NewToken(token.DEDENT, "", 5, 1),
+		token.NewToken(token.IDENT, "x", 5, 5),
+		token.NewToken(token.EOL, "", 5, 6),
+		token.NewToken(token.DEDENT, "", 6, 1),
+		token.NewToken(token.EOF, "", 7, 1),
+	}
+
+	l := New(test.MakeFile(input))
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("Unexpected lexer errors: %q", l.Errors())
+	}
+
+	for i, tok := range expected {
+		if !sameToken(tok, l.Tokens[i]) {
+			t.Fatalf("Tests[%d] - Wrong token. Expected = %q, got = %q", i, tok, l.Tokens[i])
+		}
+	}
+}
+
+func TestIndentation(t *testing.T) {
+	input := test.MakeInput(
+		`if x then`,
+		"\ty",
+		``,
+		`  # a comment`,
+		`    z`,
+		"  \tw",
+		`v`,
+	)
+
+	expected := []token.Token{
+		token.NewToken(token.IF, "if", 1, 1),
+		token.NewToken(token.IDENT, "x", 1, 4),
+		token.NewToken(token.THEN, "then", 1, 6),
+		token.NewToken(token.EOL, "", 1, 10),
+		token.NewToken(token.INDENT, "", 2, 1),
+		token.NewToken(token.IDENT, "y", 2, 5),
+		token.NewToken(token.EOL, "", 2, 6),
+		token.NewToken(token.EOL, "", 3, 1),
+		token.NewToken(token.EOL, "", 4, 14),
+		token.NewToken(token.IDENT, "z", 5, 5),
+		token.NewToken(token.EOL, "", 5, 6),
+		token.NewToken(token.IDENT, "w", 6, 5),
+		token.NewToken(token.EOL, "", 6, 6),
+		token.NewToken(token.DEDENT, "", 7, 1),
+		token.NewToken(token.IDENT, "v", 7, 1),
+	}
+
+	l := New(test.MakeFile(input))
+
+	for i, tok := range expected {
+		if !sameToken(tok, l.Tokens[i]) {
+			t.Fatalf("Tests[%d] - Wrong token. Expected = %q, got = %q", i, tok, l.Tokens[i])
+		}
+	}
+
+	// The tab and the spaces of the last indented line are the same level,
+	// but mixing them is worth a warning.
+	diags := l.Diagnostics()
+	if len(diags) != 1 || diags[0].Severity != diagnostics.Warning || diags[0].Code != diagnostics.MixedIndentation {
+		t.Fatalf("Expected a mixed indentation warning, got %q", l.Errors())
+	}
+}
+
+func TestIndentationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		mode     Mode
+		severity diagnostics.Severity
+		expected string
+	}{
+		{
+			test.MakeInput(`if x then`, `    y`, `  z`),
+			0,
+			diagnostics.Error,
+			`[3, 1] Unindent does not match any outer indentation level`,
+		},
+		{
+			test.MakeInput(`if x then`, "\t  y"),
+			StrictIndentation,
+			diagnostics.Error,
+			`[2, 1] Indentation mixes tabs and spaces`,
+		},
+		{
+			test.MakeInput(`if x then`, "\ty", `if z then`, `    w`),
+			StrictIndentation,
+			diagnostics.Error,
+			`[4, 1] Indentation uses spaces, but the file is indented with tabs`,
+		},
+		{
+			test.MakeInput(`if x then`, "\ty", `if z then`, `    w`),
+			0,
+			diagnostics.Error,
+			``,
+		},
+		{
+			test.MakeInput(`if v then {`, `    v = 2`, `  }`),
+			0,
+			diagnostics.Error,
+			``,
+		},
+		{
+			test.MakeInput(`f(fn (x) {`, `        return x`, `    }, 1)`),
+			0,
+			diagnostics.Error,
+			``,
+		},
+		{
+			test.MakeInput(`f(fn (x) {`, `    if x then`, `        y`, `      z`, `})`),
+			0,
+			diagnostics.Error,
+			`[4, 1] Unindent does not match any outer indentation level`,
+		},
+	}
+
+	for _, tt := range tests {
+		l := NewWithMode(test.MakeFile(tt.input), tt.mode)
+
+		errors := strings.Join(l.Errors(), "\n")
+		if errors != tt.expected {
+			t.Errorf("%q - Expected %q, got %q", tt.input, tt.expected, errors)
+			continue
+		}
+		if tt.expected != "" && l.Diagnostics()[0].Severity != tt.severity {
+			t.Errorf("%q - Expected severity %s, got %s", tt.input, tt.severity, l.Diagnostics()[0].Severity)
+		}
+	}
+}
+
+func TestLineJoining(t *testing.T) {
+	input := test.MakeInput(
+		`f(1,`,
+		`		[2,`,
+		`	3])`,
+		`x = 1 + \`,
+		`	2`,
+		`if x then {`,
+		`	m = {`,
+		`	a: 1}`,
+		`}`,
+	)
+
+	expected := []token.Token{
+		token.NewToken(token.IDENT, "f", 1, 1),
+		token.NewToken(token.LPAREN, "(", 1, 2),
+		token.NewToken(token.INT, "1", 1, 3),
+		token.NewToken(token.COMMA, ",", 1, 4),
+		token.NewToken(token.LBRACKET, "[", 2, 9),
+		token.NewToken(token.INT, "2", 2, 10),
+		token.NewToken(token.COMMA, ",", 2, 11),
+		token.NewToken(token.INT, "3", 3, 5),
+		token.NewToken(token.RBRACKET, "]", 3, 6),
+		token.NewToken(token.RPAREN, ")", 3, 7),
+		token.NewToken(token.EOL, "", 3, 8),
+		token.NewToken(token.IDENT, "x", 4, 1),
+		token.NewToken(token.ASSIGN, "=", 4, 3),
+		token.NewToken(token.INT, "1", 4, 5),
+		token.NewToken(token.PLUS, "+", 4, 7),
+		token.NewToken(token.INT, "2", 5, 5),
+		token.NewToken(token.EOL, "", 5, 6),
+		token.NewToken(token.IF, "if", 6, 1),
+		token.NewToken(token.IDENT, "x", 6, 4),
+		token.NewToken(token.THEN, "then", 6, 6),
+		token.NewToken(token.LBRACE, "{", 6, 11),
+		token.NewToken(token.EOL, "", 6, 12),
+		token.NewToken(token.INDENT, "", 7, 1),
+		token.NewToken(token.IDENT, "m", 7, 5),
+		token.NewToken(token.ASSIGN, "=", 7, 7),
+		token.NewToken(token.LBRACE, "{", 7, 9),
+		token.NewToken(token.IDENT, "a", 8, 5),
+		token.NewToken(token.COLON, ":", 8, 6),
+		token.NewToken(token.INT, "1", 8, 8),
+		token.NewToken(token.RBRACE, "}", 8, 9),
+		token.NewToken(token.EOL, "", 8, 10),
+		token.NewToken(token.DEDENT, "", 9, 1),
+		token.NewToken(token.RBRACE, "}", 9, 1),
+		token.NewToken(token.EOL, "", 9, 2),
+		token.NewToken(token.EOF, "", 11, 1),
+	}
+
+	l := New(test.MakeFile(input))
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("Unexpected lexer errors: %q", l.Errors())
+	}
+	if len(l.Tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %q", len(expected), len(l.Tokens), l.Tokens)
+	}
+
+	for i, tok := range expected {
+		if !sameToken(tok, l.Tokens[i]) {
+			t.Fatalf("Tests[%d] - Wrong token. Expected = %q, got = %q", i, tok, l.Tokens[i])
+		}
+	}
+}
+
+func TestBracketErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		label    string
+	}{
+		{`f([1, 2)`, `[1, 8] Expected "]" to close "[", but got ")"`, `[1, 3] unclosed [`},
+		{`x = )`, `[1, 5] Unmatched ")"`, ``},
+		{test.MakeInput(`f(1,`, `	2`), `[1, 2] Unclosed "("`, ``},
+		{test.MakeInput(`if x then {`, `	y = (1 + 2]`), `[2, 15] Expected ")" to close "(", but got "]"`, `[2, 9] unclosed (`},
+	}
+
+	for _, tt := range tests {
+		diags := New(test.MakeFile(tt.input)).Diagnostics()
+		if len(diags) == 0 {
+			t.Errorf("%q - Expected error %q, got none", tt.input, tt.expected)
+			continue
+		}
+
+		if diags[0].Error() != tt.expected {
+			t.Errorf("%q - Expected error %q, got %q", tt.input, tt.expected, diags[0].Error())
+		}
+
+		label := ""
+		if len(diags[0].Labels) > 0 {
+			l := diags[0].Labels[0]
+			label = fmt.Sprintf("[%d, %d] %s", l.Span.Start.Line, l.Span.Start.Column, l.Message)
+		}
+		if label != tt.label {
+			t.Errorf("%q - Expected label %q, got %q", tt.input, tt.label, label)
+		}
+	}
+}
+
+func TestErrorFilename(t *testing.T) {
+	file := source.NewFileSet(4).AddFile("main.eev", []byte(`"a\qb"`))
+	expected := `main.eev [1, 3] Invalid escape sequence: \q`
+
+	if errors := New(file).Errors(); len(errors) != 1 || errors[0] != expected {
+		t.Errorf("Expected error %q, got %q", expected, errors)
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	input := test.MakeInput(
+		`"hi {name}!"`,
+		`"{a} and {m["k"]}" '\{x}'`,
+		`"""`,
+		`{ {k: 1}.k }"""`,
+	)
+
+	expected := []token.Token{
+		token.NewToken(token.INTERP_START, `"hi {`, 1, 1),
+		token.NewToken(token.IDENT, "name", 1, 6),
+		token.NewToken(token.INTERP_END, `}!"`, 1, 10),
+		token.NewToken(token.EOL, "", 1, 13),
+		token.NewToken(token.INTERP_START, `"{`, 2, 1),
+		token.NewToken(token.IDENT, "a", 2, 3),
+		token.NewToken(token.INTERP_MID, `} and {`, 2, 4),
+		token.NewToken(token.IDENT, "m", 2, 11),
+		token.NewToken(token.LBRACKET, "[", 2, 12),
+		token.NewToken(token.STRING, `"k"`, 2, 13),
+		token.NewToken(token.RBRACKET, "]", 2, 16),
+		token.NewToken(token.INTERP_END, `}"`, 2, 17),
+		token.NewToken(token.STRING, `'\{x}'`, 2, 20),
+		token.NewToken(token.EOL, "", 2, 26),
+		token.NewToken(token.INTERP_START, "\"\"\"\n{", 3, 1),
+		token.NewToken(token.LBRACE, "{", 4, 3),
+		token.NewToken(token.IDENT, "k", 4, 4),
+		token.NewToken(token.COLON, ":", 4, 5),
+		token.NewToken(token.INT, "1", 4, 7),
+		token.NewToken(token.RBRACE, "}", 4, 8),
+		token.NewToken(token.DOT, ".", 4, 9),
+		token.NewToken(token.IDENT, "k", 4, 10),
+		token.NewToken(token.INTERP_END, `}"""`, 4, 12),
+		token.NewToken(token.EOL, "", 4, 16),
+		token.NewToken(token.EOF, "", 6, 1),
+	}
+
+	l := New(test.MakeFile(input))
+
+	if len(l.Errors()) != 0 {
+		t.Fatalf("Unexpected lexer errors: %q", l.Errors())
+	}
+
+	for i, tok := range expected {
+		if !sameToken(tok, l.Tokens[i]) {
+			t.Fatalf("Tests[%d] - Wrong token. Expected = %q, got = %q", i, tok, l.Tokens[i])
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x = "eevee`, `[1, 5] Unterminated string literal: expected " to close it`},
+		{`'eevee`, `[1, 1] Unterminated string literal: expected ' to close it`},
+		{"x = `eevee\n", "[1, 5] Unterminated string literal: expected ` to close it"},
+		{"\"\"\"eevee\n\"", `[1, 1] Unterminated string literal: expected """ to close it`},
+		{`"a\qb"`, `[1, 3] Invalid escape sequence: \q`},
+		{`let s = "\u{110000}"`, `[1, 10] Invalid unicode escape: U+110000 is not a valid code point`},
+		{`"\u{zz}"`, `[1, 2] Invalid unicode escape: "zz" is not a hexadecimal code point`},
+		{`"\u1F600"`, `[1, 2] Invalid unicode escape: expected "{" after \u`},
+		{"\"\"\"one\n  two \\x\"\"\"", `[2, 7] Invalid escape sequence: \x`},
+		{`"lvl {lvl + 1`, `[1, 6] Unterminated interpolation: expected "}" to close "{"`},
+		{`"{a} \q {b}"`, `[1, 6] Invalid escape sequence: \q`},
+		{`"{a} {b} \q"`, `[1, 10] Invalid escape sequence: \q`},
+		{`"{"b \q"}"`, `[1, 6] Invalid escape sequence: \q`},
+	}
+
+	for _, tt := range tests {
+		l := New(test.MakeFile(tt.input))
+		errors := l.Errors()
+
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q - Expected error %q, got %q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input string
+		code  diagnostics.Code
+		start int
+		end   int
+		fix   string // offset and text of the suggested edit
+	}{
+		{`x = "eevee`, diagnostics.UnterminatedString, 4, 5, `10 "`},
+		{`0755`, diagnostics.InvalidNumber, 0, 4, `1 o`},
+		{`0789`, diagnostics.InvalidNumber, 0, 4, ``},
+		{`"a\qb"`, diagnostics.InvalidEscape, 2, 4, ``},
+		{`"é {x`, diagnostics.UnterminatedInterpolation, 4, 5, ``},
+	}
+
+	for _, tt := range tests {
+		diags := New(test.MakeFile(tt.input)).Diagnostics()
+		if len(diags) != 1 {
+			t.Errorf("%q - Expected 1 diagnostic, got %d", tt.input, len(diags))
+			continue
+		}
+
+		d := diags[0]
+		if d.Severity != diagnostics.Error || d.Code != tt.code {
+			t.Errorf("%q - Expected an error with code %s, got %s %s", tt.input, tt.code, d.Severity, d.Code)
+		}
+		if d.Span.Start.Offset != tt.start || d.Span.End.Offset != tt.end {
+			t.Errorf("%q - Expected span %d-%d, got %d-%d", tt.input, tt.start, tt.end, d.Span.Start.Offset, d.Span.End.Offset)
+		}
+
+		fix := ""
+		if len(d.Fixes) > 0 {
+			edit := d.Fixes[0].Edits[0]
+			fix = fmt.Sprintf("%d %s", edit.Span.Start.Offset, edit.NewText)
+		}
+		if fix != tt.fix {
+			t.Errorf("%q - Expected fix %q, got %q", tt.input, tt.fix, fix)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []string{
+		test.MakeInput(
+			`fn f(x)`,
+			`	if x > 1 then`,
+			`		return x`,
+			`	return 0`,
+		),
+		"let x = 1\r\nlet y = 2\r\n",
+		"\uFEFFlet x = 1",
+		test.MakeInput(
+			`let s = """a`,
+			`{x +`,
+			`  1} b"""`,
+			"let t = `c",
+			"d`",
+		),
+		test.MakeInput(
+			`let xs = [1,`,
+			`	2,`,
+			`3]`,
+			`let y = 1 + \`,
+			`	2`,
+		),
+		test.MakeInput(
+			`if x then {`,
+			`	let y = 1; y }`,
+		),
+		test.MakeInput(
+			`let x = (1`,
+			`  "eevee`,
+			`	  0755`,
+		),
+		"",
+	}
+
+	for _, input := range tests {
+		eager := New(source.NewFileSet(4).AddFile("main.eev", []byte(input)))
+		l := NewReader("main.eev", iotest.OneByteReader(strings.NewReader(input)), 4, 0)
+
+		for i, expected := range eager.Tokens {
+			if tok := l.NextToken(); tok != expected {
+				t.Fatalf("%q - Tests[%d] - Wrong token. Expected = %q at %d, got = %q at %d", input, i, expected, expected.Offset, tok, tok.Offset)
+			}
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q - Expected EOF after the end, got %q", input, tok)
+		}
+
+		if !reflect.DeepEqual(l.Diagnostics(), eager.Diagnostics()) {
+			t.Errorf("%q - Expected diagnostics %q, got %q", input, eager.Errors(), l.Errors())
+		}
+		if l.Err() != nil {
+			t.Errorf("%q - Unexpected read error: %s", input, l.Err())
+		}
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	l := NewReader("main.eev", iotest.TimeoutReader(strings.NewReader("let x = 1\nlet y = 2\n")), 4, 0)
+
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+
+	if l.Err() != iotest.ErrTimeout {
+		t.Errorf("Expected error %q, got %v", iotest.ErrTimeout, l.Err())
+	}
+	if len(types) == 0 || types[len(types)-1] != token.EOL {
+		t.Errorf("Expected the tokens read before the error to end with EOL, got %q", types)
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := test.MakeInput(
+		`# Pokémon`,
+		``,
+		`let x = 1  # one`,
+		`if x then`,
+		"\tprint(x, \\",
+		"\t\t2) ",
+	)
+
+	expected := []struct {
+		literal  string
+		leading  string
+		trailing string
+	}{
+		{"", "# Pokémon", "\n"},
+		{"", "", "\n"},
+		{"let", "", " "},
+		{"x", "", " "},
+		{"=", "", " "},
+		{"1", "", "  # one"},
+		{"", "", "\n"},
+		{"if", "", " "},
+		{"x", "", " "},
+		{"then", "", ""},
+		{"", "", "\n"},
+		{"", "", ""},
+		{"print", "\t", ""},
+		{"(", "", ""},
+		{"x", "", ""},
+		{",", "", " \\\n"},
+		{"2", "\t\t", ""},
+		{")", "", ""},
+		{"", "", " \n"},
+		{"", "", ""},
+		{"", "", ""},
+	}
+
+	l := NewWithMode(test.MakeFile(input), KeepTrivia)
+	if len(l.Tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %q", len(expected), len(l.Tokens), l.Tokens)
+	}
+
+	for i, tt := range expected {
+		tok := l.Tokens[i]
+		leading, trailing := token.TriviaText(tok.Trivia.Leading), token.TriviaText(tok.Trivia.Trailing)
+		if tok.Literal != tt.literal || leading != tt.leading || trailing != tt.trailing {
+			t.Errorf("Tests[%d] - Expected %q with trivia %q and %q, got %q with %q and %q",
+				i, tt.literal, tt.leading, tt.trailing, tok.Literal, leading, trailing)
+		}
+	}
+
+	kinds := []token.TriviaKind{}
+	for _, piece := range l.Tokens[15].Trivia.Trailing {
+		kinds = append(kinds, piece.Kind)
+	}
+	if fmt.Sprint(kinds) != fmt.Sprint([]token.TriviaKind{token.SpaceTrivia, token.ContinuationTrivia, token.NewlineTrivia}) {
+		t.Errorf("Expected space, continuation and newline, got %v", kinds)
+	}
+}
+
+func TestTriviaText(t *testing.T) {
+	tests := []string{
+		test.MakeInput(
+			`# generated`,
+			``,
+			`fn f(x)   # comment`,
+			`	let s = "{ x  +  1 }"  `,
+			``,
+			`	  # indented comment`,
+			`	return [1,`,
+			`		2] \`,
+			`		+ s`,
+		),
+		"let t = ```a\n\n  b```\n\n\n",
+		"let x = (1 +\n# no closing parenthesis",
+		"   ",
+		"",
+	}
+
+	for _, input := range tests {
+		readers := map[string]*Lexer{
+			"file":   NewWithMode(test.MakeFile(input), KeepTrivia),
+			"reader": NewReader("", iotest.OneByteReader(strings.NewReader(input)), 4, KeepTrivia),
+		}
+
+		for name, l := range readers {
+			var out strings.Builder
+			for tok := l.NextToken(); ; tok = l.NextToken() {
+				out.WriteString(token.TriviaText(tok.Trivia.Leading) + tok.Literal + token.TriviaText(tok.Trivia.Trailing))
+				if tok.Type == token.EOF {
+					break
+				}
+			}
+
+			if out.String() != input {
+				t.Errorf("%s %q - Expected the tokens to hold the source, got %q", name, input, out.String())
+			}
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected string
+	}{
+		{`"eevee"`, "eevee"},
+		{`""`, ""},
+		{`'a\tb'`, "a\tb"},
+		{`"\u{65}\u{1F600}"`, "e\U0001F600"},
+		{"`a\\tb`", "a\\tb"},
+		{`""""""`, ""},
+		{`"""{`, ""},
+		{`'''a{`, "a"},
+		{`}b"""`, "b"},
+		{"'''a\n'b'\n'''", "a\n'b'\n"},
+		{`"a\qb"`, "a\\qb"},
+		{`"eevee`, "eevee"},
+	}
+
+	for _, tt := range tests {
+		if value := Unquote(tt.literal); value != tt.expected {
+			t.Errorf("%q - Expected %q, got %q", tt.literal, tt.expected, value)
+		}
+	}
+}
+
+func BenchmarkLexer(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		source string
+	}{
+		{"Program", generateSource(4 << 20)},
+		{"LongLines", generateLongLines(4 << 20)},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bm.source)))
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				New(test.MakeFile(bm.source))
+			}
+		})
+	}
+}
+
+// generateSource returns a program of about size bytes made of nested
+// functions, loops and expressions, the way generated sources look.
+func generateSource(size int) string {
+	var out strings.Builder
+
+	for i := 0; out.Len() < size; i++ {
+		fmt.Fprintf(&out, "# generated function %d\n", i)
+		fmt.Fprintf(&out, "fn pokemon_%d(level, stats)\n", i)
+		fmt.Fprintf(&out, "\tlet hp = stats[\"hp\"] * 2.5, name = \"eevee_%d\"\n", i)
+		out.WriteString("\tfor let i = 0; i < level; i += 1 do\n")
+		out.WriteString("\t\tif hp >= 100 and not stats.fainted then\n")
+		out.WriteString("\t\t\thp -= (level % 7) * 3\n")
+		out.WriteString("\t\telse\n")
+		out.WriteString("\t\t\thp += 1\n")
+		out.WriteString("\treturn { name: name, hp: hp, moves: [1, 2, 3] }\n")
+	}
+
+	return out.String()
+}
+
+// generateLongLines returns about size bytes of very long expression lines.
+func generateLongLines(size int) string {
+	var out strings.Builder
+
+	for out.Len() < size {
+		out.WriteString("let total = 0")
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&out, " + values[%d] * 1.5", i)
+		}
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
+
+// sameToken compares two tokens without their offsets, which TestOffsets
+// checks on its own.
+func sameToken(a, b token.Token) bool {
+	a.Offset, b.Offset = 0, 0
+	return a == b
+}
@@ -0,0 +1,172 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/token"
+)
+
+type numberBase struct {
+	name    string
+	isDigit func(byte) bool
+}
+
+// numberBases maps the letter after a leading zero to the base it selects.
+var numberBases = map[byte]numberBase{
+	'x': {"hexadecimal", isHexDigit},
+	'X': {"hexadecimal", isHexDigit},
+	'o': {"octal", isOctalDigit},
+	'O': {"octal", isOctalDigit},
+	'b': {"binary", isBinaryDigit},
+	'B': {"binary", isBinaryDigit},
+}
+
+// scanNumber reads an integer, with an optional 0x, 0o or 0b prefix, a
+// float when the digits have a fraction or an exponent, or a decimal when
+// they end with the d suffix. Letters and digits stuck to the number are
+// read along with it, so that numberError reports them instead of the
+// parser finding an identifier after it.
+func scanNumber(src string) (token.TokenType, int) {
+	if len(src) > 1 && src[0] == '0' {
+		if _, ok := numberBases[src[1]]; ok {
+			return token.INT, 2 + skip(src[2:], isIdentChar)
+		}
+	}
+
+	tokenType := token.INT
+	n := skip(src, isDecimalChar)
+
+	if n+1 < len(src) && src[n] == '.' && isDigit(src[n+1]) {
+		tokenType = token.FLOAT
+		n += 1 + skip(src[n+1:], isDecimalChar)
+	}
+
+	if n < len(src) && (src[n] == 'e' || src[n] == 'E') {
+		m := n + 1
+		if m < len(src) && (src[m] == '+' || src[m] == '-') {
+			m++
+		}
+		if m < len(src) && isDigit(src[m]) {
+			tokenType = token.FLOAT
+			n = m + skip(src[m:], isDecimalChar)
+		}
+	}
+
+	if n < len(src) && src[n] == 'd' && (n+1 == len(src) || !isIdentChar(src[n+1])) {
+		return token.DECIMAL, n + 1
+	}
+
+	return tokenType, n + skip(src[n:], isIdentChar)
+}
+
+const leadingZeros = "Leading zeros are not allowed in decimal integers, use the 0o prefix for octal"
+
+// checkNumber reports a malformed INT, FLOAT or DECIMAL literal read by
+// scanNumber at the anchor a. The span goes from the mistake to the end of
+// the literal.
+func (l *Lexer) checkNumber(literal string, a anchor) {
+	offset, msg := numberError(literal)
+	if msg == "" {
+		return
+	}
+
+	d := diagnostics.Errorf(diagnostics.InvalidNumber, l.span(a, a.pos+offset, a.pos+len(literal)), "%s", msg)
+	if msg == leadingZeros && skip(literal, func(c byte) bool { return c == '_' || isOctalDigit(c) }) == len(literal) {
+		d = d.WithFix("write it as an octal literal", l.span(a, a.pos+1, a.pos+1), "o")
+	}
+	l.report(d)
+}
+
+// numberError returns the offset and the description of the first
+// mistake in a number literal, or an empty message when it is valid.
+func numberError(literal string) (int, string) {
+	if len(literal) > 1 && literal[0] == '0' {
+		if base, ok := numberBases[literal[1]]; ok {
+			return prefixedNumberError(literal, base)
+		}
+	}
+
+	literal = strings.TrimSuffix(literal, "d")
+	n := skip(literal, isDecimalChar)
+	isFloat := false
+
+	if n < len(literal) && literal[n] == '.' {
+		isFloat = true
+		n += 1 + skip(literal[n+1:], isDecimalChar)
+	}
+
+	if n < len(literal) && (literal[n] == 'e' || literal[n] == 'E') {
+		m := n + 1
+		if m < len(literal) && (literal[m] == '+' || literal[m] == '-') {
+			m++
+		}
+		if m == len(literal) || !isDigit(literal[m]) {
+			return n, "Exponent has no digits"
+		}
+		isFloat = true
+		n = m + skip(literal[m:], isDecimalChar)
+	}
+
+	if n < len(literal) {
+		return n, fmt.Sprintf("Invalid digit %q in decimal literal", literal[n])
+	}
+
+	if !isFloat && len(literal) > 1 && literal[0] == '0' {
+		return 0, leadingZeros
+	}
+
+	return separatorError(literal, 0, isDigit)
+}
+
+func prefixedNumberError(literal string, base numberBase) (int, string) {
+	digits := literal[2:]
+
+	if skip(digits, func(c byte) bool { return c == '_' }) == len(digits) {
+		return 0, fmt.Sprintf("Invalid %s literal: it has no digits", base.name)
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] != '_' && !base.isDigit(digits[i]) {
+			return 2 + i, fmt.Sprintf("Invalid digit %q in %s literal", digits[i], base.name)
+		}
+	}
+
+	return separatorError(literal, 2, base.isDigit)
+}
+
+// separatorError checks that each underscore of a number literal sits
+// between two digits. The prefix of a literal counts as a digit, so
+// that 0x_FF is allowed.
+func separatorError(literal string, prefixLength int, isDigit func(byte) bool) (int, string) {
+	for i := prefixLength; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+
+		before := i == prefixLength && prefixLength > 0 || i > 0 && isDigit(literal[i-1])
+		after := i+1 < len(literal) && isDigit(literal[i+1])
+		if !before || !after {
+			return i, `"_" must separate successive digits`
+		}
+	}
+
+	return 0, ""
+}
+
+func isDecimalChar(c byte) bool {
+	return isDigit(c) || c == '_'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isOctalDigit(c byte) bool {
+	return '0' <= c && c <= '7'
+}
+
+func isBinaryDigit(c byte) bool {
+	return c == '0' || c == '1'
+}
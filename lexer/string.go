@@ -0,0 +1,305 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/token"
+)
+
+// anchor is a known source position that the positions of the bytes that
+// follow it are computed from.
+type anchor struct {
+	pos    int
+	line   int
+	column int
+}
+
+// positionOf returns the line and column of the byte at pos, which comes
+// after a.
+func (l *Lexer) positionOf(pos int, a anchor) (int, int) {
+	between := l.source[a.pos:pos]
+	if i := strings.LastIndexByte(between, '\n'); i >= 0 {
+		return a.line + strings.Count(between, "\n"), l.advance(1, between[i+1:])
+	}
+
+	return a.line, l.advance(a.column, between)
+}
+
+// scanString reads a string literal starting at pos, at the given column.
+// Double and single quoted strings end on their line, while triple-quoted
+// and backtick strings may span several lines. Backtick strings are raw:
+// their backslashes and braces are kept as is.
+//
+// In the other strings, `{` starts an interpolated expression. The string
+// is then split into an INTERP_START token, the tokens of the expression,
+// INTERP_MID tokens between expressions and a closing INTERP_END token.
+func (l *Lexer) scanString(column int) {
+	a := anchor{pos: l.pos, line: l.line, column: column}
+	quote := l.source[l.pos]
+
+	delimiter := l.source[l.pos : l.pos+1]
+	if quote != '`' && strings.HasPrefix(l.source[l.pos:], strings.Repeat(delimiter, 3)) {
+		delimiter = l.source[l.pos : l.pos+3]
+	}
+	multiline := quote == '`' || len(delimiter) == 3
+	raw := quote == '`'
+	l.pos += len(delimiter)
+
+	partType, partStart := token.STRING, a.pos
+	terminated := false
+scan:
+	for l.pos < len(l.source) || l.fill() {
+		switch c := l.source[l.pos]; {
+		case strings.HasPrefix(l.source[l.pos:], delimiter):
+			l.pos += len(delimiter)
+			terminated = true
+			break scan
+		case c == '\n' && !multiline:
+			break scan
+		case c == '\n':
+			l.newLine(l.pos + 1)
+		case c == '\\' && !raw && l.pos+1 < len(l.source) && l.source[l.pos+1] != '\n':
+			length, _, _ := decodeEscape(l.source[l.pos:l.lineEnd()])
+			l.pos += length
+		case c == '{' && !raw:
+			open := l.pos
+			l.pos++
+			if partType == token.STRING {
+				partType = token.INTERP_START
+			}
+			l.addStringPart(partType, partStart, a)
+
+			if !l.scanInterpolation(a, multiline) {
+				l.report(diagnostics.Errorf(diagnostics.UnterminatedInterpolation, l.span(a, open, open+1),
+					"Unterminated interpolation: expected %q to close %q", token.RBRACE, token.LBRACE))
+				return
+			}
+			partType, partStart = token.INTERP_MID, l.pos
+			l.pos++
+		default:
+			l.pos++
+		}
+	}
+
+	if !terminated {
+		d := diagnostics.Errorf(diagnostics.UnterminatedString, l.span(a, a.pos, a.pos+len(delimiter)),
+			"Unterminated string literal: expected %s to close it", delimiter)
+		if !multiline {
+			l.pos = partStart + len(strings.TrimRightFunc(l.source[partStart:l.pos], unicode.IsSpace))
+			d = d.WithNote("Only triple-quoted and backtick strings can span several lines").
+				WithFix("close the string", l.span(a, l.pos, l.pos), delimiter)
+		}
+		l.report(d)
+	}
+
+	if partType == token.INTERP_MID {
+		partType = token.INTERP_END
+	}
+	l.addStringPart(partType, partStart, a)
+}
+
+// addStringPart emits the string token or string part that spans from
+// start to pos, and reports its malformed escape sequences.
+func (l *Lexer) addStringPart(tokenType token.TokenType, start int, a anchor) {
+	literal := l.source[start:l.pos]
+	line, column := l.positionOf(start, a)
+	l.addToken(tokenType, literal, line, column, start)
+
+	if literal[0] == '`' {
+		return
+	}
+
+	offset, content := stringContent(literal)
+	if _, err := unescape(content); err != nil {
+		errStart := start + offset + err.offset
+		l.report(diagnostics.Errorf(diagnostics.InvalidEscape, l.span(a, errStart, errStart+err.length), "%s", err.message))
+	}
+}
+
+// scanInterpolation reads the tokens of an expression embedded in a string
+// up to its closing brace, and reports whether it found it. Braces of map
+// literals and nested strings are skipped over.
+func (l *Lexer) scanInterpolation(a anchor, multiline bool) bool {
+	depth := 0
+
+	for l.pos < len(l.source) || l.fill() {
+		c := l.source[l.pos]
+		line, column := l.positionOf(l.pos, a)
+
+		switch {
+		case c == '\n' && !multiline:
+			return false
+		case c == '\n':
+			l.newLine(l.pos + 1)
+			continue
+		case c == '}' && depth == 0:
+			return true
+		case isQuote(c):
+			l.scanString(column)
+			continue
+		}
+
+		tokenType, length := scan(l.source[l.pos:l.lineEnd()])
+		lexeme := l.source[l.pos : l.pos+length]
+
+		switch tokenType {
+		case token.IDENT:
+			tokenType = lookupIdent(lexeme)
+		case token.INT, token.FLOAT, token.DECIMAL:
+			l.checkNumber(lexeme, anchor{pos: l.pos, line: line, column: column})
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			depth--
+		}
+
+		if tokenType != token.WHITESPACE && tokenType != token.COMMENT {
+			l.addToken(tokenType, lexeme, line, column, l.pos)
+		}
+		l.pos += length
+	}
+
+	return false
+}
+
+// Unquote returns the text of a STRING, INTERP_START, INTERP_MID or
+// INTERP_END token literal. Malformed literals are reported by the lexer,
+// so Unquote decodes them as far as it can and keeps invalid escape
+// sequences as written.
+func Unquote(literal string) string {
+	if literal == "" {
+		return ""
+	}
+
+	_, content := stringContent(literal)
+	if literal[0] == '`' {
+		return content
+	}
+
+	value, _ := unescape(content)
+	return value
+}
+
+// stringContent strips the quotes and interpolation braces of a string
+// token literal. It returns the offset of the content in literal.
+func stringContent(literal string) (int, string) {
+	var opening, closing string
+	if literal[0] == '}' {
+		opening = "}"
+		// The part that ends an interpolated string never holds an unescaped
+		// quote of its delimiter, so three trailing quotes close a
+		// triple-quoted string.
+		closing = literal[len(literal)-1:]
+		if len(literal) > 3 && strings.HasSuffix(literal, strings.Repeat(closing, 3)) {
+			closing = literal[len(literal)-3:]
+		}
+	} else {
+		opening = literal[:1]
+		if opening != "`" && strings.HasPrefix(literal, strings.Repeat(opening, 3)) {
+			opening = literal[:3]
+		}
+		closing = opening
+	}
+
+	content := literal[len(opening):]
+	switch {
+	case opening != "`" && strings.HasSuffix(content, "{"):
+		content = content[:len(content)-1]
+	case isQuote(closing[0]) && strings.HasSuffix(content, closing):
+		content = content[:len(content)-len(closing)]
+	}
+
+	return len(opening), content
+}
+
+type escapeError struct {
+	offset  int // offset of the backslash in the unescaped string
+	length  int
+	message string
+}
+
+var escapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+	'{':  '{',
+	'}':  '}',
+}
+
+// unescape decodes the escape sequences of s. It reports the first
+// malformed sequence, which is kept as written in the result.
+func unescape(s string) (string, *escapeError) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+
+	var out strings.Builder
+	var firstErr *escapeError
+
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			out.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		length, value, msg := decodeEscape(s[i:])
+		if msg != "" {
+			if firstErr == nil {
+				firstErr = &escapeError{offset: i, length: length, message: msg}
+			}
+			out.WriteString(s[i : i+length])
+		} else {
+			out.WriteString(value)
+		}
+		i += length
+	}
+
+	return out.String(), firstErr
+}
+
+// decodeEscape decodes the escape sequence at the start of s. It returns
+// the length of the sequence, its value, and a message if it is invalid.
+func decodeEscape(s string) (int, string, string) {
+	if len(s) < 2 {
+		return len(s), "", "Invalid escape sequence: \\ at end of string"
+	}
+
+	if value, ok := escapes[s[1]]; ok {
+		return 2, string(value), ""
+	}
+
+	if s[1] != 'u' {
+		r, size := utf8.DecodeRuneInString(s[1:])
+		return 1 + size, "", fmt.Sprintf("Invalid escape sequence: \\%c", r)
+	}
+
+	if len(s) < 3 || s[2] != '{' {
+		return 2, "", `Invalid unicode escape: expected "{" after \u`
+	}
+
+	end := 3 + skip(s[3:], isIdentChar)
+	if end == len(s) || s[end] != '}' {
+		return end, "", `Invalid unicode escape: expected "}" to close \u{`
+	}
+
+	digits := s[3:end]
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) == 0 || len(digits) > 6 {
+		return end + 1, "", fmt.Sprintf("Invalid unicode escape: %q is not a hexadecimal code point", digits)
+	}
+	if r := rune(code); !utf8.ValidRune(r) {
+		return end + 1, "", fmt.Sprintf("Invalid unicode escape: U+%X is not a valid code point", code)
+	}
+
+	return end + 1, string(rune(code)), ""
+}
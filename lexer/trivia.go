@@ -0,0 +1,63 @@
+package lexer
+
+import (
+	"strings"
+
+	"github.com/jellycat-io/eevee/token"
+)
+
+// attachTrivia gives tok, which is being added, the text between the end
+// of the last token and its start. The part of that text up to the first
+// line break trails the last token, which NextToken has not returned yet
+// in this mode, and the rest leads tok. The indentation of a line leads
+// its first token rather than trailing the INDENT and DEDENT tokens.
+func (l *Lexer) attachTrivia(tok *token.Token) {
+	gap := l.source[l.lastEnd-l.base : tok.Offset-l.base]
+	tok.Trivia = &token.Trivia{}
+
+	if n := len(l.Tokens); n > 0 && !isIndentation(l.Tokens[n-1].Type) {
+		trailing := gap
+		if i := strings.IndexByte(gap, '\n'); i >= 0 {
+			trailing = gap[:i+1]
+		}
+		l.Tokens[n-1].Trivia.Trailing = splitTrivia(trailing)
+		gap = gap[len(trailing):]
+	}
+
+	tok.Trivia.Leading = splitTrivia(gap)
+}
+
+// splitTrivia splits text, which is between two tokens, into pieces of
+// trivia.
+func splitTrivia(text string) []token.TriviaPiece {
+	var pieces []token.TriviaPiece
+
+	for text != "" {
+		kind, length := token.SpaceTrivia, 1
+		switch text[0] {
+		case '\n':
+			kind = token.NewlineTrivia
+		case '\\':
+			kind = token.ContinuationTrivia
+		case '#':
+			kind, length = token.CommentTrivia, len(text)
+			if i := strings.IndexByte(text, '\n'); i >= 0 {
+				length = i
+			}
+		default:
+			length = strings.IndexAny(text, "\n\\#")
+			if length < 0 {
+				length = len(text)
+			}
+		}
+
+		pieces = append(pieces, token.TriviaPiece{Kind: kind, Text: text[:length]})
+		text = text[length:]
+	}
+
+	return pieces
+}
+
+func isIndentation(tokenType token.TokenType) bool {
+	return tokenType == token.INDENT || tokenType == token.DEDENT
+}
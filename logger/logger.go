@@ -1,11 +1,12 @@
 package logger
 
 import (
-	"fmt"
 	"log"
 	"os"
 
 	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/source"
 )
 
 // Logger represents a simple logger with different logging levels
@@ -39,9 +40,7 @@ func (l *Logger) Fatal(msg string) {
 	l.fatalLogger.Printf(color.InRed("%s\n"), msg)
 }
 
-func (l *Logger) PrintParserErrors(errors []string) {
-	fmt.Println(color.InBold(color.InRed("parser errors:\n")))
-	for _, msg := range errors {
-		fmt.Println(color.InRed("\t" + msg))
-	}
+// PrintDiagnostics prints diags as code frames of the files in fset
+func (l *Logger) PrintDiagnostics(diags diagnostics.List, fset *source.FileSet) {
+	diagnostics.RenderAll(os.Stdout, diags, fset)
 }
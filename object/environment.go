@@ -0,0 +1,48 @@
+package object
+
+// Environment is a single scope in the chain of lexical scopes.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get looks name up in this scope and then in every enclosing one.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+
+	return obj, ok
+}
+
+// Define binds name in this scope, shadowing any outer binding.
+func (e *Environment) Define(name string, value Object) Object {
+	e.store[name] = value
+	return value
+}
+
+// Assign rebinds an existing name in the nearest scope that declares it.
+// It returns false when name is not declared anywhere in the chain.
+func (e *Environment) Assign(name string, value Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = value
+		return true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, value)
+	}
+
+	return false
+}
@@ -0,0 +1,343 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/code"
+	"github.com/jellycat-io/eevee/decimal"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = ObjectType("INTEGER")
+	BIG_INTEGER_OBJ  = ObjectType("BIG_INTEGER")
+	FLOAT_OBJ        = ObjectType("FLOAT")
+	DECIMAL_OBJ      = ObjectType("DECIMAL")
+	STRING_OBJ       = ObjectType("STRING")
+	BOOL_OBJ         = ObjectType("BOOL")
+	NULL_OBJ         = ObjectType("NULL")
+	ARRAY_OBJ        = ObjectType("ARRAY")
+	MAP_OBJ          = ObjectType("MAP")
+	FUNCTION_OBJ     = ObjectType("FUNCTION")
+	COMPILED_FN_OBJ  = ObjectType("COMPILED_FUNCTION")
+	CLOSURE_OBJ      = ObjectType("CLOSURE")
+	CELL_OBJ         = ObjectType("CELL")
+	RETURN_VALUE_OBJ = ObjectType("RETURN_VALUE")
+	ERROR_OBJ        = ObjectType("ERROR")
+)
+
+var (
+	NULL  = &Null{}
+	TRUE  = &Bool{Value: true}
+	FALSE = &Bool{Value: false}
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
+
+// BigInteger holds the integers that do not fit in an int64. Operations
+// on integers return an Integer again whenever their result fits.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Type() ObjectType { return BIG_INTEGER_OBJ }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+
+// NewInteger returns value as an Integer when it fits in an int64 and as
+// a BigInteger otherwise.
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInteger{Value: value}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string  { return strconv.FormatFloat(f.Value, 'g', -1, 64) }
+
+// Decimal is an exact base 10 number, created by literals such as 12.30d.
+type Decimal struct {
+	Value decimal.Decimal
+}
+
+func (d *Decimal) Type() ObjectType { return DECIMAL_OBJ }
+func (d *Decimal) Inspect() string  { return d.Value.String() }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+type Bool struct {
+	Value bool
+}
+
+func (b *Bool) Type() ObjectType { return BOOL_OBJ }
+func (b *Bool) Inspect() string  { return strconv.FormatBool(b.Value) }
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string  { return a.inspect(make(map[Object]bool)) }
+
+func (a *Array) inspect(visiting map[Object]bool) string {
+	if visiting[a] {
+		return "[...]"
+	}
+	visiting[a] = true
+	defer delete(visiting, a)
+
+	elements := make([]string, len(a.Elements))
+	for i, e := range a.Elements {
+		elements[i] = inspectElement(e, visiting)
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashKey identifies a map key. Numbers with the same value share a key,
+// so that m[1] and m[1.0] are the same entry.
+type HashKey struct {
+	Type  ObjectType
+	Value interface{}
+}
+
+// Hashable is implemented by the values that can be used as map keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey     { return HashKey{Type: INTEGER_OBJ, Value: i.Value} }
+func (bi *BigInteger) HashKey() HashKey { return HashKey{Type: INTEGER_OBJ, Value: bi.Value.String()} }
+func (s *String) HashKey() HashKey      { return HashKey{Type: STRING_OBJ, Value: s.Value} }
+func (b *Bool) HashKey() HashKey        { return HashKey{Type: BOOL_OBJ, Value: b.Value} }
+func (f *Float) HashKey() HashKey {
+	if i := int64(f.Value); float64(i) == f.Value {
+		return HashKey{Type: INTEGER_OBJ, Value: i}
+	}
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		value, _ := big.NewFloat(f.Value).Int(nil)
+		return NewInteger(value).(Hashable).HashKey()
+	}
+	return HashKey{Type: FLOAT_OBJ, Value: f.Value}
+}
+
+// Decimals equal to an integer or a float share their key.
+func (d *Decimal) HashKey() HashKey {
+	r := d.Value.Rat()
+	if r.IsInt() {
+		return NewInteger(r.Num()).(Hashable).HashKey()
+	}
+	if f, exact := r.Float64(); exact {
+		return HashKey{Type: FLOAT_OBJ, Value: f}
+	}
+	return HashKey{Type: DECIMAL_OBJ, Value: r.RatString()}
+}
+
+type MapPair struct {
+	Key   Object
+	Value Object
+}
+
+// Map keeps its pairs in insertion order.
+type Map struct {
+	Pairs map[HashKey]MapPair
+	Keys  []HashKey
+}
+
+func NewMap() *Map {
+	return &Map{Pairs: make(map[HashKey]MapPair)}
+}
+
+// Set inserts or replaces the value for key. Replacing a value keeps the
+// original position of the key.
+func (m *Map) Set(key Hashable, value Object) {
+	hash := key.HashKey()
+	if _, ok := m.Pairs[hash]; !ok {
+		m.Keys = append(m.Keys, hash)
+	}
+	m.Pairs[hash] = MapPair{Key: key.(Object), Value: value}
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Inspect() string  { return m.inspect(make(map[Object]bool)) }
+
+func (m *Map) inspect(visiting map[Object]bool) string {
+	if visiting[m] {
+		return "{...}"
+	}
+	visiting[m] = true
+	defer delete(visiting, m)
+
+	pairs := make([]string, len(m.Keys))
+	for i, hash := range m.Keys {
+		pair := m.Pairs[hash]
+		pairs[i] = inspectElement(pair.Key, visiting) + ": " + inspectElement(pair.Value, visiting)
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// AnonymousName is the name given to functions created by function
+// literals, used when inspecting them and in error messages.
+const AnonymousName = "anonymous"
+
+type Function struct {
+	Name       string
+	Parameters []ast.Identifier
+	Body       ast.Statement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	params := make([]string, len(f.Parameters))
+	for i, param := range f.Parameters {
+		params[i] = param.Name
+	}
+
+	return fmt.Sprintf("fn %s(%s)", f.Name, strings.Join(params, ", "))
+}
+
+// CompiledFunction is the bytecode of a function produced by the compiler.
+type CompiledFunction struct {
+	Name          string
+	Instructions  code.Instructions
+	Lines         []code.LineInfo
+	NumLocals     int
+	NumParameters int
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FN_OBJ }
+func (cf *CompiledFunction) Inspect() string  { return fmt.Sprintf("compiled fn %s", cf.Name) }
+
+// Closure is a compiled function bundled with the cells of the free
+// variables it captured when it was created.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string  { return fmt.Sprintf("fn %s", c.Fn.Name) }
+
+// Cell is a variable captured by closures. While the frame that declares
+// the variable runs, Ref points at its slot on the stack of the VM, so the
+// frame and the closures see each other's assignments. Once the slot goes
+// away, the cell is closed and keeps the last value itself.
+type Cell struct {
+	Ref   *Object
+	value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string  { return (*c.Ref).Inspect() }
+
+// Close makes c hold the value of the slot it points at.
+func (c *Cell) Close() {
+	c.value = *c.Ref
+	c.Ref = &c.value
+}
+
+// ReturnValue wraps the value of a return statement while it unwinds
+// to the enclosing function call.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+type Error struct {
+	Message string
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "error: " + e.Message }
+
+func NewError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func NativeBool(value bool) *Bool {
+	if value {
+		return TRUE
+	}
+
+	return FALSE
+}
+
+// Interpolate concatenates values the way an interpolated string does:
+// strings are inserted as is and other values as they are inspected.
+func Interpolate(values []Object) *String {
+	var out strings.Builder
+	for _, v := range values {
+		if s, ok := v.(*String); ok {
+			out.WriteString(s.Value)
+		} else {
+			out.WriteString(v.Inspect())
+		}
+	}
+
+	return &String{Value: out.String()}
+}
+
+// inspectElement formats a value nested inside a collection, quoting
+// strings so they can be told apart from other values.
+func inspectElement(obj Object, visiting map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *String:
+		return strconv.Quote(obj.Value)
+	case *Array:
+		return obj.inspect(visiting)
+	case *Map:
+		return obj.inspect(visiting)
+	default:
+		return obj.Inspect()
+	}
+}
+
+func IsError(obj Object) bool {
+	return obj != nil && obj.Type() == ERROR_OBJ
+}
+
+// IsTruthy reports whether obj counts as true in a condition.
+// Only null and false are falsy.
+func IsTruthy(obj Object) bool {
+	switch obj {
+	case NULL, FALSE:
+		return false
+	default:
+		return true
+	}
+}
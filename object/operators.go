@@ -0,0 +1,544 @@
+package object
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/jellycat-io/eevee/decimal"
+)
+
+// BinaryOp applies an arithmetic, comparison or equality operator.
+// It is shared by every execution engine so they agree on semantics.
+func BinaryOp(op string, left, right Object) Object {
+	switch op {
+	case "==":
+		return NativeBool(Equals(left, right))
+	case "!=":
+		return NativeBool(!Equals(left, right))
+	}
+
+	switch {
+	case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
+		return integerOp(op, left.(*Integer).Value, right.(*Integer).Value)
+	case isInteger(left) && isInteger(right):
+		return bigIntegerOp(op, toBigInt(left), toBigInt(right))
+	case isExact(left) && isExact(right):
+		return decimalOp(op, toDecimal(left), toDecimal(right))
+	case isNumber(left) && isNumber(right) && (left.Type() == DECIMAL_OBJ || right.Type() == DECIMAL_OBJ):
+		// Arithmetic would lose the exactness of the decimal, so decimals
+		// and floats can only be compared.
+		if compareOp(op, 0) == nil {
+			return NewError("unsupported operand types for %s: %s and %s", op, left.Type(), right.Type())
+		}
+		return compareNumbers(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return floatOp(op, toFloat(left), toFloat(right))
+	case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
+		return stringOp(op, left.(*String).Value, right.(*String).Value)
+	case left.Type() == ARRAY_OBJ && right.Type() == ARRAY_OBJ && op == "+":
+		l, r := left.(*Array).Elements, right.(*Array).Elements
+		elements := make([]Object, 0, len(l)+len(r))
+		elements = append(elements, l...)
+		return &Array{Elements: append(elements, r...)}
+	default:
+		return NewError("unsupported operand types for %s: %s and %s", op, left.Type(), right.Type())
+	}
+}
+
+// UnaryOp applies a prefix operator.
+func UnaryOp(op string, right Object) Object {
+	switch op {
+	case "!":
+		return NativeBool(!IsTruthy(right))
+	case "-":
+		switch right := right.(type) {
+		case *Integer:
+			if right.Value == math.MinInt64 {
+				return NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+			}
+			return &Integer{Value: -right.Value}
+		case *BigInteger:
+			return NewInteger(new(big.Int).Neg(right.Value))
+		case *Float:
+			return &Float{Value: -right.Value}
+		case *Decimal:
+			return &Decimal{Value: right.Value.Neg()}
+		}
+	case "+":
+		if isNumber(right) {
+			return right
+		}
+	default:
+		return NewError("unknown operator: %s", op)
+	}
+
+	return NewError("unsupported operand type for %s: %s", op, right.Type())
+}
+
+// Equals compares two values. Numbers compare by value across integer,
+// float and decimal, other primitives by value, arrays and maps element by
+// element and everything else by identity. Containers that hold themselves
+// are equal when they have the same shape.
+func Equals(left, right Object) bool {
+	return equals(left, right, make(map[[2]Object]bool))
+}
+
+// equals compares left and right, assuming the pairs of containers in
+// comparing are equal, since they are being compared already.
+func equals(left, right Object, comparing map[[2]Object]bool) bool {
+	if isNumber(left) && isNumber(right) {
+		switch {
+		case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
+			return left.(*Integer).Value == right.(*Integer).Value
+		case isSmall(left) && isSmall(right):
+			return toFloat(left) == toFloat(right)
+		default:
+			l, r := toRat(left), toRat(right)
+			if l == nil || r == nil {
+				return toFloat(left) == toFloat(right)
+			}
+			return l.Cmp(r) == 0
+		}
+	}
+
+	if left == right {
+		return true
+	}
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left.(type) {
+	case *Array, *Map:
+		pair := [2]Object{left, right}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+	}
+
+	switch left := left.(type) {
+	case *String:
+		return left.Value == right.(*String).Value
+	case *Bool:
+		return left.Value == right.(*Bool).Value
+	case *Null:
+		return true
+	case *Array:
+		r := right.(*Array)
+		if len(left.Elements) != len(r.Elements) {
+			return false
+		}
+		for i, e := range left.Elements {
+			if !equals(e, r.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *Map:
+		r := right.(*Map)
+		if len(left.Keys) != len(r.Keys) {
+			return false
+		}
+		for hash, pair := range left.Pairs {
+			other, ok := r.Pairs[hash]
+			if !ok || !equals(pair.Value, other.Value, comparing) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
+
+// integerOp applies op to two int64 values, moving to bigIntegerOp when
+// the result overflows.
+func integerOp(op string, left, right int64) Object {
+	switch op {
+	case "+":
+		if sum := left + right; (sum > left) == (right > 0) {
+			return &Integer{Value: sum}
+		}
+	case "-":
+		if diff := left - right; (diff < left) == (right > 0) {
+			return &Integer{Value: diff}
+		}
+	case "*":
+		product := left * right
+		if left == 0 || product/left == right && !(left == -1 && right == math.MinInt64) {
+			return &Integer{Value: product}
+		}
+	case "/":
+		if right == 0 {
+			return NewError("division by zero")
+		}
+		if left != math.MinInt64 || right != -1 {
+			return &Integer{Value: left / right}
+		}
+	case "%":
+		if right == 0 {
+			return NewError("division by zero")
+		}
+		return &Integer{Value: left % right}
+	case "<":
+		return NativeBool(left < right)
+	case "<=":
+		return NativeBool(left <= right)
+	case ">":
+		return NativeBool(left > right)
+	case ">=":
+		return NativeBool(left >= right)
+	default:
+		return NewError("unknown operator: %s %s %s", INTEGER_OBJ, op, INTEGER_OBJ)
+	}
+
+	return bigIntegerOp(op, big.NewInt(left), big.NewInt(right))
+}
+
+func bigIntegerOp(op string, left, right *big.Int) Object {
+	switch op {
+	case "+":
+		return NewInteger(new(big.Int).Add(left, right))
+	case "-":
+		return NewInteger(new(big.Int).Sub(left, right))
+	case "*":
+		return NewInteger(new(big.Int).Mul(left, right))
+	case "/":
+		if right.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return NewInteger(new(big.Int).Quo(left, right))
+	case "%":
+		if right.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return NewInteger(new(big.Int).Rem(left, right))
+	}
+
+	if result := compareOp(op, left.Cmp(right)); result != nil {
+		return result
+	}
+
+	return NewError("unknown operator: %s %s %s", INTEGER_OBJ, op, INTEGER_OBJ)
+}
+
+func decimalOp(op string, left, right decimal.Decimal) Object {
+	switch op {
+	case "+":
+		return &Decimal{Value: left.Add(right)}
+	case "-":
+		return &Decimal{Value: left.Sub(right)}
+	case "*":
+		return &Decimal{Value: left.Mul(right)}
+	case "/":
+		if right.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return &Decimal{Value: left.Quo(right)}
+	case "%":
+		if right.Sign() == 0 {
+			return NewError("division by zero")
+		}
+		return &Decimal{Value: left.Rem(right)}
+	}
+
+	if result := compareOp(op, left.Cmp(right)); result != nil {
+		return result
+	}
+
+	return NewError("unknown operator: %s %s %s", DECIMAL_OBJ, op, DECIMAL_OBJ)
+}
+
+// compareOp turns the result of a three-way comparison into the result of
+// a relational operator, or returns nil when op is not one.
+func compareOp(op string, cmp int) Object {
+	switch op {
+	case "<":
+		return NativeBool(cmp < 0)
+	case "<=":
+		return NativeBool(cmp <= 0)
+	case ">":
+		return NativeBool(cmp > 0)
+	case ">=":
+		return NativeBool(cmp >= 0)
+	default:
+		return nil
+	}
+}
+
+func floatOp(op string, left, right float64) Object {
+	switch op {
+	case "+":
+		return &Float{Value: left + right}
+	case "-":
+		return &Float{Value: left - right}
+	case "*":
+		return &Float{Value: left * right}
+	case "/":
+		if right == 0 {
+			return NewError("division by zero")
+		}
+		return &Float{Value: left / right}
+	case "%":
+		if right == 0 {
+			return NewError("division by zero")
+		}
+		return &Float{Value: math.Mod(left, right)}
+	case "<":
+		return NativeBool(left < right)
+	case "<=":
+		return NativeBool(left <= right)
+	case ">":
+		return NativeBool(left > right)
+	case ">=":
+		return NativeBool(left >= right)
+	default:
+		return NewError("unknown operator: %s %s %s", FLOAT_OBJ, op, FLOAT_OBJ)
+	}
+}
+
+func stringOp(op string, left, right string) Object {
+	switch op {
+	case "+":
+		return &String{Value: left + right}
+	case "<":
+		return NativeBool(left < right)
+	case "<=":
+		return NativeBool(left <= right)
+	case ">":
+		return NativeBool(left > right)
+	case ">=":
+		return NativeBool(left >= right)
+	default:
+		return NewError("unknown operator: %s %s %s", STRING_OBJ, op, STRING_OBJ)
+	}
+}
+
+func isNumber(obj Object) bool {
+	return isExact(obj) || obj.Type() == FLOAT_OBJ
+}
+
+// isExact reports whether obj is a number without rounding errors.
+func isExact(obj Object) bool {
+	return isInteger(obj) || obj.Type() == DECIMAL_OBJ
+}
+
+func isInteger(obj Object) bool {
+	return obj.Type() == INTEGER_OBJ || obj.Type() == BIG_INTEGER_OBJ
+}
+
+// isSmall reports whether obj is a number that a float64 compares exactly
+// enough with floats.
+func isSmall(obj Object) bool {
+	return obj.Type() == INTEGER_OBJ || obj.Type() == FLOAT_OBJ
+}
+
+// compareNumbers applies a relational operator to two numbers exactly,
+// except for infinite and NaN floats, which are compared as floats.
+func compareNumbers(op string, left, right Object) Object {
+	l, r := toRat(left), toRat(right)
+	if l == nil || r == nil {
+		return floatOp(op, toFloat(left), toFloat(right))
+	}
+
+	return compareOp(op, l.Cmp(r))
+}
+
+func toFloat(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value)
+	case *BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *Float:
+		return obj.Value
+	case *Decimal:
+		f, _ := obj.Value.Rat().Float64()
+		return f
+	default:
+		return 0
+	}
+}
+
+func toBigInt(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInteger:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
+
+func toDecimal(obj Object) decimal.Decimal {
+	if obj, ok := obj.(*Decimal); ok {
+		return obj.Value
+	}
+
+	return decimal.FromInt(toBigInt(obj))
+}
+
+// toRat returns the exact value of a number, or nil for infinite and NaN
+// floats.
+func toRat(obj Object) *big.Rat {
+	switch obj := obj.(type) {
+	case *Integer:
+		return new(big.Rat).SetInt64(obj.Value)
+	case *BigInteger:
+		return new(big.Rat).SetInt(obj.Value)
+	case *Float:
+		return new(big.Rat).SetFloat64(obj.Value)
+	case *Decimal:
+		return obj.Value.Rat()
+	default:
+		return nil
+	}
+}
+
+// IndexOp evaluates left[index].
+func IndexOp(left, index Object) Object {
+	switch {
+	case left.Type() == STRING_OBJ && index.Type() == INTEGER_OBJ:
+		runes := []rune(left.(*String).Value)
+		i, ok := normalizeIndex(index.(*Integer).Value, len(runes))
+		if !ok {
+			return NewError("string index out of range: %d", index.(*Integer).Value)
+		}
+		return &String{Value: string(runes[i])}
+	case left.Type() == ARRAY_OBJ && index.Type() == INTEGER_OBJ:
+		elements := left.(*Array).Elements
+		i, ok := normalizeIndex(index.(*Integer).Value, len(elements))
+		if !ok {
+			return NewError("array index out of range: %d", index.(*Integer).Value)
+		}
+		return elements[i]
+	case left.Type() == MAP_OBJ:
+		key, ok := index.(Hashable)
+		if !ok {
+			return NewError("unusable as map key: %s", index.Type())
+		}
+		if pair, ok := left.(*Map).Pairs[key.HashKey()]; ok {
+			return pair.Value
+		}
+		return NULL
+	default:
+		return NewError("%s is not indexable by %s", left.Type(), index.Type())
+	}
+}
+
+// SetIndexOp evaluates left[index] = value and returns value.
+func SetIndexOp(left, index, value Object) Object {
+	switch {
+	case left.Type() == ARRAY_OBJ && index.Type() == INTEGER_OBJ:
+		elements := left.(*Array).Elements
+		i, ok := normalizeIndex(index.(*Integer).Value, len(elements))
+		if !ok {
+			return NewError("array index out of range: %d", index.(*Integer).Value)
+		}
+		elements[i] = value
+		return value
+	case left.Type() == MAP_OBJ:
+		key, ok := index.(Hashable)
+		if !ok {
+			return NewError("unusable as map key: %s", index.Type())
+		}
+		left.(*Map).Set(key, value)
+		return value
+	default:
+		return NewError("%s does not support index assignment by %s", left.Type(), index.Type())
+	}
+}
+
+// SliceOp evaluates left[start:end]. A null bound stands for the start or
+// the end of the sequence, negative bounds count from the end and bounds
+// past either end are clamped, so slicing never fails on a valid sequence.
+func SliceOp(left, start, end Object) Object {
+	var length int
+	switch left := left.(type) {
+	case *String:
+		length = len([]rune(left.Value))
+	case *Array:
+		length = len(left.Elements)
+	default:
+		return NewError("%s is not sliceable", left.Type())
+	}
+
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return err
+	}
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return err
+	}
+	if to < from {
+		to = from
+	}
+
+	switch left := left.(type) {
+	case *String:
+		return &String{Value: string([]rune(left.Value)[from:to])}
+	default:
+		elements := make([]Object, to-from)
+		copy(elements, left.(*Array).Elements[from:to])
+		return &Array{Elements: elements}
+	}
+}
+
+// PropertyOp evaluates left.name. On maps it is a shorthand for
+// left["name"].
+func PropertyOp(left Object, name string) Object {
+	if left.Type() == MAP_OBJ {
+		return IndexOp(left, &String{Value: name})
+	}
+
+	return NewError("%s has no property %q", left.Type(), name)
+}
+
+// SetPropertyOp evaluates left.name = value and returns value.
+func SetPropertyOp(left Object, name string, value Object) Object {
+	if left.Type() == MAP_OBJ {
+		return SetIndexOp(left, &String{Value: name}, value)
+	}
+
+	return NewError("cannot set property %q on %s", name, left.Type())
+}
+
+// sliceBound resolves a slice bound against a sequence of the given length,
+// using def when the bound is null.
+func sliceBound(bound Object, def, length int) (int, *Error) {
+	switch bound := bound.(type) {
+	case *Null:
+		return def, nil
+	case *Integer:
+		i := bound.Value
+		if i < 0 {
+			i += int64(length)
+		}
+		if i < 0 {
+			return 0, nil
+		}
+		if i > int64(length) {
+			return length, nil
+		}
+		return int(i), nil
+	default:
+		return 0, NewError("slice bounds must be integers, got %s", bound.Type())
+	}
+}
+
+// normalizeIndex resolves negative indices from the end of a sequence
+// and reports whether the result is in range.
+func normalizeIndex(index int64, length int) (int, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+
+	return int(index), true
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jellycat-io/eevee/token"
+)
+
+// TestGrammarPrecedence checks that the expression rules of grammar.bnf
+// are the ones the operator tables of the parser define.
+func TestGrammarPrecedence(t *testing.T) {
+	expected := expressionRules(t)
+
+	buf, err := os.ReadFile("../grammar.bnf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]bool)
+	for _, rule := range expected {
+		names[ruleName(rule)] = true
+	}
+
+	var documented []string
+	for _, line := range strings.Split(string(buf), "\n") {
+		if names[ruleName(line)] {
+			documented = append(documented, line)
+		}
+	}
+
+	if strings.Join(documented, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("grammar.bnf does not match the operator tables. Expected the rules:\n%s\ngot:\n%s",
+			strings.Join(expected, "\n"), strings.Join(documented, "\n"))
+	}
+}
+
+// expressionRules returns the rules of the expressions built from operators,
+// from the loosest precedence to the tightest. Each level of infix operators
+// is a rule whose operands are the next level. Prefix operators take as
+// operand the first level they do not bind tighter than.
+func expressionRules(t *testing.T) []string {
+	tokenNames := constantNames(t, "../token/token.go", "TokenType")
+	levelNames := constantNames(t, "operators.go", "precedence")
+
+	byLevel := make(map[precedence][]token.TokenType)
+	for tokenType, op := range infixOperators {
+		byLevel[op.precedence] = append(byLevel[op.precedence], tokenType)
+	}
+
+	var levels []precedence
+	for level := range byLevel {
+		if level != CALL {
+			levels = append(levels, level)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	ruleOf := func(level precedence) string {
+		return strings.ToLower(levelNames[strconv.Itoa(int(level))]) + "_expression"
+	}
+	// operandOf returns the rule of an operand parsed at level: the first
+	// level that binds at least as tightly.
+	operandOf := func(level precedence) string {
+		for _, l := range levels {
+			if l >= level {
+				return ruleOf(l)
+			}
+		}
+		return "unary_expression"
+	}
+	alternatives := func(tokenTypes []token.TokenType) []string {
+		var names []string
+		for _, tokenType := range tokenTypes {
+			names = append(names, tokenNames[string(tokenType)])
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	var rules, operatorRules []string
+	rule := func(name, definition string) string {
+		return fmt.Sprintf("%-28s::= %s", name, definition)
+	}
+
+	for _, level := range levels {
+		op := infixOperators[byLevel[level][0]]
+		name := ruleOf(level)
+
+		operators := alternatives(byLevel[level])
+		if level == EQUALITY {
+			// `is not` is folded into `!=` by parseInfixExpression.
+			operators = append(operators, "EQ NOT")
+		}
+		operator := operators[0]
+		if len(operators) > 1 {
+			operator = strings.TrimSuffix(name, "_expression") + "_operator"
+			operatorRules = append(operatorRules, rule(operator, strings.Join(operators, " | ")))
+		}
+
+		if op.associativity == rightAssoc {
+			rules = append(rules, rule(name, fmt.Sprintf("%s [ %s %s ]", operandOf(level+1), operator, name)))
+		} else {
+			rules = append(rules, rule(name, fmt.Sprintf("%s { %s %s }", operandOf(level+1), operator, operandOf(level+1))))
+		}
+	}
+
+	byOperand := make(map[precedence][]token.TokenType)
+	for tokenType, level := range prefixOperators {
+		byOperand[level] = append(byOperand[level], tokenType)
+	}
+	var operands []precedence
+	for level := range byOperand {
+		operands = append(operands, level)
+	}
+	sort.Slice(operands, func(i, j int) bool { return operands[i] > operands[j] })
+
+	var unary []string
+	for _, level := range operands {
+		operators := alternatives(byOperand[level])
+		operator := operators[0]
+		if len(operators) > 1 {
+			operator = "(" + strings.Join(operators, " | ") + ")"
+		}
+		unary = append(unary, operator+" "+operandOf(level))
+	}
+	unary = append(unary, "call_expression")
+	rules = append(rules, rule("unary_expression", strings.Join(unary, " | ")))
+
+	var postfix []string
+	for _, tokenType := range alternatives(byLevel[CALL]) {
+		switch tokenType {
+		case "LPAREN":
+			postfix = append(postfix, "arguments")
+		case "DOT":
+			postfix = append(postfix, "DOT identifier")
+		case "LBRACKET":
+			postfix = append(postfix, "LBRACKET expression RBRACKET", "slice")
+		default:
+			t.Fatalf("No grammar for the postfix operator %s", tokenType)
+		}
+	}
+	rules = append(rules, rule("call_expression", fmt.Sprintf("primary_expression { %s }", strings.Join(postfix, " | "))))
+
+	return append(rules, operatorRules...)
+}
+
+// constantNames returns the names of the constants of type typeName
+// declared in a Go file, by the value of their string literal or by their
+// position in an iota list.
+func constantNames(t *testing.T, path, typeName string) map[string]string {
+	file, err := goparser.ParseFile(gotoken.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]string)
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != gotoken.CONST {
+			continue
+		}
+
+		// An iota list gives its type to its first constant only.
+		first := gd.Specs[0].(*ast.ValueSpec)
+		if ident, ok := first.Type.(*ast.Ident); ok && ident.Name == typeName {
+			for i, spec := range gd.Specs {
+				names[strconv.Itoa(i)] = spec.(*ast.ValueSpec).Names[0].Name
+			}
+			continue
+		}
+
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if len(vs.Values) != 1 {
+				continue
+			}
+
+			// Typed conversions of string literals, as TokenType("=").
+			call, ok := vs.Values[0].(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 {
+				continue
+			}
+			fun, ok := call.Fun.(*ast.Ident)
+			lit, isLit := call.Args[0].(*ast.BasicLit)
+			if ok && isLit && fun.Name == typeName {
+				value, _ := strconv.Unquote(lit.Value)
+				names[value] = vs.Names[0].Name
+			}
+		}
+	}
+
+	return names
+}
+
+func ruleName(line string) string {
+	name, _, found := strings.Cut(line, "::=")
+	if !found {
+		return ""
+	}
+
+	return strings.TrimSpace(name)
+}
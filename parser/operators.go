@@ -0,0 +1,121 @@
+package parser
+
+import "github.com/jellycat-io/eevee/token"
+
+// precedence is the binding power of an operator. Higher binds tighter.
+type precedence int
+
+const (
+	LOWEST precedence = iota
+	ASSIGNMENT
+	LOGICAL_OR
+	LOGICAL_AND
+	LOGICAL_NOT
+	EQUALITY
+	RELATIONAL
+	ADDITIVE
+	MULTIPLICATIVE
+	PREFIX
+	CALL
+)
+
+type associativity int
+
+const (
+	leftAssoc associativity = iota
+	rightAssoc
+)
+
+// operatorKind selects the node an infix or postfix operator builds.
+type operatorKind int
+
+const (
+	assignmentOperator operatorKind = iota
+	logicalOperator
+	binaryOperator
+	callOperator
+	memberOperator
+)
+
+type operator struct {
+	precedence    precedence
+	associativity associativity
+	kind          operatorKind
+}
+
+// infixOperators drives the expression parser: adding an infix or postfix
+// operator only takes an entry here. The operator of the resulting node is
+// the canonical spelling of the token type, so `and` becomes `&&`. The
+// `is not` pair is folded into `!=` by parseInfixExpression.
+var infixOperators = map[token.TokenType]operator{
+	token.ASSIGN:         {ASSIGNMENT, rightAssoc, assignmentOperator},
+	token.PLUS_ASSIGN:    {ASSIGNMENT, rightAssoc, assignmentOperator},
+	token.MINUS_ASSIGN:   {ASSIGNMENT, rightAssoc, assignmentOperator},
+	token.STAR_ASSIGN:    {ASSIGNMENT, rightAssoc, assignmentOperator},
+	token.SLASH_ASSIGN:   {ASSIGNMENT, rightAssoc, assignmentOperator},
+	token.PERCENT_ASSIGN: {ASSIGNMENT, rightAssoc, assignmentOperator},
+	token.OR:             {LOGICAL_OR, leftAssoc, logicalOperator},
+	token.AND:            {LOGICAL_AND, leftAssoc, logicalOperator},
+	token.EQ:             {EQUALITY, leftAssoc, binaryOperator},
+	token.NOT_EQ:         {EQUALITY, leftAssoc, binaryOperator},
+	token.LT:             {RELATIONAL, leftAssoc, binaryOperator},
+	token.LT_EQ:          {RELATIONAL, leftAssoc, binaryOperator},
+	token.GT:             {RELATIONAL, leftAssoc, binaryOperator},
+	token.GT_EQ:          {RELATIONAL, leftAssoc, binaryOperator},
+	token.PLUS:           {ADDITIVE, leftAssoc, binaryOperator},
+	token.MINUS:          {ADDITIVE, leftAssoc, binaryOperator},
+	token.STAR:           {MULTIPLICATIVE, leftAssoc, binaryOperator},
+	token.SLASH:          {MULTIPLICATIVE, leftAssoc, binaryOperator},
+	token.PERCENT:        {MULTIPLICATIVE, leftAssoc, binaryOperator},
+	token.LPAREN:         {CALL, leftAssoc, callOperator},
+	token.DOT:            {CALL, leftAssoc, memberOperator},
+	token.LBRACKET:       {CALL, leftAssoc, memberOperator},
+}
+
+// prefixOperators maps unary operators to the precedence of their operand.
+// `not` binds looser than comparisons, so `not a == b` is `!(a == b)`.
+var prefixOperators = map[token.TokenType]precedence{
+	token.PLUS:  PREFIX,
+	token.MINUS: PREFIX,
+	token.BANG:  PREFIX,
+	token.NOT:   LOGICAL_NOT,
+}
+
+// canonicalOperators spells the operators whose token type is a keyword
+// name rather than the operator itself.
+var canonicalOperators = map[token.TokenType]string{
+	token.NOT: string(token.BANG),
+}
+
+// canonicalOperator returns the operator a node stores for tokenType.
+func canonicalOperator(tokenType token.TokenType) string {
+	if op, ok := canonicalOperators[tokenType]; ok {
+		return op
+	}
+
+	return string(tokenType)
+}
+
+// InfixPrecedence returns the precedence of the infix operator that a node
+// stores as op, such as "&&" or "+=", and whether it is right associative.
+// Printers use it to put back the parentheses of a syntax tree.
+func InfixPrecedence(op string) (int, bool) {
+	o, ok := infixOperators[token.TokenType(op)]
+	if !ok {
+		return int(CALL), false
+	}
+
+	return int(o.precedence), o.associativity == rightAssoc
+}
+
+// PrefixPrecedence returns the lowest precedence that the operand of the
+// prefix operator written op, such as "-" or "not", binds without
+// parentheses.
+func PrefixPrecedence(op string) int {
+	tokenType := token.TokenType(op)
+	if keyword, ok := token.Keywords[op]; ok {
+		tokenType = keyword
+	}
+
+	return int(prefixOperators[tokenType])
+}
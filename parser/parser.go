@@ -1,89 +1,101 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/decimal"
+	"github.com/jellycat-io/eevee/diagnostics"
+	"github.com/jellycat-io/eevee/lexer"
 	"github.com/jellycat-io/eevee/token"
 )
 
 var (
 	literalTypes = map[token.TokenType]bool{
-		token.INT:    true,
-		token.FLOAT:  true,
-		token.STRING: true,
-		token.TRUE:   true,
-		token.FALSE:  true,
-		token.NULL:   true,
-	}
-	complexAssignmentOps = map[token.TokenType]bool{
-		token.PLUS_ASSIGN:    true,
-		token.MINUS_ASSIGN:   true,
-		token.STAR_ASSIGN:    true,
-		token.SLASH_ASSIGN:   true,
-		token.PERCENT_ASSIGN: true,
-	}
-	nilExpression = ast.NewNullLiteral()
+		token.INT:          true,
+		token.FLOAT:        true,
+		token.DECIMAL:      true,
+		token.STRING:       true,
+		token.INTERP_START: true,
+		token.TRUE:         true,
+		token.FALSE:        true,
+		token.NULL:         true,
+	}
 )
 
-type ParseError struct {
-	Line    int
-	Column  int
-	Message string
-}
-
-func (pe *ParseError) Error() string {
-	return fmt.Errorf("[%d, %d] %s", pe.Line, pe.Column, pe.Message).Error()
+// TokenSource supplies the tokens that a parser reads, one at a time. After
+// the EOF token, it keeps returning EOF. A *lexer.Lexer is a TokenSource,
+// which scans a file or a stream as the parser needs its tokens.
+type TokenSource interface {
+	NextToken() token.Token
+	// Filename returns the name of the source in diagnostics.
+	Filename() string
 }
 
 type Parser struct {
-	tokens          []token.Token
-	currentTokenIdx int
-	currentToken    token.Token
-	errors          []ParseError
-	panicMode       bool
-	isREPL          bool
+	tokens         TokenSource
+	lookahead      []token.Token // tokens read from tokens after currentToken
+	consumed       int           // number of tokens advanced over
+	currentToken   token.Token
+	filename       string
+	diagnostics    diagnostics.List
+	panicMode      bool
+	isREPL         bool
+	lastEnd        token.Position // end of the last token consumed, other than layout
+	pendingDedents int
+	braceDepth     int // number of blocks in braces being parsed
 }
 
-func New(tokens []token.Token, isREPL bool) *Parser {
-	currentTokenIdx := 0
-	currentToken := tokens[currentTokenIdx]
-
+// New returns a parser for the tokens of src, which it reads as it parses
+// them. Its diagnostics name the source of src.
+func New(src TokenSource, isREPL bool) *Parser {
 	return &Parser{
-		tokens:          tokens,
-		currentTokenIdx: currentTokenIdx,
-		currentToken:    currentToken,
-		errors:          make([]ParseError, 0),
-		isREPL:          isREPL,
+		tokens:       src,
+		currentToken: src.NextToken(),
+		filename:     src.Filename(),
+		isREPL:       isREPL,
 	}
 }
 
-func (p *Parser) Errors() []string {
-	errMsgs := make([]string, len(p.errors))
-	for i, err := range p.errors {
-		errMsgs[i] = err.Error()
-	}
+// Diagnostics returns the syntax errors found by Parse, in the order they
+// were found.
+func (p *Parser) Diagnostics() diagnostics.List {
+	return p.diagnostics
+}
 
-	return errMsgs
+// Errors returns the diagnostics as text.
+func (p *Parser) Errors() []string {
+	return p.diagnostics.Strings()
 }
 
 func (p *Parser) Parse() *ast.Program {
-	if len(p.tokens) == 0 {
-		return nil
-	}
-
 	return p.parseProgram()
 }
 
+// parseProgram parses the whole source, so the program spans from its
+// first character to the end of the last line.
 func (p *Parser) parseProgram() *ast.Program {
-	return ast.NewProgram(p.parseStatements(token.EOF))
+	program := ast.NewProgram(p.parseStatements(token.EOF))
+	program.SetSpan(token.Position{Line: 1, Column: 1}, p.currentToken.Pos())
+
+	return program
 }
 
 func (p *Parser) parseStatements(stopTokens ...token.TokenType) []ast.Statement {
-	stmts := make([]ast.Statement, 0, len(p.tokens))
+	stmts := make([]ast.Statement, 0)
 
 	for !p.matchAny(stopTokens...) {
+		// Skip the lines that have no tokens, such as blank lines at the
+		// start of a file, and the dedents left over by an error in a
+		// bracketed literal, which only blocks can stop at.
+		if p.matchAny(token.EOL, token.DEDENT) {
+			p.advance()
+			continue
+		}
 		stmts = append(stmts, p.parseStatement())
 	}
 
@@ -91,6 +103,9 @@ func (p *Parser) parseStatements(stopTokens ...token.TokenType) []ast.Statement
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.currentToken.Pos()
+	consumed := p.consumed
+
 	var stmt ast.Statement
 	switch p.currentToken.Type {
 	case token.INDENT:
@@ -102,54 +117,130 @@ func (p *Parser) parseStatement() ast.Statement {
 	case token.WHILE, token.DO, token.FOR:
 		stmt = p.parseIterationStatement()
 	case token.FUNCTION:
-		stmt = p.parseFunctionDeclaration()
+		if p.peekToken().Type == token.IDENT {
+			stmt = p.parseFunctionDeclaration()
+		} else {
+			stmt = p.parseExpressionStatement()
+		}
 	case token.RETURN:
 		stmt = p.parseReturnStatement()
 	default:
 		stmt = p.parseExpressionStatement()
 	}
 
+	// Skip what is left of a statement with a syntax error, so that the
+	// next statement is checked on its own.
+	if p.panicMode {
+		if p.consumed == consumed && !p.matchAny(token.DEDENT, token.EOF) {
+			p.advance()
+		}
+		p.synchronize()
+		stmt = p.badStatement(start)
+	}
+
 	if p.match(token.EOL) {
 		p.eat(token.EOL)
 	}
 
+	// Close the indentation opened inside a block in braces whose closing
+	// brace is on a more indented line than the statement it belongs to.
+	for p.pendingDedents > 0 && p.match(token.DEDENT) {
+		p.eat(token.DEDENT)
+		p.pendingDedents--
+	}
+
 	return stmt
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	stmts := []ast.Statement{}
 	p.eat(token.INDENT)
+	start := p.currentToken.Pos()
 
 	if !p.match(token.DEDENT) {
-		stmts = append(stmts, p.parseStatements(token.DEDENT)...)
+		stmts = append(stmts, p.parseStatements(token.DEDENT, token.EOF)...)
 	}
 
+	block := ast.NewBlockStatement(stmts)
+	p.finish(block, start)
 	p.eat(token.DEDENT)
 
-	return ast.NewBlockStatement(stmts)
+	return block
+}
+
+// parseBraceBlock parses a block in braces, whose statements are separated
+// by semicolons or line breaks. The lines inside the braces can be indented
+// freely, but the indentation cannot close the block: that would mix the
+// two block styles.
+func (p *Parser) parseBraceBlock() *ast.BlockStatement {
+	open := p.eat(token.LBRACE)
+	stmts := []ast.Statement{}
+	depth := 0 // indentation opened inside the braces
+
+	p.braceDepth++
+loop:
+	for {
+		switch p.currentToken.Type {
+		case token.EOL, token.SEMI:
+			p.advance()
+		case token.INDENT:
+			depth++
+			p.advance()
+		case token.DEDENT:
+			if depth == 0 {
+				p.reportInvalid(diagnostics.Errorf(diagnostics.MixedBlockStyle, p.tokenSpan(open),
+					"Block opened with %q is closed by indentation", token.LBRACE).
+					WithLabel(p.span(p.lastEnd, p.lastEnd), fmt.Sprintf("expected %q after this line", token.RBRACE)).
+					WithNote("A block is either in braces or indented, not both"))
+				break loop
+			}
+			depth--
+			p.advance()
+		case token.RBRACE, token.EOF:
+			break loop
+		default:
+			stmts = append(stmts, p.parseStatement())
+		}
+	}
+	p.braceDepth--
+
+	if p.isAtEnd() {
+		p.report(diagnostics.Errorf(diagnostics.UnclosedDelimiter, p.tokenSpan(open),
+			"Unterminated block: expected %q to close %q", token.RBRACE, token.LBRACE).
+			WithLabel(p.tokenSpan(p.currentToken), "the file ends here"))
+	} else if p.match(token.RBRACE) {
+		p.eat(token.RBRACE)
+		// The indentation still open at the closing brace is closed after
+		// the statement the block belongs to.
+		p.pendingDedents += depth
+	}
+
+	block := ast.NewBlockStatement(stmts)
+	p.finish(block, open.Pos())
+
+	return block
 }
 
 func (p *Parser) parseFunctionDeclaration() *ast.FunctionDeclaration {
-	p.eat(token.FUNCTION)
+	start := p.eat(token.FUNCTION).Pos()
 	name := *p.parseIdentifier()
 	p.eat(token.LPAREN)
 
 	params := p.parseFunctionParameters()
 	p.eat(token.RPAREN)
 
-	if p.match(token.EOL) {
-		p.eat(token.EOL)
-	}
+	body := p.parseBody()
 
-	body := p.parseStatement()
+	fd := ast.NewFunctionDeclaration(name, params, body)
+	p.finish(fd, start)
 
-	return ast.NewFunctionDeclaration(name, params, body)
+	return fd
 }
 
 func (p *Parser) parseFunctionParameters() []ast.Identifier {
 	params := make([]ast.Identifier, 0)
 
-	for !p.match(token.RPAREN) {
+	for !p.match(token.RPAREN) && !p.isSyncPoint() {
 		params = append(params, *p.parseIdentifier())
 		for p.match(token.COMMA) {
 			p.eat(token.COMMA)
@@ -161,19 +252,31 @@ func (p *Parser) parseFunctionParameters() []ast.Identifier {
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	p.eat(token.RETURN)
-	if p.match(token.EOL) || p.match(token.DEDENT) || p.isAtEnd() {
-		return ast.NewReturnStatement(nilExpression)
+	start := p.eat(token.RETURN).Pos()
+
+	var value ast.Expression
+	// A function literal can end with a bare return inside an enclosing
+	// expression, as in `call(fn () return, 1)`.
+	if p.isAtLineEnd() || p.matchAny(token.RPAREN, token.RBRACKET, token.RBRACE, token.COMMA, token.SEMI) {
+		value = p.implicitNull(p.lastEnd)
+	} else {
+		value = p.parseExpression()
 	}
 
-	return ast.NewReturnStatement(p.parseExpression())
+	rs := ast.NewReturnStatement(value)
+	p.finish(rs, start)
+
+	return rs
 }
 
 func (p *Parser) parseVariableStatement() *ast.VariableStatement {
-	p.eat(token.LET)
+	start := p.eat(token.LET).Pos()
 	declarations := p.parseVariableDeclarationList()
 
-	return ast.NewVariableStatement(declarations)
+	vs := ast.NewVariableStatement(declarations)
+	p.finish(vs, start)
+
+	return vs
 }
 
 func (p *Parser) parseVariableDeclarationList() []*ast.VariableDeclaration {
@@ -194,10 +297,13 @@ func (p *Parser) parseVariableDeclaration() *ast.VariableDeclaration {
 	if !p.match(token.COMMA) && p.match(token.ASSIGN) {
 		init = p.parseVariableInitializer()
 	} else {
-		init = nilExpression
+		init = p.implicitNull(ident.End())
 	}
 
-	return ast.NewVariableDeclaration(ident, init)
+	vd := ast.NewVariableDeclaration(ident, init)
+	p.finish(vd, ident.Pos())
+
+	return vd
 }
 
 func (p *Parser) parseVariableInitializer() ast.Expression {
@@ -214,62 +320,64 @@ func (p *Parser) parseIterationStatement() ast.Statement {
 	case token.FOR:
 		return p.parseForStatement()
 	default:
-		return nil
+		return p.badStatement(p.currentToken.Pos())
 	}
 }
 
 func (p *Parser) parseWhileStatement() *ast.WhileStatement {
-	p.eat(token.WHILE)
+	keyword := p.eat(token.WHILE)
 	cond := p.parseExpression()
-	p.eat(token.DO)
-	if p.match(token.EOL) {
-		p.eat(token.EOL)
-	}
-	body := p.parseStatement()
+	p.eatAfter(token.DO, keyword)
+	body := p.parseBody()
+
+	ws := ast.NewWhileStatement(cond, body)
+	p.finish(ws, keyword.Pos())
 
-	return ast.NewWhileStatement(cond, body)
+	return ws
 }
 
 func (p *Parser) parseDoWhileStatement() *ast.DoWhileStatement {
-	p.eat(token.DO)
-	body := p.parseStatement()
-	p.eat(token.WHILE)
+	keyword := p.eat(token.DO)
+	body := p.parseBody()
+	p.eatAfter(token.WHILE, keyword)
 	if p.match(token.EOL) {
 		p.eat(token.EOL)
 	}
 	cond := p.parseExpression()
 
-	return ast.NewDoWhileStatement(cond, body)
+	dws := ast.NewDoWhileStatement(cond, body)
+	p.finish(dws, keyword.Pos())
+
+	return dws
 }
 
 func (p *Parser) parseForStatement() *ast.ForStatement {
-	p.eat(token.FOR)
+	keyword := p.eat(token.FOR)
 
 	var init ast.Node
 	if !p.match(token.SEMI) {
 		init = p.parseForStatementInitializer()
 	}
-	p.eat(token.SEMI)
+	p.eatAfter(token.SEMI, keyword)
 
 	var cond ast.Expression
 	if !p.match(token.SEMI) {
 		cond = p.parseExpression()
 	}
-	p.eat(token.SEMI)
+	p.eatAfter(token.SEMI, keyword)
 
 	var iter ast.Expression
 	if !p.match(token.DO) {
 		iter = p.parseExpression()
 	}
-	p.eat(token.DO)
+	p.eatAfter(token.DO, keyword)
 
-	if p.match(token.EOL) {
-		p.eat(token.EOL)
-	}
+	body := p.parseBody()
 
-	body := p.parseStatement()
+	fs := ast.NewForStatement(init, cond, iter, body)
+	p.finish(fs, keyword.Pos())
 
-	return ast.NewForStatement(init, cond, iter, body)
+	return fs
 }
 
 func (p *Parser) parseForStatementInitializer() ast.Node {
@@ -281,43 +389,40 @@ func (p *Parser) parseForStatementInitializer() ast.Node {
 }
 
 func (p *Parser) parseIfStatement() *ast.IfStatement {
-	p.eat(token.IF)
+	keyword := p.eat(token.IF)
 	condition := p.parseExpression()
-	p.eat(token.THEN)
-
-	if p.match(token.EOL) {
-		p.eat(token.EOL)
-	}
+	p.eatAfter(token.THEN, keyword)
 
-	consequent := p.parseStatement()
+	consequent := p.parseBody()
 
 	var alternate ast.Statement
 	if p.match(token.ELSE) {
 		p.eat(token.ELSE)
-
-		if p.match(token.EOL) {
-			p.eat(token.EOL)
-		}
-
-		alternate = p.parseStatement()
+		alternate = p.parseBody()
 	} else {
 		alternate = nil
 	}
 
-	return ast.NewIfStatement(condition, consequent, alternate)
+	is := ast.NewIfStatement(condition, consequent, alternate)
+	p.finish(is, keyword.Pos())
+
+	return is
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	exp := p.parseExpression()
-	return ast.NewExpressionStatement(exp)
+	start := p.currentToken.Pos()
+	es := ast.NewExpressionStatement(p.parseExpression())
+	p.finish(es, start)
+
+	return es
 }
 
 func (p *Parser) parseExpression() ast.Expression {
 	if p.currentToken.Type == token.ILLEGAL {
 		t := p.currentToken
 		p.advance() // Skip the illegal token
-		p.error(t.Line, t.Column, fmt.Sprintf("Unexpected token: %q", t.Type))
-		return nilExpression
+		p.error(diagnostics.UnexpectedToken, t, "Unexpected token: %q", t.Type)
+		return p.badExpression(t.Pos())
 	}
 
 	var exp ast.Expression
@@ -328,150 +433,289 @@ func (p *Parser) parseExpression() ast.Expression {
 		exp = p.parseAssignmentExpression()
 	}
 
-	// If we've encountered an error and haven't consumed a new token,
-	// enter panic mode and synchronize.
-	if p.panicMode {
-		p.synchronize()
-	}
-
 	return exp
 }
 
 func (p *Parser) parseAssignmentExpression() ast.Expression {
-	left := p.parseLogicalOrExpression()
+	return p.parseOperatorExpression(LOWEST)
+}
 
-	if !isAssignmentOperator(p.currentToken.Type) {
-		return left
-	}
+// parseOperatorExpression parses an operand followed by every operator
+// binding tighter than minPrecedence, folding them into left-associative
+// chains unless the operator table says otherwise.
+func (p *Parser) parseOperatorExpression(minPrecedence precedence) ast.Expression {
+	start := p.currentToken.Pos()
+	left := p.parsePrefixExpression()
+
+	for {
+		op, ok := infixOperators[p.currentToken.Type]
+		if !ok || op.precedence <= minPrecedence {
+			return left
+		}
 
-	return ast.NewAssignmentExpression(
-		p.parseAssignmentOperator().Literal,
-		p.checkValidAssignmentTarget(left),
-		p.parseAssignmentExpression(),
-	)
+		left = p.parseInfixExpression(left, op, start)
+	}
 }
 
-func (p *Parser) parseLogicalOrExpression() ast.Expression {
-	return p.parseLogicalExpression(p.parseLogicalAndExpression, token.OR)
-}
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	prec, ok := prefixOperators[p.currentToken.Type]
+	if !ok {
+		return p.parsePrimaryExpression()
+	}
 
-func (p *Parser) parseLogicalAndExpression() ast.Expression {
-	return p.parseLogicalExpression(p.parseEqualityExpression, token.AND)
+	op := p.eat(p.currentToken.Type)
+	ue := ast.NewUnaryExpression(canonicalOperator(op.Type), p.parseOperatorExpression(prec-1))
+	p.finish(ue, op.Pos())
+
+	return ue
 }
 
-func (p *Parser) parseLogicalExpression(builder func() ast.Expression, ops ...token.TokenType) ast.Expression {
-	exp := builder()
+// parseInfixExpression parses the operator following left and its right
+// operand. start is the position of left, where the result begins.
+func (p *Parser) parseInfixExpression(left ast.Expression, op operator, start token.Position) ast.Expression {
+	switch op.kind {
+	case callOperator:
+		ce := ast.NewCallExpression(left, p.parseArguments())
+		p.finish(ce, start)
+		return ce
+	case memberOperator:
+		return p.parseMemberAccess(left, start)
+	}
 
-	for _, op := range ops {
-		if p.match(op) {
-			op_lit := p.eat(op).Literal
-			if op_lit == "and" {
-				op_lit = "&&"
-			}
-			if op_lit == "or" {
-				op_lit = "||"
-			}
-			right := builder()
-			exp = ast.NewLogicalExpression(op_lit, exp, right)
-			break
-		}
+	if op.kind == assignmentOperator {
+		left = p.checkValidAssignmentTarget(left)
 	}
 
-	return exp
-}
+	tok := p.eat(p.currentToken.Type)
+	operator := canonicalOperator(tok.Type)
+	if tok.Type == token.EQ && tok.Literal == "is" && p.match(token.NOT) {
+		p.eat(token.NOT)
+		operator = string(token.NOT_EQ)
+	}
 
-func (p *Parser) parseEqualityExpression() ast.Expression {
-	return p.parseBinaryExpression(p.parseRelationalExpression, token.EQ, token.NOT_EQ)
-}
+	rightPrecedence := op.precedence
+	if op.associativity == rightAssoc {
+		rightPrecedence--
+	}
 
-func (p *Parser) parseRelationalExpression() ast.Expression {
-	return p.parseBinaryExpression(p.parseAdditiveExpression, token.LT, token.LT_EQ, token.GT, token.GT_EQ)
-}
+	var exp interface {
+		ast.Expression
+		spanned
+	}
+	switch op.kind {
+	case assignmentOperator:
+		exp = ast.NewAssignmentExpression(operator, left, p.parseOperatorExpression(rightPrecedence))
+	case logicalOperator:
+		exp = ast.NewLogicalExpression(operator, left, p.parseOperatorExpression(rightPrecedence))
+	default:
+		exp = ast.NewBinaryExpression(operator, left, p.parseOperatorExpression(rightPrecedence))
+	}
+	p.finish(exp, start)
 
-func (p *Parser) parseAdditiveExpression() ast.Expression {
-	return p.parseBinaryExpression(p.parseMultiplicativeExpression, token.PLUS, token.MINUS)
+	return exp
 }
 
-func (p *Parser) parseMultiplicativeExpression() ast.Expression {
-	return p.parseBinaryExpression(p.parseUnaryExpression, token.STAR, token.SLASH, token.PERCENT)
-}
+func (p *Parser) parseArguments() []ast.Expression {
+	open := p.eat(token.LPAREN)
+	args := make([]ast.Expression, 0)
 
-func (p *Parser) parseBinaryExpression(builder func() ast.Expression, ops ...token.TokenType) ast.Expression {
-	exp := builder()
+	for !p.match(token.RPAREN) && !p.isAtLineEnd() {
+		args = append(args, p.parseAssignmentExpression())
 
-	for _, op := range ops {
-		if p.match(op) {
-			op_lit := p.eat(op).Literal
-			if op_lit == "is" {
-				op_lit = "=="
-			}
-			if op_lit == "not" {
-				op_lit = "!="
-			}
-			right := builder()
-			exp = ast.NewBinaryExpression(op_lit, exp, right)
+		if !p.match(token.COMMA) {
 			break
 		}
+		p.eat(token.COMMA)
 	}
 
-	return exp
-}
-
-func (p *Parser) parseUnaryExpression() ast.Expression {
-	var op string
-	switch p.currentToken.Type {
-	case token.PLUS:
-		op = p.eat(token.PLUS).Literal
-	case token.MINUS:
-		op = p.eat(token.MINUS).Literal
-	case token.BANG:
-		op = p.eat(token.BANG).Literal
+	if p.isAtLineEnd() {
+		end := "the line ends here"
+		if p.isAtEnd() {
+			end = "the file ends here"
+		}
+		p.report(diagnostics.Errorf(diagnostics.UnclosedDelimiter, p.tokenSpan(open),
+			"Unterminated argument list: expected %q to close %q", token.RPAREN, token.LPAREN).
+			WithLabel(p.tokenSpan(p.currentToken), end))
+		return args
 	}
 
-	if op != "" {
-		return ast.NewUnaryExpression(op, p.parseUnaryExpression())
+	if !p.match(token.RPAREN) {
+		p.report(diagnostics.Errorf(diagnostics.UnexpectedToken, p.tokenSpan(p.currentToken),
+			"Expected %q or %q in argument list, but got %q", token.COMMA, token.RPAREN, p.currentToken.Type).
+			WithLabel(p.tokenSpan(open), "the argument list starts here"))
+		return args
 	}
+	p.eat(token.RPAREN)
 
-	return p.parseLeftHandSideExpression()
+	return args
 }
 
-func (p *Parser) parseLeftHandSideExpression() ast.Expression {
-	return p.parseMemberExpression()
-}
+func (p *Parser) parseMemberAccess(obj ast.Expression, start token.Position) ast.Expression {
+	if p.match(token.DOT) {
+		p.eat(token.DOT)
+		me := ast.NewMemberExpression(false, obj, p.parseIdentifier())
+		p.finish(me, start)
+		return me
+	}
 
-func (p *Parser) parseMemberExpression() ast.Expression {
-	obj := p.parsePrimaryExpression()
+	p.eat(token.LBRACKET)
 
-	for p.match(token.DOT) || p.match(token.LBRACKET) {
-		if p.match(token.DOT) {
-			p.eat(token.DOT)
-			prop := p.parseIdentifier()
-			obj = ast.NewMemberExpression(false, obj, prop)
-		}
+	var low ast.Expression
+	if !p.match(token.COLON) {
+		low = p.parseAssignmentExpression()
+	}
 
-		if p.match(token.LBRACKET) {
-			p.eat(token.LBRACKET)
-			prop := p.parseExpression()
-			p.eat(token.RBRACKET)
-			obj = ast.NewMemberExpression(true, obj, prop)
-		}
+	if !p.match(token.COLON) {
+		p.eat(token.RBRACKET)
+		me := ast.NewMemberExpression(true, obj, low)
+		p.finish(me, start)
+		return me
+	}
+	p.eat(token.COLON)
+
+	var high ast.Expression
+	if !p.match(token.RBRACKET) {
+		high = p.parseAssignmentExpression()
 	}
+	p.eat(token.RBRACKET)
+
+	se := ast.NewSliceExpression(obj, low, high)
+	p.finish(se, start)
 
-	return obj
+	return se
 }
 
 func (p *Parser) parsePrimaryExpression() ast.Expression {
 	if isLiteral(p.currentToken.Type) {
 		return p.parseLiteral()
+	} else if p.match(token.LBRACKET) {
+		return p.parseArrayLiteral()
+	} else if p.match(token.LBRACE) {
+		return p.parseMapLiteral()
+	} else if p.match(token.FUNCTION) {
+		return p.parseFunctionLiteral()
 	} else if p.match(token.LPAREN) {
 		return p.parseGroupedExpression()
 	} else if p.match(token.IDENT) {
 		return p.parseIdentifier()
 	}
 
-	p.error(p.currentToken.Line, p.currentToken.Column, fmt.Sprintf("Unexpected token: %q", p.currentToken.Type))
-	p.advance()
-	return nil
+	tok := p.currentToken
+	p.error(diagnostics.UnexpectedToken, tok, "Unexpected token: %q", tok.Type)
+	// A token that can start a statement or end a line is left for the
+	// statement parser to recover at.
+	if !p.isSyncPoint() {
+		p.advance()
+	}
+
+	return p.badExpression(tok.Pos())
+}
+
+func (p *Parser) parseArrayLiteral() *ast.ArrayLiteral {
+	start := p.eat(token.LBRACKET).Pos()
+	elements := make([]ast.Expression, 0)
+
+	for !p.match(token.RBRACKET) && !p.isAtEnd() {
+		elements = append(elements, p.parseAssignmentExpression())
+
+		if !p.match(token.COMMA) {
+			break
+		}
+		p.eat(token.COMMA)
+	}
+	p.eat(token.RBRACKET)
+
+	al := ast.NewArrayLiteral(elements)
+	p.finish(al, start)
+
+	return al
+}
+
+func (p *Parser) parseMapLiteral() *ast.MapLiteral {
+	start := p.eat(token.LBRACE).Pos()
+	pairs := make([]*ast.MapPair, 0)
+
+	for !p.match(token.RBRACE) && !p.isAtEnd() {
+		pairs = append(pairs, p.parseMapPair())
+
+		if !p.match(token.COMMA) {
+			break
+		}
+		p.eat(token.COMMA)
+	}
+	p.eat(token.RBRACE)
+
+	ml := ast.NewMapLiteral(pairs)
+	p.finish(ml, start)
+
+	return ml
+}
+
+func (p *Parser) parseMapPair() *ast.MapPair {
+	var key ast.Expression
+	computed := false
+	start := p.currentToken.Pos()
+
+	switch p.currentToken.Type {
+	case token.IDENT:
+		key = p.parseIdentifier()
+	case token.STRING:
+		key = p.parseStringLiteral()
+	case token.LBRACKET:
+		p.eat(token.LBRACKET)
+		key = p.parseAssignmentExpression()
+		p.eat(token.RBRACKET)
+		computed = true
+	default:
+		p.error(diagnostics.InvalidMapKey, p.currentToken, "Invalid map key: %q", p.currentToken.Type)
+		if !p.isSyncPoint() {
+			p.advance()
+		}
+		key = p.badExpression(start)
+	}
+
+	p.eat(token.COLON)
+
+	mp := ast.NewMapPair(computed, key, p.parseAssignmentExpression())
+	p.finish(mp, start)
+
+	return mp
+}
+
+// parseFunctionLiteral parses an anonymous function. Its body is either an
+// indented block starting on the next line, or a return statement or an
+// expression on the same line. An inline body never consumes the end of
+// the line, which belongs to the enclosing statement.
+func (p *Parser) parseFunctionLiteral() *ast.FunctionLiteral {
+	start := p.eat(token.FUNCTION).Pos()
+	p.eat(token.LPAREN)
+
+	params := p.parseFunctionParameters()
+	p.eat(token.RPAREN)
+	if p.panicMode {
+		p.synchronize()
+	}
+
+	var body ast.Statement
+	switch {
+	case p.match(token.LBRACE):
+		body = p.parseBraceBlock()
+	case p.matchBlockStart():
+		p.eatLineEnds()
+		body = p.parseBlockStatement()
+	case p.match(token.RETURN):
+		body = p.parseReturnStatement()
+	default:
+		bodyStart := p.currentToken.Pos()
+		es := ast.NewExpressionStatement(p.parseAssignmentExpression())
+		p.finish(es, bodyStart)
+		body = es
+	}
+
+	fl := ast.NewFunctionLiteral(params, body)
+	p.finish(fl, start)
+
+	return fl
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
@@ -483,7 +727,11 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() *ast.Identifier {
-	return ast.NewIdentifier(p.eat(token.IDENT).Literal)
+	tok := p.eat(token.IDENT)
+	ident := ast.NewIdentifier(tok.Literal)
+	ident.SetSpan(tok.Pos(), tok.End())
+
+	return ident
 }
 
 func (p *Parser) parseLiteral() ast.Expression {
@@ -492,8 +740,12 @@ func (p *Parser) parseLiteral() ast.Expression {
 		return p.parseIntegerLiteral()
 	case token.FLOAT:
 		return p.parseFloatLiteral()
+	case token.DECIMAL:
+		return p.parseDecimalLiteral()
 	case token.STRING:
 		return p.parseStringLiteral()
+	case token.INTERP_START:
+		return p.parseInterpolatedString()
 	case token.TRUE:
 		return p.parseBoolLiteral(true)
 	case token.FALSE:
@@ -501,83 +753,171 @@ func (p *Parser) parseLiteral() ast.Expression {
 	case token.NULL:
 		return p.parseNullLiteral()
 	default:
-		p.error(p.currentToken.Line, p.currentToken.Column, fmt.Sprintf("Unexpected token: %q", p.currentToken.Type))
+		start := p.currentToken.Pos()
+		p.error(diagnostics.UnexpectedToken, p.currentToken, "Unexpected token: %q", p.currentToken.Type)
 		p.advance()
-		return nilExpression
+		return p.badExpression(start)
 	}
 }
 
-func (p *Parser) parseIntegerLiteral() *ast.IntegerLiteral {
+// parseIntegerLiteral parses an INT token, promoting it to a big integer
+// when it does not fit in an int64.
+func (p *Parser) parseIntegerLiteral() ast.Expression {
 	tok := p.eat(token.INT)
 
 	value, err := strconv.ParseInt(tok.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(tok.Literal, 0); ok {
+			bl := ast.NewBigIntegerLiteral(n)
+			bl.SetSpan(tok.Pos(), tok.End())
+			return bl
+		}
+	}
 	if err != nil {
-		p.error(p.currentToken.Line, p.currentToken.Column, fmt.Sprintf("Could not parse %q as integer", tok.Literal))
+		p.reportInvalid(diagnostics.Errorf(diagnostics.InvalidNumberLiteral, p.tokenSpan(tok), "Could not parse %q as integer", tok.Literal))
 	}
 
-	return ast.NewIntegerLiteral(int64(value))
+	il := ast.NewIntegerLiteral(value)
+	il.SetSpan(tok.Pos(), tok.End())
+
+	return il
 }
 
 func (p *Parser) parseFloatLiteral() *ast.FloatLiteral {
 	tok := p.eat(token.FLOAT)
 	value, err := strconv.ParseFloat(tok.Literal, 64)
 
+	if errors.Is(err, strconv.ErrRange) {
+		p.reportInvalid(diagnostics.Errorf(diagnostics.InvalidNumberLiteral, p.tokenSpan(tok), "Float literal %s is out of range", tok.Literal))
+	} else if err != nil {
+		p.reportInvalid(diagnostics.Errorf(diagnostics.InvalidNumberLiteral, p.tokenSpan(tok), "Could not parse %q as float", tok.Literal))
+	}
+
+	fl := ast.NewFloatLiteral(value)
+	fl.SetSpan(tok.Pos(), tok.End())
+
+	return fl
+}
+
+func (p *Parser) parseDecimalLiteral() *ast.DecimalLiteral {
+	tok := p.eat(token.DECIMAL)
+
+	literal := strings.ReplaceAll(strings.TrimSuffix(tok.Literal, "d"), "_", "")
+	value, err := decimal.Parse(literal)
 	if err != nil {
-		p.error(p.currentToken.Line, p.currentToken.Column, fmt.Sprintf("Could not parse %q as float", tok.Literal))
+		p.reportInvalid(diagnostics.Errorf(diagnostics.InvalidNumberLiteral, p.tokenSpan(tok), "Could not parse %q as decimal", tok.Literal))
 	}
 
-	return ast.NewFloatLiteral(float64(value))
+	dl := ast.NewDecimalLiteral(value)
+	dl.SetSpan(tok.Pos(), tok.End())
+
+	return dl
 }
 
 func (p *Parser) parseStringLiteral() *ast.StringLiteral {
 	tok := p.eat(token.STRING)
+	sl := ast.NewStringLiteral(lexer.Unquote(tok.Literal))
+	sl.SetSpan(tok.Pos(), tok.End())
+
+	return sl
+}
+
+// parseInterpolatedString parses the parts of a string split by the lexer
+// around its embedded expressions. Empty text parts are left out, and the
+// others span the token they come from, quotes and braces included.
+func (p *Parser) parseInterpolatedString() *ast.InterpolatedString {
+	parts := make([]ast.Expression, 0)
+	tok := p.eat(token.INTERP_START)
+	start := tok.Pos()
+
+	for {
+		if text := lexer.Unquote(tok.Literal); text != "" {
+			sl := ast.NewStringLiteral(text)
+			sl.SetSpan(tok.Pos(), tok.End())
+			parts = append(parts, sl)
+		}
+		if tok.Type == token.INTERP_END {
+			break
+		}
 
-	return ast.NewStringLiteral(tok.Literal[1 : len(tok.Literal)-1])
+		parts = append(parts, p.parseExpression())
+
+		if !p.matchAny(token.INTERP_MID, token.INTERP_END) {
+			p.report(diagnostics.Errorf(diagnostics.UnclosedDelimiter, p.tokenSpan(p.currentToken),
+				"Expected %q to close interpolation, but got %q", token.RBRACE, p.currentToken.Type).
+				WithLabel(p.tokenSpan(tok), "the interpolation starts here"))
+			break
+		}
+		tok = p.eat(p.currentToken.Type)
+	}
+
+	is := ast.NewInterpolatedString(parts)
+	p.finish(is, start)
+
+	return is
 }
 
 func (p *Parser) parseBoolLiteral(value bool) *ast.BoolLiteral {
+	var tok token.Token
 	switch value {
 	case true:
-		p.eat(token.TRUE)
+		tok = p.eat(token.TRUE)
 	case false:
-		p.eat(token.FALSE)
+		tok = p.eat(token.FALSE)
 	}
 
-	return ast.NewBoolLiteral(value)
+	bl := ast.NewBoolLiteral(value)
+	bl.SetSpan(tok.Pos(), tok.End())
+
+	return bl
 }
 
 func (p *Parser) parseNullLiteral() *ast.NullLiteral {
-	p.eat(token.NULL)
-	return nilExpression
+	tok := p.eat(token.NULL)
+	nl := ast.NewNullLiteral()
+	nl.SetSpan(tok.Pos(), tok.End())
+
+	return nl
 }
 
-func (p *Parser) parseAssignmentOperator() token.Token {
-	if p.match(token.ASSIGN) {
-		tok := p.eat(token.ASSIGN)
-		return tok
-	}
+// implicitNull returns the null standing for an expression the source
+// leaves out, such as the value of a bare return, with an empty span at pos.
+func (p *Parser) implicitNull(pos token.Position) *ast.NullLiteral {
+	nl := ast.NewNullLiteral()
+	nl.SetSpan(pos, pos)
 
-	tokenType := p.checkComplexAssignmentOperator()
-	tok := p.eat(tokenType)
-	return tok
+	return nl
 }
 
-func (p *Parser) checkComplexAssignmentOperator() token.TokenType {
-	switch p.currentToken.Type {
-	case token.PLUS_ASSIGN:
-		return token.PLUS_ASSIGN
-	case token.MINUS_ASSIGN:
-		return token.MINUS_ASSIGN
-	case token.STAR_ASSIGN:
-		return token.STAR_ASSIGN
-	case token.SLASH_ASSIGN:
-		return token.SLASH_ASSIGN
-	case token.PERCENT_ASSIGN:
-		return token.PERCENT_ASSIGN
-	default:
-		p.error(p.currentToken.Line, p.currentToken.Column, fmt.Sprintf("Expected assignment operator, but got %q", p.currentToken.Type))
-		return token.ILLEGAL
+// badExpression returns the placeholder for an expression with a syntax
+// error that starts at start and spans the tokens consumed since.
+func (p *Parser) badExpression(start token.Position) *ast.BadExpression {
+	be := ast.NewBadExpression()
+	p.finish(be, start)
+
+	return be
+}
+
+func (p *Parser) badStatement(start token.Position) *ast.BadStatement {
+	bs := ast.NewBadStatement()
+	p.finish(bs, start)
+
+	return bs
+}
+
+// spanned is implemented by every AST node through its embedded ast.Span.
+type spanned interface {
+	SetSpan(start, end token.Position)
+}
+
+// finish sets the span of node from start to the end of the last token
+// consumed.
+func (p *Parser) finish(node spanned, start token.Position) {
+	end := p.lastEnd
+	if end.Offset < start.Offset {
+		end = start
 	}
+	node.SetSpan(start, end)
 }
 
 func (p *Parser) checkValidAssignmentTarget(node ast.Expression) ast.Expression {
@@ -585,8 +925,10 @@ func (p *Parser) checkValidAssignmentTarget(node ast.Expression) ast.Expression
 	case *ast.Identifier, *ast.MemberExpression:
 		return node
 	default:
-		p.error(p.currentToken.Line, p.currentToken.Column, fmt.Sprintf("Invalid left-hand side in assignment expression: %v", node))
-		p.advance()
+		p.reportInvalid(diagnostics.Errorf(diagnostics.InvalidAssignmentTarget, p.tokenSpan(p.currentToken),
+			"Invalid left-hand side in assignment expression: %v", node).
+			WithLabel(p.span(node.Pos(), node.End()), "this cannot be assigned to").
+			WithNote("Only variables, properties and indexes can be assigned to"))
 		return node
 	}
 }
@@ -594,8 +936,13 @@ func (p *Parser) checkValidAssignmentTarget(node ast.Expression) ast.Expression
 func (p *Parser) eat(tokenType token.TokenType) token.Token {
 	tok := p.currentToken
 	if !p.match(tokenType) {
-		p.error(p.currentToken.Line, p.currentToken.Column, fmt.Sprintf("Expected %q, but got %q", tokenType, p.currentToken.Type))
+		p.error(diagnostics.UnexpectedToken, p.currentToken, "Expected %q, but got %q", tokenType, p.currentToken.Type)
 		tok = token.NewToken(token.ILLEGAL, "", p.currentToken.Line, p.currentToken.Column)
+		// Leave the end of the line or the next statement in place, so
+		// that the parser can recover there.
+		if p.isSyncPoint() {
+			return tok
+		}
 	}
 
 	p.advance()
@@ -603,40 +950,142 @@ func (p *Parser) eat(tokenType token.TokenType) token.Token {
 	return tok
 }
 
+// eatAfter eats a token of tokenType that continues the statement opened
+// by the keyword opener. When it is missing, the error points back at the
+// opener.
+func (p *Parser) eatAfter(tokenType token.TokenType, opener token.Token) token.Token {
+	if !p.match(tokenType) {
+		p.report(diagnostics.Errorf(diagnostics.UnexpectedToken, p.tokenSpan(p.currentToken),
+			"Expected %q, but got %q", tokenType, p.currentToken.Type).
+			WithLabel(p.tokenSpan(opener), fmt.Sprintf("this `%s` started here", opener.Literal)))
+	}
+
+	return p.eat(tokenType)
+}
+
+// parseBody parses the body of a compound statement: a block in braces, a
+// statement on the same line as its header, or the block on the next
+// lines. When the header has a syntax error, the rest of it is skipped
+// first, so that the body is still checked, and a missing body is not
+// reported again.
+func (p *Parser) parseBody() ast.Statement {
+	broken := p.panicMode
+	if broken {
+		p.synchronize()
+	}
+
+	if p.match(token.LBRACE) {
+		return p.parseBraceBody()
+	}
+
+	if p.matchBlockStart() {
+		p.eatLineEnds()
+	} else if p.match(token.EOL) {
+		p.eat(token.EOL)
+	}
+
+	if p.match(token.LBRACE) {
+		return p.parseBraceBody()
+	}
+
+	if broken && p.matchAny(token.DEDENT, token.EOF) {
+		return p.badStatement(p.currentToken.Pos())
+	}
+
+	return p.parseStatement()
+}
+
+// parseBraceBody parses a body in braces, which cannot go on with an
+// indented block.
+func (p *Parser) parseBraceBody() ast.Statement {
+	open := p.currentToken
+	block := p.parseBraceBlock()
+
+	if p.matchBlockStart() {
+		next := p.peek(p.lineEnds() + 1)
+		p.reportInvalid(diagnostics.Errorf(diagnostics.MixedBlockStyle, p.tokenSpan(next),
+			"Indented block after a body in braces").
+			WithLabel(p.tokenSpan(open), "the body starts here").
+			WithNote("A block is either in braces or indented, not both"))
+	}
+
+	return block
+}
+
+// synchronize leaves panic mode after a syntax error, skipping tokens up to
+// the next point where a statement can start.
 func (p *Parser) synchronize() {
-	for !p.match(token.EOL) && !p.isAtEnd() {
+	for !p.isSyncPoint() {
 		p.advance()
 	}
 
-	if !p.isREPL && p.isAtEnd() {
-		p.eat(token.EOL)
+	p.panicMode = false
+}
+
+// isSyncPoint reports whether the current token ends a line, opens or
+// closes a block, or starts a statement with a keyword. A `fn` only starts
+// a statement when a name follows it, otherwise it is a function literal.
+// Semicolons and closing braces end statements inside blocks in braces.
+func (p *Parser) isSyncPoint() bool {
+	switch p.currentToken.Type {
+	case token.EOL, token.INDENT, token.DEDENT, token.EOF,
+		token.LET, token.IF, token.WHILE, token.FOR, token.RETURN:
+		return true
+	case token.SEMI, token.RBRACE:
+		return p.braceDepth > 0
+	case token.FUNCTION:
+		return p.peekToken().Type == token.IDENT
+	default:
+		return false
 	}
 }
 
 func (p *Parser) advance() {
-	p.currentTokenIdx++
+	if !p.matchAny(token.EOL, token.INDENT, token.DEDENT, token.EOF) {
+		p.lastEnd = p.currentToken.End()
+	}
+	p.consumed++
 
-	if p.currentTokenIdx < len(p.tokens) {
-		p.currentToken = p.tokens[p.currentTokenIdx]
+	if len(p.lookahead) > 0 {
+		p.currentToken = p.lookahead[0]
+		p.lookahead = p.lookahead[1:]
 	} else {
-		p.currentToken = token.NewToken(token.EOF, "", p.currentToken.Line, p.currentToken.Column+1)
+		p.currentToken = p.tokens.NextToken()
 	}
+}
 
-	p.panicMode = false
+// error reports an error at tok with a formatted message.
+func (p *Parser) error(code diagnostics.Code, tok token.Token, format string, args ...interface{}) {
+	p.report(diagnostics.Errorf(code, p.tokenSpan(tok), format, args...))
 }
 
-func (p *Parser) error(line, column int, msg string) {
+// report reports a syntax error and enters panic mode, in which the errors
+// that follow from it are not reported until the parser synchronizes.
+func (p *Parser) report(d diagnostics.Diagnostic) {
 	if p.panicMode {
-		return // Don't report multiple errors for the same token
+		return
 	}
-	err := ParseError{
-		Line:    line,
-		Column:  column,
-		Message: msg,
+	p.diagnostics = append(p.diagnostics, d)
+
+	p.panicMode = true
+}
+
+// reportInvalid reports an error in a construct that was parsed in full,
+// such as a number out of range. The parser is still in step with the
+// source, so it does not enter panic mode.
+func (p *Parser) reportInvalid(d diagnostics.Diagnostic) {
+	if p.panicMode {
+		return
 	}
-	p.errors = append(p.errors, err)
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+func (p *Parser) span(start, end token.Position) diagnostics.Span {
+	return diagnostics.Span{Filename: p.filename, Start: start, End: end}
+}
 
-	p.panicMode = true // Enter panic mode after an error
+func (p *Parser) tokenSpan(tok token.Token) diagnostics.Span {
+	return p.span(tok.Pos(), tok.End())
 }
 
 func (p *Parser) match(tokenType token.TokenType) bool {
@@ -653,38 +1102,55 @@ func (p *Parser) matchAny(tokenTypes ...token.TokenType) bool {
 }
 
 func (p *Parser) peekToken() token.Token {
-	if p.currentTokenIdx+1 < len(p.tokens) {
-		return p.tokens[p.currentTokenIdx+1]
-	}
-	return token.Token{}
+	return p.peek(1)
 }
 
-func (p *Parser) isAtEnd() bool {
-	return p.currentToken.Type == token.EOF
-}
+// peek returns the token n tokens after the current one, reading it from
+// the source when it was not read yet.
+func (p *Parser) peek(n int) token.Token {
+	for len(p.lookahead) < n {
+		p.lookahead = append(p.lookahead, p.tokens.NextToken())
+	}
 
-func isLiteral(tokenType token.TokenType) bool {
-	return literalTypes[tokenType]
+	return p.lookahead[n-1]
 }
 
-// func isBinaryOperator(tokenType token.TokenType) bool {
-// 	for _, tt := range binaryOperators {
-// 		if tt == tokenType {
-// 			return true
-// 		}
-// 	}
+// matchBlockStart reports whether the current line ends before an indented
+// block. The blank lines and the lines holding only a comment between them
+// end with an EOL of their own.
+func (p *Parser) matchBlockStart() bool {
+	return p.match(token.EOL) && p.peek(p.lineEnds()).Type == token.INDENT
+}
 
-// 	return false
-// }
+// lineEnds returns the number of EOL tokens from the current one.
+func (p *Parser) lineEnds() int {
+	if !p.match(token.EOL) {
+		return 0
+	}
 
-func isAssignmentOperator(tokenType token.TokenType) bool {
-	if tokenType == token.ASSIGN {
-		return true
+	n := 1
+	for p.peek(n).Type == token.EOL {
+		n++
 	}
 
-	if complexAssignmentOps[tokenType] {
-		return true
+	return n
+}
+
+func (p *Parser) eatLineEnds() {
+	for p.match(token.EOL) {
+		p.eat(token.EOL)
 	}
+}
 
-	return false
+func (p *Parser) isAtEnd() bool {
+	return p.currentToken.Type == token.EOF
+}
+
+// isAtLineEnd reports whether the current token ends the logical line.
+func (p *Parser) isAtLineEnd() bool {
+	return p.matchAny(token.EOL, token.INDENT, token.DEDENT, token.EOF)
+}
+
+func isLiteral(tokenType token.TokenType) bool {
+	return literalTypes[tokenType]
 }
@@ -1,22 +1,33 @@
 package parser
 
 import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/jellycat-io/eevee/ast"
+	"github.com/jellycat-io/eevee/decimal"
+	"github.com/jellycat-io/eevee/diagnostics"
 	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/source"
 	"github.com/jellycat-io/eevee/test"
+	"github.com/jellycat-io/eevee/token"
 )
 
 func TestParseProgram(t *testing.T) {
 	input := test.MakeInput(
+		``,
+		`# Pokémon`,
 		`42`,
 		`"eevee"`,
+		``,
 		`3.14`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, true)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -40,8 +51,8 @@ func TestParseBlockStatement(t *testing.T) {
 		`"flareon"`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, true)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -62,6 +73,172 @@ func TestParseBlockStatement(t *testing.T) {
 	}
 }
 
+func TestParseBlockAfterBlankLines(t *testing.T) {
+	input := test.MakeInput(
+		`if x then`,
+		`	# starts with a comment`,
+		`	print(x)`,
+		`fn f()`,
+		``,
+		`	return x`,
+		`let g = fn ()`,
+		`	# starts with a comment`,
+		`	return x`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeIfStatement(
+			makeIdentifier("x"),
+			makeBlockStatement(
+				makeExpressionStatement(makeCallExpression(makeIdentifier("print"), makeIdentifier("x"))),
+			),
+			nil,
+		),
+		makeFunctionDeclaration(
+			*makeIdentifier("f"),
+			makeFunctionParameters(),
+			makeBlockStatement(makeReturnStatement(makeIdentifier("x"))),
+		),
+		makeVariableStatement(
+			makeVariableDeclaration(
+				makeIdentifier("g"),
+				makeFunctionLiteral(
+					makeFunctionParameters(),
+					makeBlockStatement(makeReturnStatement(makeIdentifier("x"))),
+				),
+			),
+		),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseBraceBlock(t *testing.T) {
+	tests := []struct {
+		braces   string
+		indented string
+	}{
+		{
+			`if x then { y = 1; z = 2 } else { y = 2 }`,
+			test.MakeInput(`if x then`, `	y = 1`, `	z = 2`, `else`, `	y = 2`),
+		},
+		{
+			`while x < 10 do { x += 1 }`,
+			test.MakeInput(`while x < 10 do`, `	x += 1`),
+		},
+		{
+			`do { x += 1; } while x < 10`,
+			test.MakeInput(`do`, `	x += 1`, `while x < 10`),
+		},
+		{
+			`for let i = 0; i < 3; i += 1 do { x += i }`,
+			test.MakeInput(`for let i = 0; i < 3; i += 1 do`, `	x += i`),
+		},
+		{
+			`fn add(a, b) { return a + b }`,
+			test.MakeInput(`fn add(a, b)`, `	return a + b`),
+		},
+		{
+			`let f = fn (x) { return; }`,
+			test.MakeInput(`let f = fn (x)`, `	return`),
+		},
+		{
+			test.MakeInput(
+				`fn f(x) {`,
+				`	if x then {`,
+				`		return 1`,
+				`		}`,
+				`	return 2`,
+				`}`,
+				`f(1)`,
+			),
+			test.MakeInput(
+				`fn f(x)`,
+				`	if x then`,
+				`		return 1`,
+				`	return 2`,
+				`f(1)`,
+			),
+		},
+		{
+			test.MakeInput(`if x then`, `{`, `y`, `}`),
+			test.MakeInput(`if x then`, `	y`),
+		},
+		{
+			test.MakeInput(`fn add(a, b)`, `{`, `return a + b`, `}`),
+			test.MakeInput(`fn add(a, b)`, `	return a + b`),
+		},
+		{
+			test.MakeInput(`let v = f(1)`, `{`, `  a: 1,`, `  b: 2`, `}`),
+			test.MakeInput(`let v = f(1)`, `{ a: 1, b: 2 }`),
+		},
+		{
+			test.MakeInput(`let v = f(g(x))`, `{ a: 1 }`),
+			test.MakeInput(`let v = f(g(x))`, `{`, `	a: 1 }`),
+		},
+		{
+			test.MakeInput(`if v then {`, `    v = 2`, `  }`),
+			test.MakeInput(`if v then`, `	v = 2`),
+		},
+		{
+			test.MakeInput(`f(fn (x) {`, `        return x`, `    }, 1)`),
+			`f(fn (x) { return x }, 1)`,
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(test.MakeFile(tt.braces))
+		p := New(l, false)
+		program := p.Parse()
+
+		checkParserErrors(t, p)
+
+		expected := New(lexer.New(test.MakeFile(tt.indented)), false).Parse()
+		if program.String() != expected.String() {
+			t.Errorf("%q - Expected: %q, got %q", tt.braces, expected, program)
+		}
+	}
+}
+
+func TestParseBlockStyleDiagnostics(t *testing.T) {
+	tests := []struct {
+		input string
+		code  diagnostics.Code
+		span  string
+	}{
+		{test.MakeInput(`while y do`, `	if x then {`, `		a`, `	b`), diagnostics.MixedBlockStyle, "2:15-2:16"},
+		{test.MakeInput(`if x then { a }`, `	b`), diagnostics.MixedBlockStyle, "2:5-2:6"},
+		{test.MakeInput(`if x then {`, `	a`), diagnostics.UnclosedDelimiter, "1:11-1:12"},
+		{`if x then { a = ); b }`, diagnostics.UnexpectedToken, "1:17-1:18"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(test.MakeFile(tt.input))
+		p := New(l, false)
+		p.Parse()
+
+		diags := p.Diagnostics()
+		if len(diags) != 1 {
+			t.Errorf("%q - Expected 1 diagnostic, got %q", tt.input, p.Errors())
+			continue
+		}
+
+		d := diags[0]
+		span := fmt.Sprintf("%d:%d-%d:%d", d.Span.Start.Line, d.Span.Start.Column, d.Span.End.Line, d.Span.End.Column)
+		if d.Code != tt.code || span != tt.span {
+			t.Errorf("%q - Expected %s at %s, got %s at %s", tt.input, tt.code, tt.span, d.Code, span)
+		}
+	}
+}
+
 func TestParseFunctionDeclaration(t *testing.T) {
 	input := test.MakeInput(
 		`fn square(x)`,
@@ -70,8 +247,8 @@ func TestParseFunctionDeclaration(t *testing.T) {
 		`fn nothing() return`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, true)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -120,6 +297,80 @@ func TestParseFunctionDeclaration(t *testing.T) {
 	}
 }
 
+func TestParseFunctionLiteral(t *testing.T) {
+	input := test.MakeInput(
+		`let add = fn (a) fn (b) a + b`,
+		`let square = fn (x)`,
+		`	return x * x`,
+		`map(xs, fn (x) return x, 1)`,
+		`fn () null`,
+		`fn outer()`,
+		`	return fn ()`,
+		`		return 1`,
+		`outer()`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeVariableStatement(makeVariableDeclaration(
+			makeIdentifier("add"),
+			makeFunctionLiteral(
+				makeFunctionParameters(*makeIdentifier("a")),
+				makeExpressionStatement(makeFunctionLiteral(
+					makeFunctionParameters(*makeIdentifier("b")),
+					makeExpressionStatement(makeBinaryExpression(
+						"+",
+						makeIdentifier("a"),
+						makeIdentifier("b"),
+					)),
+				)),
+			),
+		)),
+		makeVariableStatement(makeVariableDeclaration(
+			makeIdentifier("square"),
+			makeFunctionLiteral(
+				makeFunctionParameters(*makeIdentifier("x")),
+				makeBlockStatement(makeReturnStatement(makeBinaryExpression(
+					"*",
+					makeIdentifier("x"),
+					makeIdentifier("x"),
+				))),
+			),
+		)),
+		makeExpressionStatement(makeCallExpression(
+			makeIdentifier("map"),
+			makeIdentifier("xs"),
+			makeFunctionLiteral(
+				makeFunctionParameters(*makeIdentifier("x")),
+				makeReturnStatement(makeIdentifier("x")),
+			),
+			makeIntegerLiteral(1),
+		)),
+		makeExpressionStatement(makeFunctionLiteral(
+			makeFunctionParameters(),
+			makeExpressionStatement(makeNullLiteral()),
+		)),
+		makeFunctionDeclaration(
+			*makeIdentifier("outer"),
+			makeFunctionParameters(),
+			makeBlockStatement(makeReturnStatement(makeFunctionLiteral(
+				makeFunctionParameters(),
+				makeBlockStatement(makeReturnStatement(makeIntegerLiteral(1))),
+			))),
+		),
+		makeExpressionStatement(makeCallExpression(makeIdentifier("outer"))),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
 func TestParseMemberExpression(t *testing.T) {
 	input := test.MakeInput(
 		`pokemon.level`,
@@ -130,8 +381,8 @@ func TestParseMemberExpression(t *testing.T) {
 		`pokedex.eevee.attacks["tackle"]`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, true)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -206,6 +457,305 @@ func TestParseMemberExpression(t *testing.T) {
 	}
 }
 
+func TestParseCallExpression(t *testing.T) {
+	input := test.MakeInput(
+		`evolve()`,
+		`add(1, 2 * 3)`,
+		`obj.method(1)(2)[0]`,
+		`pokedex["eevee"].attack(target,)`,
+		`level = compute(base, bonus)`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(
+			makeCallExpression(makeIdentifier("evolve")),
+		),
+		makeExpressionStatement(
+			makeCallExpression(
+				makeIdentifier("add"),
+				makeIntegerLiteral(1),
+				makeBinaryExpression(
+					"*",
+					makeIntegerLiteral(2),
+					makeIntegerLiteral(3),
+				),
+			),
+		),
+		makeExpressionStatement(
+			makeMemberExpression(
+				true,
+				makeCallExpression(
+					makeCallExpression(
+						makeMemberExpression(
+							false,
+							makeIdentifier("obj"),
+							makeIdentifier("method"),
+						),
+						makeIntegerLiteral(1),
+					),
+					makeIntegerLiteral(2),
+				),
+				makeIntegerLiteral(0),
+			),
+		),
+		makeExpressionStatement(
+			makeCallExpression(
+				makeMemberExpression(
+					false,
+					makeMemberExpression(
+						true,
+						makeIdentifier("pokedex"),
+						makeStringLiteral("eevee"),
+					),
+					makeIdentifier("attack"),
+				),
+				makeIdentifier("target"),
+			),
+		),
+		makeExpressionStatement(
+			makeAssignmentExpression(
+				"=",
+				makeIdentifier("level"),
+				makeCallExpression(
+					makeIdentifier("compute"),
+					makeIdentifier("base"),
+					makeIdentifier("bonus"),
+				),
+			),
+		),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseCallExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`add(1, 2`, `[1, 4] Unterminated argument list: expected ")" to close "("`},
+		{test.MakeInput(`evolve(`, `eevee`), `[1, 7] Unterminated argument list: expected ")" to close "("`},
+		{`add(1 2)`, `[1, 7] Expected "," or ")" in argument list, but got "INT"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(test.MakeFile(tt.input))
+		p := New(l, false)
+		p.Parse()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q - Expected error %q, got none", tt.input, tt.expected)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("%q - Wrong error. Expected %q, got %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParseArrayLiteral(t *testing.T) {
+	input := test.MakeInput(
+		`[]`,
+		`[1, "eevee", 2 * 3]`,
+		`[[1], [2,],]`,
+		`let starters = [`,
+		`	"bulbasaur",`,
+		`	"charmander",`,
+		`	"squirtle",`,
+		`]`,
+		`let evolutions = ["vaporeon",`,
+		`	"jolteon"]`,
+		`starters[0]`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeArrayLiteral()),
+		makeExpressionStatement(makeArrayLiteral(
+			makeIntegerLiteral(1),
+			makeStringLiteral("eevee"),
+			makeBinaryExpression(
+				"*",
+				makeIntegerLiteral(2),
+				makeIntegerLiteral(3),
+			),
+		)),
+		makeExpressionStatement(makeArrayLiteral(
+			makeArrayLiteral(makeIntegerLiteral(1)),
+			makeArrayLiteral(makeIntegerLiteral(2)),
+		)),
+		makeVariableStatement(makeVariableDeclaration(
+			makeIdentifier("starters"),
+			makeArrayLiteral(
+				makeStringLiteral("bulbasaur"),
+				makeStringLiteral("charmander"),
+				makeStringLiteral("squirtle"),
+			),
+		)),
+		makeVariableStatement(makeVariableDeclaration(
+			makeIdentifier("evolutions"),
+			makeArrayLiteral(
+				makeStringLiteral("vaporeon"),
+				makeStringLiteral("jolteon"),
+			),
+		)),
+		makeExpressionStatement(makeMemberExpression(
+			true,
+			makeIdentifier("starters"),
+			makeIntegerLiteral(0),
+		)),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseMapLiteral(t *testing.T) {
+	input := test.MakeInput(
+		`{}`,
+		`{ "name": "eevee", level: 5, [key]: 1 + 1, }`,
+		`let pokemon = {`,
+		`	name: "eevee",`,
+		`	types: ["normal"],`,
+		`	stats: {`,
+		`		hp: 55,`,
+		`	},`,
+		`}`,
+		`pokemon.name`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeMapLiteral()),
+		makeExpressionStatement(makeMapLiteral(
+			makeMapPair(false, makeStringLiteral("name"), makeStringLiteral("eevee")),
+			makeMapPair(false, makeIdentifier("level"), makeIntegerLiteral(5)),
+			makeMapPair(
+				true,
+				makeIdentifier("key"),
+				makeBinaryExpression("+", makeIntegerLiteral(1), makeIntegerLiteral(1)),
+			),
+		)),
+		makeVariableStatement(makeVariableDeclaration(
+			makeIdentifier("pokemon"),
+			makeMapLiteral(
+				makeMapPair(false, makeIdentifier("name"), makeStringLiteral("eevee")),
+				makeMapPair(false, makeIdentifier("types"), makeArrayLiteral(makeStringLiteral("normal"))),
+				makeMapPair(false, makeIdentifier("stats"), makeMapLiteral(
+					makeMapPair(false, makeIdentifier("hp"), makeIntegerLiteral(55)),
+				)),
+			),
+		)),
+		makeExpressionStatement(makeMemberExpression(
+			false,
+			makeIdentifier("pokemon"),
+			makeIdentifier("name"),
+		)),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseSliceExpression(t *testing.T) {
+	input := test.MakeInput(
+		`xs[1:3]`,
+		`xs[:n]`,
+		`xs[i:]`,
+		`xs[:]`,
+		`xs[1:][0]`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeSliceExpression(
+			makeIdentifier("xs"),
+			makeIntegerLiteral(1),
+			makeIntegerLiteral(3),
+		)),
+		makeExpressionStatement(makeSliceExpression(
+			makeIdentifier("xs"),
+			nil,
+			makeIdentifier("n"),
+		)),
+		makeExpressionStatement(makeSliceExpression(
+			makeIdentifier("xs"),
+			makeIdentifier("i"),
+			nil,
+		)),
+		makeExpressionStatement(makeSliceExpression(
+			makeIdentifier("xs"),
+			nil,
+			nil,
+		)),
+		makeExpressionStatement(makeMemberExpression(
+			true,
+			makeSliceExpression(
+				makeIdentifier("xs"),
+				makeIntegerLiteral(1),
+				nil,
+			),
+			makeIntegerLiteral(0),
+		)),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+
+	data, err := json.Marshal(ast.Statements[1])
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var decoded struct {
+		Expression struct {
+			Low   json.RawMessage `json:"low"`
+			High  json.RawMessage `json:"high"`
+			Start token.Position  `json:"start"`
+			End   token.Position  `json:"end"`
+		} `json:"expression"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	slice := decoded.Expression
+	if string(slice.Low) != "null" || !strings.Contains(string(slice.High), `"name":"n"`) {
+		t.Errorf("Expected the bounds null and n in the JSON AST, got %s", data)
+	}
+	if slice.Start != (token.Position{Line: 2, Column: 1, Offset: 8}) || slice.End != (token.Position{Line: 2, Column: 7, Offset: 14}) {
+		t.Errorf("Expected the span of the slice in the JSON AST, got %s", data)
+	}
+}
+
 func TestParseVariableStatement(t *testing.T) {
 	input := test.MakeInput(
 		`let pokemon = "eevee"`,
@@ -216,8 +766,8 @@ func TestParseVariableStatement(t *testing.T) {
 		`let x = y = 42`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, true)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -284,8 +834,8 @@ func TestParseAssignmentExpression(t *testing.T) {
 		`level = 40 + 2`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, false)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -348,8 +898,8 @@ func TestParseWhileStatement(t *testing.T) {
 		`do x += 1 while x < 10`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, false)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -410,8 +960,8 @@ func TestParseForStatement(t *testing.T) {
 		`for ;; do y += 1`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, false)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -493,7 +1043,7 @@ func TestParseIfStatement(t *testing.T) {
 		`	pokemon = "ivysaur"`,
 		`else`,
 		`	pokemon = "bulbasaur"`,
-		`if (eevee not null) then`,
+		`if (eevee is not null) then`,
 		`	if evo_cond is solar_stone then`,
 		`		eevee = "leafeon"`,
 		`	if evo_cond == friendship_plus_exchange then eevee = "sylveon"`,
@@ -501,8 +1051,8 @@ func TestParseIfStatement(t *testing.T) {
 		`else eevee = "missingno"`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, false)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -623,8 +1173,8 @@ func TestParseLogicalExpression(t *testing.T) {
 		`(5 == 5 || 5 < 10) && 5 > 1`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, false)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -766,14 +1316,14 @@ func TestParseBinaryExpression(t *testing.T) {
 		`2 == 2`,
 		`2 is 2`,
 		`4 != 2`,
-		`4 not 2`,
-		`2 not 2 < 2`,
+		`4 is not 2`,
+		`2 is not 2 < 2`,
 		`2 == 2 < 2 + 2`,
 		`-2 + 2`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, false)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -926,53 +1476,398 @@ func TestParseBinaryExpression(t *testing.T) {
 	}
 }
 
-func TestUnaryExpression(t *testing.T) {
+func TestParseOperatorChains(t *testing.T) {
 	input := test.MakeInput(
-		`-42`,
-		`--42`,
-		`!eevee`,
-		`!!eevee`,
-		`!(2 is 2)`,
+		`1 + 2 + 3`,
+		`a * b / c % d`,
+		`x or y or z`,
+		`a and b or c and d`,
+		`1 - 2 * 3 - 4`,
+		`a = b += c`,
+		`-a * -b`,
+		`!a.b(c)`,
+		`a < b == c > d`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, true)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
 
 	expectedAst := makeProgram(
-		makeExpressionStatement(makeUnaryExpression(
-			"-",
-			makeIntegerLiteral(42),
-		)),
-		makeExpressionStatement(makeUnaryExpression(
-			"-",
-			makeUnaryExpression(
-				"-",
-				makeIntegerLiteral(42),
-			),
-		)),
-		makeExpressionStatement(makeUnaryExpression(
-			"!",
-			makeIdentifier("eevee"),
-		)),
-		makeExpressionStatement(makeUnaryExpression(
-			"!",
-			makeUnaryExpression(
-				"!",
-				makeIdentifier("eevee"),
-			),
-		)),
-		makeExpressionStatement(makeUnaryExpression(
-			"!",
+		makeExpressionStatement(makeBinaryExpression(
+			"+",
 			makeBinaryExpression(
-				"==",
-				makeIntegerLiteral(2),
+				"+",
+				makeIntegerLiteral(1),
 				makeIntegerLiteral(2),
 			),
+			makeIntegerLiteral(3),
 		)),
-	)
+		makeExpressionStatement(makeBinaryExpression(
+			"%",
+			makeBinaryExpression(
+				"/",
+				makeBinaryExpression(
+					"*",
+					makeIdentifier("a"),
+					makeIdentifier("b"),
+				),
+				makeIdentifier("c"),
+			),
+			makeIdentifier("d"),
+		)),
+		makeExpressionStatement(makeLogicalExpression(
+			"||",
+			makeLogicalExpression(
+				"||",
+				makeIdentifier("x"),
+				makeIdentifier("y"),
+			),
+			makeIdentifier("z"),
+		)),
+		makeExpressionStatement(makeLogicalExpression(
+			"||",
+			makeLogicalExpression(
+				"&&",
+				makeIdentifier("a"),
+				makeIdentifier("b"),
+			),
+			makeLogicalExpression(
+				"&&",
+				makeIdentifier("c"),
+				makeIdentifier("d"),
+			),
+		)),
+		makeExpressionStatement(makeBinaryExpression(
+			"-",
+			makeBinaryExpression(
+				"-",
+				makeIntegerLiteral(1),
+				makeBinaryExpression(
+					"*",
+					makeIntegerLiteral(2),
+					makeIntegerLiteral(3),
+				),
+			),
+			makeIntegerLiteral(4),
+		)),
+		makeExpressionStatement(makeAssignmentExpression(
+			"=",
+			makeIdentifier("a"),
+			makeAssignmentExpression(
+				"+=",
+				makeIdentifier("b"),
+				makeIdentifier("c"),
+			),
+		)),
+		makeExpressionStatement(makeBinaryExpression(
+			"*",
+			makeUnaryExpression("-", makeIdentifier("a")),
+			makeUnaryExpression("-", makeIdentifier("b")),
+		)),
+		makeExpressionStatement(makeUnaryExpression(
+			"!",
+			makeCallExpression(
+				makeMemberExpression(
+					false,
+					makeIdentifier("a"),
+					makeIdentifier("b"),
+				),
+				makeIdentifier("c"),
+			),
+		)),
+		makeExpressionStatement(makeBinaryExpression(
+			"==",
+			makeBinaryExpression(
+				"<",
+				makeIdentifier("a"),
+				makeIdentifier("b"),
+			),
+			makeBinaryExpression(
+				">",
+				makeIdentifier("c"),
+				makeIdentifier("d"),
+			),
+		)),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseInvalidAssignmentTarget(t *testing.T) {
+	l := lexer.New(test.MakeFile(`a + b = c`))
+	p := New(l, false)
+	p.Parse()
+
+	errors := p.Errors()
+	expected := `[1, 7] Invalid left-hand side in assignment expression: (BinaryExpression + (Identifier a) (Identifier b))`
+	if len(errors) == 0 || errors[0] != expected {
+		t.Fatalf("Expected error %q, got %q", expected, errors)
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		input string
+		code  diagnostics.Code
+		span  string
+		label string
+	}{
+		{`add(1 2)`, diagnostics.UnexpectedToken, "1:7-1:8", `1:4-1:5 the argument list starts here`},
+		{test.MakeInput(`add(1, 2`), diagnostics.UnclosedDelimiter, "1:4-1:5", `3:1-3:1 the file ends here`},
+		{`a + b = c`, diagnostics.InvalidAssignmentTarget, "1:7-1:8", `1:1-1:6 this cannot be assigned to`},
+		{`{1: 2}`, diagnostics.InvalidMapKey, "1:2-1:3", ``},
+		{test.MakeInput(`if x > 1`, `	x`), diagnostics.UnexpectedToken, "1:9-1:9", "1:1-1:3 this `if` started here"},
+		{test.MakeInput(`while x`, `	x`), diagnostics.UnexpectedToken, "1:8-1:8", "1:1-1:6 this `while` started here"},
+	}
+
+	format := func(span diagnostics.Span) string {
+		return fmt.Sprintf("%d:%d-%d:%d", span.Start.Line, span.Start.Column, span.End.Line, span.End.Column)
+	}
+
+	for _, tt := range tests {
+		file := source.NewFileSet(4).AddFile("main.eev", []byte(tt.input))
+		p := New(lexer.New(file), false)
+		p.Parse()
+
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			t.Errorf("%q - Expected a diagnostic, got none", tt.input)
+			continue
+		}
+
+		d := diags[0]
+		if d.Code != tt.code || d.Span.Filename != "main.eev" || format(d.Span) != tt.span {
+			t.Errorf("%q - Expected %s at main.eev %s, got %s at %s %s", tt.input, tt.code, tt.span, d.Code, d.Span.Filename, format(d.Span))
+		}
+
+		label := ""
+		if len(d.Labels) > 0 {
+			label = format(d.Labels[0].Span) + " " + d.Labels[0].Message
+		}
+		if label != tt.label {
+			t.Errorf("%q - Expected label %q, got %q", tt.input, tt.label, label)
+		}
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	input := test.MakeInput(
+		`let a = 1 +`,
+		`fn f(x,)`,
+		`	let y = )`,
+		`	return y`,
+		`if x > 1`,
+		`	print(x 1)`,
+		`let b = {1: 2}`,
+		`x = ]`,
+		`let c = 3`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	program := p.Parse()
+
+	expectedErrors := []string{
+		`[1, 12] Unexpected token: "EOL"`,
+		`[2, 8] Expected "IDENT", but got ")"`,
+		`[3, 13] Unexpected token: ")"`,
+		`[5, 9] Expected "THEN", but got "EOL"`,
+		`[6, 13] Expected "," or ")" in argument list, but got "INT"`,
+		`[7, 10] Invalid map key: "INT"`,
+		`[8, 5] Unexpected token: "]"`,
+	}
+
+	errors := p.Errors()
+	if strings.Join(errors, "\n") != strings.Join(expectedErrors, "\n") {
+		t.Fatalf("Expected errors:\n%s\ngot:\n%s", strings.Join(expectedErrors, "\n"), strings.Join(errors, "\n"))
+	}
+
+	expectedAst := makeProgram(
+		ast.NewBadStatement(),
+		makeFunctionDeclaration(
+			*makeIdentifier("f"),
+			makeFunctionParameters(*makeIdentifier("x"), *makeIdentifier("")),
+			makeBlockStatement(
+				ast.NewBadStatement(),
+				makeReturnStatement(makeIdentifier("y")),
+			),
+		),
+		makeIfStatement(
+			makeBinaryExpression(">", makeIdentifier("x"), makeIntegerLiteral(1)),
+			makeBlockStatement(ast.NewBadStatement()),
+			nil,
+		),
+		ast.NewBadStatement(),
+		ast.NewBadStatement(),
+		makeVariableStatement(
+			makeVariableDeclaration(makeIdentifier("c"), makeIntegerLiteral(3)),
+		),
+	)
+
+	if program.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, program)
+	}
+}
+
+func TestParseBadExpression(t *testing.T) {
+	input := test.MakeInput(
+		`if ) then`,
+		`	x`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	program := p.Parse()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Expected 1 error, got %q", p.Errors())
+	}
+
+	expectedAst := makeProgram(
+		makeIfStatement(
+			ast.NewBadExpression(),
+			makeBlockStatement(makeExpressionStatement(makeIdentifier("x"))),
+			nil,
+		),
+	)
+
+	if program.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, program)
+	}
+
+	cond := program.Statements[0].(*ast.IfStatement).Condition
+	if cond.Pos() != pos(1, 4, 3) || cond.End() != pos(1, 5, 4) {
+		t.Errorf("Expected the BadExpression to span 1:4-1:5, got %v-%v", cond.Pos(), cond.End())
+	}
+}
+
+func TestUnaryExpression(t *testing.T) {
+	input := test.MakeInput(
+		`-42`,
+		`--42`,
+		`!eevee`,
+		`!!eevee`,
+		`!(2 is 2)`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeUnaryExpression(
+			"-",
+			makeIntegerLiteral(42),
+		)),
+		makeExpressionStatement(makeUnaryExpression(
+			"-",
+			makeUnaryExpression(
+				"-",
+				makeIntegerLiteral(42),
+			),
+		)),
+		makeExpressionStatement(makeUnaryExpression(
+			"!",
+			makeIdentifier("eevee"),
+		)),
+		makeExpressionStatement(makeUnaryExpression(
+			"!",
+			makeUnaryExpression(
+				"!",
+				makeIdentifier("eevee"),
+			),
+		)),
+		makeExpressionStatement(makeUnaryExpression(
+			"!",
+			makeBinaryExpression(
+				"==",
+				makeIntegerLiteral(2),
+				makeIntegerLiteral(2),
+			),
+		)),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseNotOperator(t *testing.T) {
+	input := test.MakeInput(
+		`not done`,
+		`not not done`,
+		`not level >= 16`,
+		`not evolved and level >= 16`,
+		`!evolved == false`,
+		`eevee is not null`,
+		`eevee == not evolved`,
+		`if not done then x = 1`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeUnaryExpression(
+			"!",
+			makeIdentifier("done"),
+		)),
+		makeExpressionStatement(makeUnaryExpression(
+			"!",
+			makeUnaryExpression("!", makeIdentifier("done")),
+		)),
+		makeExpressionStatement(makeUnaryExpression(
+			"!",
+			makeBinaryExpression(
+				">=",
+				makeIdentifier("level"),
+				makeIntegerLiteral(16),
+			),
+		)),
+		makeExpressionStatement(makeLogicalExpression(
+			"&&",
+			makeUnaryExpression("!", makeIdentifier("evolved")),
+			makeBinaryExpression(
+				">=",
+				makeIdentifier("level"),
+				makeIntegerLiteral(16),
+			),
+		)),
+		makeExpressionStatement(makeBinaryExpression(
+			"==",
+			makeUnaryExpression("!", makeIdentifier("evolved")),
+			makeBoolLiteral(false),
+		)),
+		makeExpressionStatement(makeBinaryExpression(
+			"!=",
+			makeIdentifier("eevee"),
+			makeNullLiteral(),
+		)),
+		makeExpressionStatement(makeBinaryExpression(
+			"==",
+			makeIdentifier("eevee"),
+			makeUnaryExpression("!", makeIdentifier("evolved")),
+		)),
+		makeIfStatement(
+			makeUnaryExpression("!", makeIdentifier("done")),
+			makeExpressionStatement(makeAssignmentExpression(
+				"=",
+				makeIdentifier("x"),
+				makeIntegerLiteral(1),
+			)),
+			nil,
+		),
+	)
 
 	if ast.String() != expectedAst.String() {
 		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
@@ -989,8 +1884,8 @@ func TestParseLiteral(t *testing.T) {
 		`null`,
 	)
 
-	l := lexer.New(input, 4)
-	p := New(l.Tokens, true)
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, true)
 	ast := p.Parse()
 
 	checkParserErrors(t, p)
@@ -1009,6 +1904,344 @@ func TestParseLiteral(t *testing.T) {
 	}
 }
 
+func TestParseNumberLiteral(t *testing.T) {
+	input := test.MakeInput(
+		`0xFF + 0o755 + 0b1010`,
+		`1_000_000`,
+		`1e9 * 2.5e-3 - .5`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeBinaryExpression("+",
+			makeBinaryExpression("+", makeIntegerLiteral(255), makeIntegerLiteral(493)),
+			makeIntegerLiteral(10),
+		)),
+		makeExpressionStatement(makeIntegerLiteral(1000000)),
+		makeExpressionStatement(makeBinaryExpression("-",
+			makeBinaryExpression("*", makeFloatLiteral(1e9), makeFloatLiteral(0.0025)),
+			makeFloatLiteral(0.5),
+		)),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseExactNumberLiteral(t *testing.T) {
+	input := test.MakeInput(
+		`9223372036854775807 + 9223372036854775808`,
+		`0xFFFF_FFFF_FFFF_FFFF_FFFF`,
+		`12.30d * 3d`,
+		`let fee = 0.000_000_001d`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	program := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeBinaryExpression("+",
+			makeIntegerLiteral(9223372036854775807),
+			makeBigIntegerLiteral("9223372036854775808"),
+		)),
+		makeExpressionStatement(makeBigIntegerLiteral("1208925819614629174706175")),
+		makeExpressionStatement(makeBinaryExpression("*",
+			makeDecimalLiteral("12.30"),
+			makeDecimalLiteral("3"),
+		)),
+		makeVariableStatement(makeVariableDeclaration(
+			makeIdentifier("fee"),
+			makeDecimalLiteral("0.000000001"),
+		)),
+	)
+
+	if program.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, program)
+	}
+
+	data, err := json.Marshal(program.Statements[:3])
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, value := range []string{`"value":"9223372036854775808"`, `"value":"1208925819614629174706175"`, `"value":"12.30"`} {
+		if !strings.Contains(string(data), value) {
+			t.Errorf("Expected %s in the JSON AST, got %s", value, data)
+		}
+	}
+
+	literal := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.BigIntegerLiteral)
+	data, err = json.Marshal(literal)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var decoded ast.BigIntegerLiteral
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if decoded.Type != literal.Type || decoded.Value.Cmp(literal.Value) != 0 || decoded.Span != literal.Span {
+		t.Errorf("Expected %s to decode to %+v, got %+v", data, literal, decoded)
+	}
+}
+
+func TestParseMapLiteralJSON(t *testing.T) {
+	input := `{ "name": "eevee", level: 5, [key]: 1 }`
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	program := p.Parse()
+
+	checkParserErrors(t, p)
+
+	data, err := json.Marshal(program.Statements[0].(*ast.ExpressionStatement).Expression)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	type node struct {
+		Type  string      `json:"type"`
+		Name  string      `json:"name"`
+		Value interface{} `json:"value"`
+	}
+	var decoded struct {
+		Type  string `json:"type"`
+		Pairs []struct {
+			Type     string `json:"type"`
+			Computed bool   `json:"computed"`
+			Key      node   `json:"key"`
+			Value    node   `json:"value"`
+		} `json:"pairs"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if decoded.Type != "MapLiteral" || len(decoded.Pairs) != 3 {
+		t.Fatalf("Expected a MapLiteral with 3 pairs, got %s", data)
+	}
+
+	expected := []struct {
+		computed bool
+		key      node
+		value    node
+	}{
+		{false, node{Type: "StringLiteral", Value: "name"}, node{Type: "StringLiteral", Value: "eevee"}},
+		{false, node{Type: "Identifier", Name: "level"}, node{Type: "IntegerLiteral", Value: float64(5)}},
+		{true, node{Type: "Identifier", Name: "key"}, node{Type: "IntegerLiteral", Value: float64(1)}},
+	}
+
+	for i, tt := range expected {
+		pair := decoded.Pairs[i]
+		if pair.Type != "MapPair" || pair.Computed != tt.computed || pair.Key != tt.key || pair.Value != tt.value {
+			t.Errorf("Pair %d - Expected computed %t, key %+v and value %+v, got computed %t, key %+v and value %+v",
+				i, tt.computed, tt.key, tt.value, pair.Computed, pair.Key, pair.Value)
+		}
+	}
+}
+
+func TestParseNumberLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = 1e400`, `[1, 9] Float literal 1e400 is out of range`},
+		{`[1, -2e308]`, `[1, 6] Float literal 2e308 is out of range`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(test.MakeFile(tt.input))
+		p := New(l, false)
+		p.Parse()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q - Expected error %q, got none", tt.input, tt.expected)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("%q - Wrong error. Expected %q, got %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParseStringLiteral(t *testing.T) {
+	input := test.MakeInput(
+		`"tab\tnew\nline \"quoted\" \\ \u{1F600}"`,
+		`'single \'quoted\''`,
+		"`raw \\n string`",
+		`let text = """`,
+		`    first line`,
+		`  second "line"""`,
+		`text`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeStringLiteral("tab\tnew\nline \"quoted\" \\ \U0001F600")),
+		makeExpressionStatement(makeStringLiteral("single 'quoted'")),
+		makeExpressionStatement(makeStringLiteral("raw \\n string")),
+		makeVariableStatement(makeVariableDeclaration(
+			makeIdentifier("text"),
+			makeStringLiteral("\n    first line\n  second \"line"),
+		)),
+		makeExpressionStatement(makeIdentifier("text")),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParseInterpolatedString(t *testing.T) {
+	input := test.MakeInput(
+		`"hello {name}, lvl {lvl + 1}"`,
+		`"{m["key"]}{ {a: 1}.a }"`,
+		`"\{not} {x}"`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	ast := p.Parse()
+
+	checkParserErrors(t, p)
+
+	expectedAst := makeProgram(
+		makeExpressionStatement(makeInterpolatedString(
+			makeStringLiteral("hello "),
+			makeIdentifier("name"),
+			makeStringLiteral(", lvl "),
+			makeBinaryExpression("+", makeIdentifier("lvl"), makeIntegerLiteral(1)),
+		)),
+		makeExpressionStatement(makeInterpolatedString(
+			makeMemberExpression(true, makeIdentifier("m"), makeStringLiteral("key")),
+			makeMemberExpression(false,
+				makeMapLiteral(makeMapPair(false, makeIdentifier("a"), makeIntegerLiteral(1))),
+				makeIdentifier("a"),
+			),
+		)),
+		makeExpressionStatement(makeInterpolatedString(
+			makeStringLiteral("{not} "),
+			makeIdentifier("x"),
+		)),
+	)
+
+	if ast.String() != expectedAst.String() {
+		t.Fatalf("Expected: %q, got %q", expectedAst, ast)
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	input := test.MakeInput(
+		`let total = price * (1 + tax)`,
+		`if total > 100 then`,
+		`  print("big")`,
+		`let a, b`,
+	)
+
+	l := lexer.New(test.MakeFile(input))
+	p := New(l, false)
+	program := p.Parse()
+
+	checkParserErrors(t, p)
+
+	total := program.Statements[0].(*ast.VariableStatement)
+	product := total.Declarations[0].Initializer.(*ast.BinaryExpression)
+	ifStmt := program.Statements[1].(*ast.IfStatement)
+	call := ifStmt.Consequent.(*ast.BlockStatement).Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	ab := program.Statements[2].(*ast.VariableStatement)
+
+	tests := []struct {
+		name  string
+		node  ast.Node
+		start token.Position
+		end   token.Position
+	}{
+		{"program", program, pos(1, 1, 0), pos(6, 1, 74)},
+		{"let total", total, pos(1, 1, 0), pos(1, 30, 29)},
+		{"price * (1 + tax)", product, pos(1, 13, 12), pos(1, 30, 29)},
+		{"1 + tax", product.Right, pos(1, 22, 21), pos(1, 29, 28)},
+		{"if statement", ifStmt, pos(2, 1, 30), pos(3, 15, 64)},
+		{"total > 100", ifStmt.Condition, pos(2, 4, 33), pos(2, 15, 44)},
+		{"block", ifStmt.Consequent, pos(3, 3, 52), pos(3, 15, 64)},
+		{"print", call.Callee, pos(3, 3, 52), pos(3, 8, 57)},
+		{`"big"`, call.Arguments[0], pos(3, 9, 58), pos(3, 14, 63)},
+		{"let a, b", ab, pos(4, 1, 65), pos(4, 9, 73)},
+		{"null of a", ab.Declarations[0].Initializer, pos(4, 6, 70), pos(4, 6, 70)},
+		{"null of b", ab.Declarations[1].Initializer, pos(4, 9, 73), pos(4, 9, 73)},
+	}
+
+	for _, tt := range tests {
+		if tt.node.Pos() != tt.start || tt.node.End() != tt.end {
+			t.Errorf("%s - Expected span %+v to %+v, got %+v to %+v", tt.name, tt.start, tt.end, tt.node.Pos(), tt.node.End())
+		}
+	}
+
+	data, err := json.Marshal(product.Left)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := `{"type":"Identifier","name":"price","start":{"line":1,"column":13,"offset":12},"end":{"line":1,"column":18,"offset":17}}`
+	if string(data) != expected {
+		t.Errorf("Expected JSON %s, got %s", expected, data)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	tests := []string{
+		test.MakeInput(
+			`fn fact(n)`,
+			`	if n < 2 then return 1`,
+			`	return n * fact(n - 1)`,
+			`let xs = [fact(3),`,
+			`	fact(4)]`,
+			`while x do { x -= 1; print(x) }`,
+		),
+		test.MakeInput(
+			`let x = 1 +`,
+			`if x then`,
+			`	print(x 1)`,
+		),
+	}
+
+	for _, input := range tests {
+		eager := New(lexer.New(source.NewFileSet(4).AddFile("main.eev", []byte(input))), false)
+		expected := eager.Parse()
+
+		p := New(lexer.NewReader("main.eev", strings.NewReader(input), 4, 0), false)
+		program := p.Parse()
+
+		expectedJSON, _ := json.Marshal(expected)
+		data, err := json.Marshal(program)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if string(data) != string(expectedJSON) {
+			t.Errorf("%q - Expected %s, got %s", input, expectedJSON, data)
+		}
+
+		if fmt.Sprint(p.Errors()) != fmt.Sprint(eager.Errors()) {
+			t.Errorf("%q - Expected errors %q, got %q", input, eager.Errors(), p.Errors())
+		}
+	}
+}
+
 func makeProgram(stmts ...ast.Statement) *ast.Program {
 	s := []ast.Statement{}
 	s = append(s, stmts...)
@@ -1025,6 +2258,10 @@ func makeFunctionDeclaration(name ast.Identifier, params []ast.Identifier, body
 	return ast.NewFunctionDeclaration(name, params, body)
 }
 
+func makeFunctionLiteral(params []ast.Identifier, body ast.Statement) *ast.FunctionLiteral {
+	return ast.NewFunctionLiteral(params, body)
+}
+
 func makeFunctionParameters(params ...ast.Identifier) []ast.Identifier {
 	p := make([]ast.Identifier, 0)
 
@@ -1087,22 +2324,64 @@ func makeUnaryExpression(op string, r ast.Expression) *ast.UnaryExpression {
 	return ast.NewUnaryExpression(op, r)
 }
 
+func makeCallExpression(callee ast.Expression, args ...ast.Expression) *ast.CallExpression {
+	a := []ast.Expression{}
+	a = append(a, args...)
+	return ast.NewCallExpression(callee, a)
+}
+
 func makeMemberExpression(comp bool, obj, prop ast.Expression) *ast.MemberExpression {
 	return ast.NewMemberExpression(comp, obj, prop)
 }
 
+func makeSliceExpression(obj, start, end ast.Expression) *ast.SliceExpression {
+	return ast.NewSliceExpression(obj, start, end)
+}
+
+func makeArrayLiteral(elements ...ast.Expression) *ast.ArrayLiteral {
+	e := []ast.Expression{}
+	e = append(e, elements...)
+	return ast.NewArrayLiteral(e)
+}
+
+func makeMapLiteral(pairs ...*ast.MapPair) *ast.MapLiteral {
+	p := []*ast.MapPair{}
+	p = append(p, pairs...)
+	return ast.NewMapLiteral(p)
+}
+
+func makeMapPair(computed bool, key, value ast.Expression) *ast.MapPair {
+	return ast.NewMapPair(computed, key, value)
+}
+
 func makeIntegerLiteral(n int64) *ast.IntegerLiteral {
 	return ast.NewIntegerLiteral(n)
 }
 
+func makeBigIntegerLiteral(n string) *ast.BigIntegerLiteral {
+	value, _ := new(big.Int).SetString(n, 10)
+	return ast.NewBigIntegerLiteral(value)
+}
+
 func makeFloatLiteral(n float64) *ast.FloatLiteral {
 	return ast.NewFloatLiteral(n)
 }
 
+func makeDecimalLiteral(d string) *ast.DecimalLiteral {
+	value, _ := decimal.Parse(d)
+	return ast.NewDecimalLiteral(value)
+}
+
 func makeStringLiteral(s string) *ast.StringLiteral {
 	return ast.NewStringLiteral(s)
 }
 
+func makeInterpolatedString(parts ...ast.Expression) *ast.InterpolatedString {
+	p := []ast.Expression{}
+	p = append(p, parts...)
+	return ast.NewInterpolatedString(p)
+}
+
 func makeBoolLiteral(b bool) *ast.BoolLiteral {
 	return ast.NewBoolLiteral(b)
 }
@@ -1115,6 +2394,10 @@ func makeIdentifier(name string) *ast.Identifier {
 	return ast.NewIdentifier(name)
 }
 
+func pos(line, column, offset int) token.Position {
+	return token.Position{Line: line, Column: column, Offset: offset}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -2,15 +2,17 @@ package repl
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os/user"
 
 	"github.com/TwiN/go-color"
+	"github.com/jellycat-io/eevee/evaluator"
 	"github.com/jellycat-io/eevee/lexer"
 	"github.com/jellycat-io/eevee/logger"
+	"github.com/jellycat-io/eevee/object"
 	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/source"
 )
 
 const PROMPT = "> "
@@ -23,6 +25,8 @@ func Start(in io.Reader, out io.Writer) {
 		panic(err)
 	}
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
+	fset := source.NewFileSet(source.DefaultTabSize)
 
 	fmt.Printf(color.InBlue("Eevee REPL 0.1.0 - Welcome %s\n"), user.Username)
 
@@ -34,22 +38,26 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line, 4)
-		for _, t := range l.Tokens {
-			fmt.Println(t)
+		l := lexer.New(fset.AddFile("", []byte(line)))
+		if l.Diagnostics().HasErrors() {
+			log.PrintDiagnostics(l.Diagnostics(), fset)
+			continue
 		}
-		p := parser.New(l.Tokens, true)
-		ast := p.Parse()
 
-		if len(p.Errors()) != 0 {
-			log.PrintParserErrors(p.Errors())
+		p := parser.New(l, true)
+		program := p.Parse()
+
+		if p.Diagnostics().HasErrors() {
+			log.PrintDiagnostics(p.Diagnostics(), fset)
+			continue
 		}
 
-		json, err := json.MarshalIndent(ast, "", "    ")
-		if err != nil {
-			log.Error(err.Error())
+		result := evaluator.Eval(program, env)
+		if object.IsError(result) {
+			fmt.Fprintln(out, color.InRed(result.Inspect()))
+			continue
 		}
 
-		fmt.Printf("%s\n", json)
+		fmt.Fprintln(out, result.Inspect())
 	}
 }
@@ -0,0 +1,327 @@
+// Package source keeps track of the source files of a program, so that
+// positions can name the file they are in. It is modelled on the FileSet
+// of go/token: each file registered in a FileSet gets a range of Pos
+// values, which are small integers that map back to a file, a line and a
+// column.
+package source
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultTabSize is the width of a tab when no tab size is configured.
+const DefaultTabSize = 4
+
+// BOM is the byte order mark that a source may start with.
+const BOM = "\uFEFF"
+
+// Pos is a compact position in a FileSet. It is the base of a file plus
+// a byte offset in that file. The zero value NoPos is no position at all.
+type Pos int
+
+const NoPos Pos = 0
+
+// IsValid reports whether p is a position.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// Position is the location of a Pos in a file. Line and Column start at 1.
+// Columns count runes, with tabs moving to the next tab stop, so they match
+// what an editor shows. Offset counts bytes from the start of the file.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+	Offset   int
+}
+
+// IsValid reports whether the position has a line.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as file:line:column, line:column when the
+// file has no name, or - when the position is not valid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.Filename != "" {
+		s = p.Filename + ":" + s
+	}
+
+	return s
+}
+
+// File is a source file registered in a FileSet.
+type File struct {
+	name    string
+	base    int
+	src     string
+	lines   []int // offsets of the first byte of each line
+	tabSize int
+	bom     bool  // the content started with a byte order mark
+	crlf    bool  // all the line breaks of the content were CRLF
+	crs     []int // offsets of the line breaks that were CRLF
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+// Base returns the Pos of the first byte of f.
+func (f *File) Base() int {
+	return f.base
+}
+
+// Size returns the length of the normalized source in bytes.
+func (f *File) Size() int {
+	return len(f.src)
+}
+
+// Source returns the content of f, with its line endings normalized and
+// its byte order mark removed. Offsets are counted in this content.
+func (f *File) Source() string {
+	return f.src
+}
+
+// Denormalize returns text, a content normalized like the source of f,
+// with the byte order mark and the line breaks that f was read with. The
+// source of f is denormalized to the exact content. Another text gets CRLF
+// line breaks if all those of f were, and LF line breaks otherwise.
+func (f *File) Denormalize(text string) string {
+	if text == f.src {
+		return Denormalize(text, f.bom, f.crs)
+	}
+
+	if f.crlf {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+
+	return Denormalize(text, f.bom, nil)
+}
+
+// Denormalize returns the normalized content text with a byte order mark
+// if bom is set, and with a carriage return before the line breaks at the
+// offsets crs, which are sorted.
+func Denormalize(text string, bom bool, crs []int) string {
+	var out strings.Builder
+	out.Grow(len(BOM) + len(text) + len(crs))
+
+	if bom {
+		out.WriteString(BOM)
+	}
+	last := 0
+	for _, offset := range crs {
+		if offset > len(text) {
+			break
+		}
+		out.WriteString(text[last:offset])
+		out.WriteByte('\r')
+		last = offset
+	}
+	out.WriteString(text[last:])
+
+	return out.String()
+}
+
+// RawOffset returns the offset in the content f was read from of the byte
+// at offset in its source, counting the byte order mark and the carriage
+// returns removed before it.
+func (f *File) RawOffset(offset int) int {
+	raw := offset + sort.SearchInts(f.crs, offset)
+	if f.bom {
+		raw += len(BOM)
+	}
+
+	return raw
+}
+
+func (f *File) TabSize() int {
+	return f.tabSize
+}
+
+func (f *File) LineCount() int {
+	return len(f.lines)
+}
+
+// LineStart returns the offset of the first byte of line, which starts
+// at 1.
+func (f *File) LineStart(line int) int {
+	if line < 1 || line > len(f.lines) {
+		panic(fmt.Sprintf("source: line %d out of range [1, %d] in %s", line, len(f.lines), f.name))
+	}
+
+	return f.lines[line-1]
+}
+
+// Line returns the text of line, without its line break, or an empty
+// string when f has no such line.
+func (f *File) Line(line int) string {
+	if line < 1 || line > len(f.lines) {
+		return ""
+	}
+
+	text := f.src[f.lines[line-1]:]
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+
+	return text
+}
+
+// Pos returns the Pos of the byte at offset, which may be the size of the
+// file for the position just after its last byte.
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > len(f.src) {
+		panic(fmt.Sprintf("source: offset %d out of range [0, %d] in %s", offset, len(f.src), f.name))
+	}
+
+	return Pos(f.base + offset)
+}
+
+// Offset returns the byte offset of p, which must be a Pos of f.
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+len(f.src) {
+		panic(fmt.Sprintf("source: Pos %d is not in %s", p, f.name))
+	}
+
+	return int(p) - f.base
+}
+
+// Position returns the file, line and column of p, which must be a Pos of
+// f.
+func (f *File) Position(p Pos) Position {
+	offset := f.Offset(p)
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+	lineStart := f.lines[line-1]
+
+	return Position{
+		Filename: f.name,
+		Line:     line,
+		Column:   f.Advance(1, f.src[lineStart:offset]),
+		Offset:   offset,
+	}
+}
+
+// Advance returns the column reached after text when it starts at column
+// on a line of f.
+func (f *File) Advance(column int, text string) int {
+	return Advance(column, text, f.tabSize)
+}
+
+// Advance returns the column reached after text when it starts at column.
+// Each rune is one column and a tab moves to the next stop of tabSize.
+func Advance(column int, text string, tabSize int) int {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\t':
+			column += tabSize - (column-1)%tabSize
+		case c&0xC0 != 0x80: // not a UTF-8 continuation byte
+			column++
+		}
+	}
+
+	return column
+}
+
+// FileSet is a set of source files whose Pos ranges do not overlap.
+type FileSet struct {
+	base    int
+	files   []*File
+	tabSize int
+}
+
+// NewFileSet returns an empty FileSet whose files expand tabs to tabSize
+// columns, or to DefaultTabSize if tabSize is not positive.
+func NewFileSet(tabSize int) *FileSet {
+	if tabSize <= 0 {
+		tabSize = DefaultTabSize
+	}
+
+	return &FileSet{base: 1, tabSize: tabSize}
+}
+
+// TabSize returns the width of a tab in the files of s.
+func (s *FileSet) TabSize() int {
+	return s.tabSize
+}
+
+// AddFile registers the content src under filename and returns the new
+// file. A leading byte order mark is removed and CRLF line endings are
+// turned into LF, so the lexer only ever sees \n.
+func (s *FileSet) AddFile(filename string, src []byte) *File {
+	raw := string(src)
+	content := strings.ReplaceAll(strings.TrimPrefix(raw, BOM), "\r\n", "\n")
+	crlf := strings.Count(raw, "\r\n")
+
+	f := &File{
+		name:    filename,
+		base:    s.base,
+		src:     content,
+		lines:   []int{0},
+		tabSize: s.tabSize,
+		bom:     strings.HasPrefix(raw, BOM),
+		crlf:    crlf > 0 && crlf == strings.Count(raw, "\n"),
+	}
+	// j follows in raw the byte at i in content.
+	j := len(raw) - len(strings.TrimPrefix(raw, BOM))
+	for i := 0; i < len(content); i, j = i+1, j+1 {
+		if content[i] == '\n' {
+			f.lines = append(f.lines, i+1)
+			if raw[j] == '\r' {
+				f.crs = append(f.crs, i)
+				j++
+			}
+		}
+	}
+
+	// The position after the last byte belongs to the file too, so the
+	// next file starts one further.
+	s.base += len(content) + 1
+	s.files = append(s.files, f)
+
+	return f
+}
+
+// File returns the file that contains p, or nil if there is none.
+func (s *FileSet) File(p Pos) *File {
+	i := sort.Search(len(s.files), func(i int) bool { return s.files[i].base > int(p) })
+	if i == 0 || !p.IsValid() {
+		return nil
+	}
+
+	f := s.files[i-1]
+	if int(p) > f.base+len(f.src) {
+		return nil
+	}
+
+	return f
+}
+
+// Lookup returns the last file added under filename, or nil if there is
+// none.
+func (s *FileSet) Lookup(filename string) *File {
+	for i := len(s.files) - 1; i >= 0; i-- {
+		if s.files[i].name == filename {
+			return s.files[i]
+		}
+	}
+
+	return nil
+}
+
+// Position returns the file, line and column of p, or the zero Position if
+// p is not in s.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+
+	return Position{}
+}
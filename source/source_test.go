@@ -0,0 +1,97 @@
+package source
+
+import "testing"
+
+func TestPosition(t *testing.T) {
+	fset := NewFileSet(4)
+	main := fset.AddFile("main.eev", []byte("let x = 1\n\tlet é = \"ü\"\t# hp\n"))
+	lib := fset.AddFile("lib.eev", []byte("fn f()\n  1"))
+
+	tests := []struct {
+		file     *File
+		offset   int
+		expected string
+	}{
+		{main, 0, "main.eev:1:1"},
+		{main, 4, "main.eev:1:5"},
+		{main, 9, "main.eev:1:10"},
+		{main, 10, "main.eev:2:1"},
+		{main, 11, "main.eev:2:5"},
+		{main, 15, "main.eev:2:9"},
+		{main, 18, "main.eev:2:11"},
+		{main, 20, "main.eev:2:13"},
+		{main, 24, "main.eev:2:16"},
+		{main, 25, "main.eev:2:17"},
+		{main, 30, "main.eev:3:1"},
+		{lib, 0, "lib.eev:1:1"},
+		{lib, 10, "lib.eev:2:4"},
+	}
+
+	for _, tt := range tests {
+		p := tt.file.Pos(tt.offset)
+
+		if f := fset.File(p); f != tt.file {
+			t.Errorf("%s@%d - Expected Pos %d to be in %s, got %v", tt.file.Name(), tt.offset, p, tt.file.Name(), f)
+			continue
+		}
+
+		position := fset.Position(p)
+		if position.String() != tt.expected {
+			t.Errorf("%s@%d - Expected %s, got %s", tt.file.Name(), tt.offset, tt.expected, position)
+		}
+		if position.Offset != tt.offset {
+			t.Errorf("%s@%d - Expected offset %d, got %d", tt.file.Name(), tt.offset, tt.offset, position.Offset)
+		}
+	}
+
+	if position := fset.Position(NoPos); position.IsValid() || position.String() != "-" {
+		t.Errorf("Expected NoPos to have no position, got %s", position)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	fset := NewFileSet(0)
+	f := fset.AddFile("", []byte("\uFEFFlet x = 1\r\nx\r\n"))
+
+	if f.Source() != "let x = 1\nx\n" {
+		t.Fatalf("Expected the BOM and CRs to be removed, got %q", f.Source())
+	}
+	if f.LineCount() != 3 {
+		t.Errorf("Expected 3 lines, got %d", f.LineCount())
+	}
+	if position := f.Position(f.Pos(10)); position.String() != "2:1" {
+		t.Errorf("Expected 2:1, got %s", position)
+	}
+	if f.TabSize() != DefaultTabSize {
+		t.Errorf("Expected the default tab size, got %d", f.TabSize())
+	}
+}
+
+func TestDenormalize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1\nx\n", "let x = 1\nx\n"},
+		{"\uFEFFlet x = 1\r\nx\r\n", "\uFEFFlet x = 1\r\nx\r\n"},
+		{"let x = 1\r\nx", "let x = 1\r\nx"},
+		{"let x = 1\r\nx\n", "let x = 1\r\nx\n"},
+		{"\uFEFFa\nb\r\n\r\nc\n\r\n", "\uFEFFa\nb\r\n\r\nc\n\r\n"},
+	}
+
+	fset := NewFileSet(0)
+	for _, tt := range tests {
+		f := fset.AddFile("", []byte(tt.input))
+		if text := f.Denormalize(f.Source()); text != tt.expected {
+			t.Errorf("%q - Expected %q, got %q", tt.input, tt.expected, text)
+		}
+	}
+
+	// Another text only gets CRLF line breaks if all those of the file were.
+	for input, expected := range map[string]string{"a\r\nb\r\n": "x\r\ny\r\n", "a\r\nb\n": "x\ny\n"} {
+		f := fset.AddFile("", []byte(input))
+		if text := f.Denormalize("x\ny\n"); text != expected {
+			t.Errorf("%q - Expected %q, got %q", input, expected, text)
+		}
+	}
+}
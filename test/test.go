@@ -2,6 +2,8 @@ package test
 
 import (
 	"bytes"
+
+	"github.com/jellycat-io/eevee/source"
 )
 
 // MakeInput takes code lines as strings or returns them in a single string separated by '\n'
@@ -13,3 +15,8 @@ func MakeInput(lines ...string) string {
 	}
 	return out.String()
 }
+
+// MakeFile registers input as an unnamed source file with a tab size of 4
+func MakeFile(input string) *source.File {
+	return source.NewFileSet(4).AddFile("", []byte(input))
+}
@@ -1,12 +1,64 @@
 package token
 
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
 type TokenType string
 
+// Position is a location in the source. Line and Column start at 1 and
+// Offset counts the bytes before it.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
 	Line    int
 	Column  int
+	Offset  int
+	Trivia  *Trivia // nil unless the lexer keeps trivia
+}
+
+type TriviaKind int
+
+const (
+	SpaceTrivia        TriviaKind = iota // spaces and tabs
+	NewlineTrivia                        // a line break
+	CommentTrivia                        // a comment, up to the end of its line
+	ContinuationTrivia                   // a backslash that continues a line
+)
+
+// TriviaPiece is a run of source text of one kind of trivia.
+type TriviaPiece struct {
+	Kind TriviaKind
+	Text string
+}
+
+// Trivia is the text around a token that is not part of any token. The
+// trailing trivia of a token goes up to the end of its line, line break
+// included, and the leading trivia of the next token holds the rest. INDENT
+// and DEDENT tokens have no trailing trivia. Lines that are not joined end
+// with an EOL token, so a comment on a line of its own leads the EOL token
+// of that line.
+type Trivia struct {
+	Leading  []TriviaPiece
+	Trailing []TriviaPiece
+}
+
+// TriviaText returns the text of pieces.
+func TriviaText(pieces []TriviaPiece) string {
+	var out strings.Builder
+	for _, piece := range pieces {
+		out.WriteString(piece.Text)
+	}
+
+	return out.String()
 }
 
 func NewToken(tokType TokenType, literal string, line, column int) Token {
@@ -18,6 +70,30 @@ func NewToken(tokType TokenType, literal string, line, column int) Token {
 	}
 }
 
+// String returns the type, literal and position of the token, for
+// debugging.
+func (t Token) String() string {
+	return fmt.Sprintf("%s %q at %d:%d", t.Type, t.Literal, t.Line, t.Column)
+}
+
+// Pos returns the position of the first character of the token.
+func (t Token) Pos() Position {
+	return Position{Line: t.Line, Column: t.Column, Offset: t.Offset}
+}
+
+// End returns the position just after the last character of the token,
+// which is on a later line for multi-line strings. Columns count runes,
+// but a tab inside a string literal only counts as one.
+func (t Token) End() Position {
+	end := Position{Line: t.Line, Column: t.Column + utf8.RuneCountInString(t.Literal), Offset: t.Offset + len(t.Literal)}
+	if i := strings.LastIndexByte(t.Literal, '\n'); i >= 0 {
+		end.Line += strings.Count(t.Literal, "\n")
+		end.Column = 1 + utf8.RuneCountInString(t.Literal[i+1:])
+	}
+
+	return end
+}
+
 const (
 	ILLEGAL        = TokenType("ILLEGAL")
 	WHITESPACE     = TokenType("WHITESPACE")
@@ -57,6 +133,7 @@ const (
 	RBRACE         = TokenType("}")
 	LBRACKET       = TokenType("[")
 	RBRACKET       = TokenType("]")
+	NOT            = TokenType("NOT")
 	FUNCTION       = TokenType("FUNCTION")
 	MODULE         = TokenType("MODULE")
 	IMPORT         = TokenType("IMPORT")
@@ -73,7 +150,11 @@ const (
 	NULL           = TokenType("NULL")
 	INT            = TokenType("INT")
 	FLOAT          = TokenType("FLOAT")
+	DECIMAL        = TokenType("DECIMAL")
 	STRING         = TokenType("STRING")
+	INTERP_START   = TokenType("INTERP_START")
+	INTERP_MID     = TokenType("INTERP_MID")
+	INTERP_END     = TokenType("INTERP_END")
 )
 
 var Keywords = map[string]TokenType{
@@ -89,7 +170,7 @@ var Keywords = map[string]TokenType{
 	"let":    LET,
 	"module": MODULE,
 	"null":   NULL,
-	"not":    NOT_EQ,
+	"not":    NOT,
 	"or":     OR,
 	"return": RETURN,
 	"then":   THEN,
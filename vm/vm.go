@@ -0,0 +1,439 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/jellycat-io/eevee/code"
+	"github.com/jellycat-io/eevee/compiler"
+	"github.com/jellycat-io/eevee/object"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+type VM struct {
+	constants []object.Object
+	globals   []object.Object
+
+	stack []object.Object
+	sp    int // Always points to the next free slot. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	openCells   []openCell // cells pointing at the stack, by slot
+	openGlobals []openCell // cells pointing at the globals, by slot
+
+	lastPopped object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Name:         "main",
+		Instructions: bytecode.Instructions,
+		Lines:        bytecode.Lines,
+	}
+	mainFrame := NewFrame(&object.Closure{Fn: mainFn}, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	// Globals start out as null, like locals, until assigned.
+	globals := make([]object.Object, GlobalsSize)
+	for i := range globals {
+		globals[i] = object.NULL
+	}
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		stack:       make([]object.Object, StackSize),
+		frames:      frames,
+		framesIndex: 1,
+		lastPopped:  object.NULL,
+	}
+}
+
+// Result returns the value of the last expression statement executed,
+// or the value returned by a top-level return statement.
+func (vm *VM) Result() object.Object {
+	return vm.lastPopped
+}
+
+func (vm *VM) Run() error {
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		frame := vm.currentFrame()
+		frame.ip++
+
+		ip := frame.ip
+		ins := frame.Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpDup:
+			count := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			for i := 0; i < count && err == nil; i++ {
+				err = vm.push(vm.stack[vm.sp-count])
+			}
+
+		case code.OpTrue:
+			err = vm.push(object.TRUE)
+		case code.OpFalse:
+			err = vm.push(object.FALSE)
+		case code.OpNull:
+			err = vm.push(object.NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpLess, code.OpLessEqual, code.OpGreater, code.OpGreaterEqual:
+			err = vm.executeBinaryOperation(op)
+
+		case code.OpMinus, code.OpPlus, code.OpBang:
+			err = vm.push(object.UnaryOp(code.Operators[op], vm.pop()))
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+
+		case code.OpJumpNotTruthy:
+			frame.ip += 2
+			if !object.IsTruthy(vm.pop()) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+			}
+
+		case code.OpJumpFalsyKeep, code.OpJumpTruthyKeep:
+			frame.ip += 2
+			if object.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpTruthyKeep) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.push(vm.globals[globalIndex])
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.globals[globalIndex] = vm.stack[vm.sp-1]
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			err = vm.push(vm.stack[frame.basePointer+int(localIndex)])
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			vm.stack[frame.basePointer+int(localIndex)] = vm.stack[vm.sp-1]
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			err = vm.push(*frame.cl.Free[freeIndex].Ref)
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			*frame.cl.Free[freeIndex].Ref = vm.stack[vm.sp-1]
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			slot := frame.basePointer + int(localIndex)
+			err = vm.push(captureCell(&vm.openCells, slot, &vm.stack[slot]))
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			err = vm.push(frame.cl.Free[freeIndex])
+
+		case code.OpCaptureGlobal:
+			globalIndex := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.push(captureCell(&vm.openGlobals, globalIndex, &vm.globals[globalIndex]))
+
+		case code.OpCloseCells:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			closeCells(&vm.openCells, frame.basePointer+int(localIndex))
+
+		case code.OpCloseGlobals:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			closeCells(&vm.openGlobals, int(globalIndex))
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			str := object.Interpolate(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp -= numParts
+			err = vm.push(str)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpMap:
+			numPairs := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			err = vm.buildMap(numPairs)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.push(object.IndexOp(left, index))
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.push(object.SetIndexOp(left, index, value))
+
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+			err = vm.push(object.SliceOp(left, start, end))
+
+		case code.OpProperty:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			name := vm.constants[constIndex].(*object.String).Value
+			err = vm.push(object.PropertyOp(vm.pop(), name))
+
+		case code.OpSetProperty:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			name := vm.constants[constIndex].(*object.String).Value
+			value := vm.pop()
+			err = vm.push(object.SetPropertyOp(vm.pop(), name, value))
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			frame.ip += 3
+			err = vm.pushClosure(int(constIndex), int(numFree))
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			err = vm.callFunction(int(numArgs))
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				vm.lastPopped = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			closeCells(&vm.openCells, frame.basePointer)
+			vm.sp = frame.basePointer - 1
+			err = vm.push(returnValue)
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			closeCells(&vm.openCells, frame.basePointer)
+			vm.sp = frame.basePointer - 1
+			err = vm.push(object.NULL)
+
+		default:
+			err = fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if err == nil && vm.sp > 0 && object.IsError(vm.stack[vm.sp-1]) {
+			err = fmt.Errorf("%s", vm.stack[vm.sp-1].(*object.Error).Message)
+		}
+
+		if err != nil {
+			return vm.runtimeError(frame, ip, err)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	// Fast path for the integer arithmetic that dominates loops. Results
+	// that overflow are left to BinaryOp, which promotes them.
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				if sum := l.Value + r.Value; (sum > l.Value) == (r.Value > 0) {
+					return vm.push(&object.Integer{Value: sum})
+				}
+			case code.OpSub:
+				if diff := l.Value - r.Value; (diff < l.Value) == (r.Value > 0) {
+					return vm.push(&object.Integer{Value: diff})
+				}
+			case code.OpLess:
+				return vm.push(object.NativeBool(l.Value < r.Value))
+			case code.OpGreater:
+				return vm.push(object.NativeBool(l.Value > r.Value))
+			}
+		}
+	}
+
+	return vm.push(object.BinaryOp(code.Operators[op], left, right))
+}
+
+func (vm *VM) buildMap(numPairs int) error {
+	m := object.NewMap()
+
+	for i := vm.sp - 2*numPairs; i < vm.sp; i += 2 {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as map key: %s", vm.stack[i].Type())
+		}
+		m.Set(key, vm.stack[i+1])
+	}
+	vm.sp -= 2 * numPairs
+
+	return vm.push(m)
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i, cell := range vm.stack[vm.sp-numFree : vm.sp] {
+		free[i] = cell.(*object.Cell)
+	}
+	vm.sp -= numFree
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+// openCell is a cell that points at a slot of the stack or of the globals.
+type openCell struct {
+	slot int
+	cell *object.Cell
+}
+
+// captureCell returns the cell of the slot that ref points at, which all
+// the closures capturing the variable in that slot share. cells holds the
+// open cells of the slots of the same kind, by slot.
+func captureCell(cells *[]openCell, slot int, ref *object.Object) *object.Cell {
+	open := *cells
+	i := len(open)
+	for i > 0 && open[i-1].slot >= slot {
+		if open[i-1].slot == slot {
+			return open[i-1].cell
+		}
+		i--
+	}
+
+	cell := &object.Cell{Ref: ref}
+	open = append(open, openCell{})
+	copy(open[i+1:], open[i:])
+	open[i] = openCell{slot: slot, cell: cell}
+	*cells = open
+
+	return cell
+}
+
+// closeCells closes the cells of the slots from the slot from, which are
+// about to be reused.
+func closeCells(cells *[]openCell, from int) {
+	open := *cells
+	i := len(open)
+	for i > 0 && open[i-1].slot >= from {
+		i--
+		open[i].cell.Close()
+	}
+	*cells = open[:i]
+}
+
+func (vm *VM) callFunction(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	cl, ok := callee.(*object.Closure)
+	if !ok {
+		return fmt.Errorf("%s is not callable", callee.Type())
+	}
+
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments for %s: want=%d, got=%d", cl.Fn.Name, cl.Fn.NumParameters, numArgs)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow")
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	if frame.basePointer+cl.Fn.NumLocals >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	// Locals beyond the arguments start out as null until assigned.
+	for i := vm.sp; i < frame.basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = object.NULL
+	}
+
+	vm.pushFrame(frame)
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) runtimeError(frame *Frame, ip int, err error) error {
+	line := code.LineFor(frame.cl.Fn.Lines, ip)
+	return fmt.Errorf("line %d: %s", line, err)
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(obj object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = obj
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp-1]
+	vm.sp--
+	return obj
+}
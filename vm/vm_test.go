@@ -0,0 +1,531 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jellycat-io/eevee/compiler"
+	"github.com/jellycat-io/eevee/decimal"
+	"github.com/jellycat-io/eevee/lexer"
+	"github.com/jellycat-io/eevee/object"
+	"github.com/jellycat-io/eevee/parser"
+	"github.com/jellycat-io/eevee/test"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestArithmetic(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{`42`, int64(42)},
+		{`2 + 2 * 3`, int64(8)},
+		{`10 - 2 - 3`, int64(5)},
+		{`100 / 10 / 5`, int64(2)},
+		{`(2 + 2) * 3`, int64(12)},
+		{`7 / 2`, int64(3)},
+		{`7 % 2`, int64(1)},
+		{`7 / 2.0`, 3.5},
+		{`-2 + 1.5`, -0.5},
+		{`"eev" + "ee"`, "eevee"},
+	})
+}
+
+func TestComparisonsAndLogic(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{`1 < 2`, true},
+		{`2 <= 1`, false},
+		{`1 == 1.0`, true},
+		{`"a" != "b"`, true},
+		{`!null`, true},
+		{`not 1 == 2`, true},
+		{`1 is not 2`, true},
+		{`true and false`, false},
+		{`null or "default"`, "default"},
+		{`1 and 2`, int64(2)},
+	})
+}
+
+func TestVariablesAndScopes(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{
+			test.MakeInput(
+				`let x = 40, y`,
+				`y = 2`,
+				`x += y`,
+				`x`,
+			),
+			int64(42),
+		},
+		{
+			test.MakeInput(
+				`let x = 1`,
+				`if true then`,
+				`	let x = 2`,
+				`	x = 3`,
+				`x`,
+			),
+			int64(1),
+		},
+	})
+}
+
+func TestControlFlow(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{
+			test.MakeInput(
+				`let level = 16, pokemon`,
+				`if level >= 16 then`,
+				`	pokemon = "ivysaur"`,
+				`else`,
+				`	pokemon = "bulbasaur"`,
+				`pokemon`,
+			),
+			"ivysaur",
+		},
+		{
+			test.MakeInput(
+				`let x = 0`,
+				`while x < 10 do`,
+				`	x += 3`,
+				`x`,
+			),
+			int64(12),
+		},
+		{
+			test.MakeInput(
+				`let x = 10`,
+				`do x += 1 while x < 5`,
+				`x`,
+			),
+			int64(11),
+		},
+		{
+			test.MakeInput(
+				`let sum = 0`,
+				`for let i = 0; i < 5; i += 1 do`,
+				`	sum += i`,
+				`sum`,
+			),
+			int64(10),
+		},
+		{
+			test.MakeInput(
+				`let x = 0`,
+				`while true do`,
+				`	x += 1`,
+				`	if x == 3 then return x * 10`,
+			),
+			int64(30),
+		},
+	})
+}
+
+func TestFunctions(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{
+			test.MakeInput(
+				`fn add(x, y) x + y`,
+				`add(40, 2)`,
+			),
+			int64(42),
+		},
+		{
+			test.MakeInput(
+				`fn fib(n)`,
+				`	if n < 2 then return n`,
+				`	return fib(n - 1) + fib(n - 2)`,
+				`fib(15)`,
+			),
+			int64(610),
+		},
+		{
+			test.MakeInput(
+				`fn nothing()`,
+				`	1`,
+				`nothing()`,
+			),
+			nil,
+		},
+		{
+			test.MakeInput(
+				`fn isEven(n) if n == 0 then return true else return isOdd(n - 1)`,
+				`fn isOdd(n) if n == 0 then return false else return isEven(n - 1)`,
+				`isEven(10)`,
+			),
+			true,
+		},
+		{
+			test.MakeInput(
+				`let f = fn () 2`,
+				`let r = []`,
+				`if true then`,
+				`	r = r + [f()]`,
+				`	fn f() 1`,
+				`	r = r + [f()]`,
+				`r`,
+			),
+			[]interface{}{int64(2), int64(1)},
+		},
+		{
+			test.MakeInput(
+				`fn adder(x)`,
+				`	fn add(y) x + y`,
+				`	return add`,
+				`let addTwo = adder(2)`,
+				`addTwo(40)`,
+			),
+			int64(42),
+		},
+		{
+			test.MakeInput(
+				`let add = fn (a) fn (b) a + b`,
+				`add(40)(2)`,
+			),
+			int64(42),
+		},
+		{
+			test.MakeInput(
+				`let twice = fn (f, x)`,
+				`	return f(f(x))`,
+				`twice(fn (x) x * 3, 2)`,
+			),
+			int64(18),
+		},
+		{
+			test.MakeInput(
+				`fn counter()`,
+				`	let count = [0]`,
+				`	return fn ()`,
+				`		count[0] += 1`,
+				`		return count[0]`,
+				`let next = counter()`,
+				`next()`,
+				`next()`,
+			),
+			int64(2),
+		},
+		{
+			test.MakeInput(
+				`let fact = fn (n)`,
+				`	if n < 2 then return 1`,
+				`	return n * fact(n - 1)`,
+				`fact(5)`,
+			),
+			int64(120),
+		},
+		{
+			test.MakeInput(
+				`fn outer()`,
+				`	let fact = fn (n)`,
+				`		if n < 2 then return 1`,
+				`		return n * fact(n - 1)`,
+				`	return fact(5)`,
+				`outer()`,
+			),
+			int64(120),
+		},
+		{`(fn () return)()`, nil},
+	})
+}
+
+func TestClosures(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{
+			test.MakeInput(
+				`fn counter()`,
+				`	let n = 0`,
+				`	return fn ()`,
+				`		n += 1`,
+				`		return n`,
+				`let next = counter()`,
+				`next()`,
+				`next()`,
+			),
+			int64(2),
+		},
+		{
+			test.MakeInput(
+				`fn later()`,
+				`	let x = 1`,
+				`	let get = fn () x`,
+				`	x = 2`,
+				`	return get()`,
+				`later()`,
+			),
+			int64(2),
+		},
+		{
+			test.MakeInput(
+				`fn shared()`,
+				`	let n = 0`,
+				`	let inc = fn () fn () n += 1`,
+				`	let get = fn () n`,
+				`	inc()()`,
+				`	inc()()`,
+				`	return get()`,
+				`shared()`,
+			),
+			int64(2),
+		},
+		{
+			test.MakeInput(
+				`fn each()`,
+				`	let fs = [null, null, null], i = 0`,
+				`	while i < 3 do`,
+				`		let j = i * 10`,
+				`		fs[i] = fn () j`,
+				`		i += 1`,
+				`	return fs[0]() + fs[1]() + fs[2]()`,
+				`each()`,
+			),
+			int64(30),
+		},
+		{
+			test.MakeInput(
+				`let fs = []`,
+				`for let i = 0; i < 3; i += 1 do`,
+				`	let j = i`,
+				`	fs = fs + [fn () j]`,
+				`[fs[0](), fs[1](), fs[2]()]`,
+			),
+			[]interface{}{int64(0), int64(1), int64(2)},
+		},
+	})
+}
+
+func TestArrays(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{`[]`, []interface{}{}},
+		{`[1, "eevee", 2 * 3]`, []interface{}{int64(1), "eevee", int64(6)}},
+		{`[1, 2] + [3]`, []interface{}{int64(1), int64(2), int64(3)}},
+		{`[1, [2, 3]] == [1, [2, 3.0]]`, true},
+		{`[1, 2, 3][1]`, int64(2)},
+		{`[1, 2, 3][-1]`, int64(3)},
+		{`[1, 2, 3, 4][1:3]`, []interface{}{int64(2), int64(3)}},
+		{`[1, 2, 3, 4][:-1]`, []interface{}{int64(1), int64(2), int64(3)}},
+		{`[1, 2, 3, 4][2:]`, []interface{}{int64(3), int64(4)}},
+		{`[1, 2][5:]`, []interface{}{}},
+		{`"eevee"[1:3]`, "ev"},
+		{`"eevee"[:]`, "eevee"},
+		{
+			test.MakeInput(
+				`let xs = [1, 2, 3]`,
+				`xs[0] = 10`,
+				`xs[-1] *= 2`,
+				`xs`,
+			),
+			[]interface{}{int64(10), int64(2), int64(6)},
+		},
+		{
+			test.MakeInput(
+				`let i = 0, xs = [1, 1]`,
+				`fn next()`,
+				`	i += 1`,
+				`	return i`,
+				`xs[next()] += 1`,
+				`[xs, i]`,
+			),
+			[]interface{}{[]interface{}{int64(1), int64(2)}, int64(1)},
+		},
+		{
+			test.MakeInput(
+				`let xs = [1], ys = [1]`,
+				`xs[0] = xs`,
+				`ys[0] = ys`,
+				`[xs == xs, xs == ys, xs == [xs]]`,
+			),
+			[]interface{}{true, true, true},
+		},
+	})
+}
+
+func TestMaps(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{`{ "name": "eevee", level: 5 }["name"]`, "eevee"},
+		{`{ "name": "eevee", level: 5 }.level`, int64(5)},
+		{`{ [1 + 1]: "two" }[2.0]`, "two"},
+		{`{ level: 5 }.name`, nil},
+		{`{ a: 1, b: [2] } == { b: [2], a: 1.0 }`, true},
+		{
+			test.MakeInput(
+				`let pokemon = {`,
+				`	name: "eevee",`,
+				`	stats: { hp: 55 },`,
+				`}`,
+				`pokemon.stats.hp += 5`,
+				`pokemon["level"] = 5`,
+				`[pokemon.stats.hp, pokemon.level]`,
+			),
+			[]interface{}{int64(60), int64(5)},
+		},
+	})
+}
+
+func TestExactNumbers(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{`9223372036854775807 + 1`, bigInteger("9223372036854775808")},
+		{`-9223372036854775807 - 2`, bigInteger("-9223372036854775809")},
+		{`4611686018427387904 * 4`, bigInteger("18446744073709551616")},
+		{`(-9223372036854775807 - 1) / -1`, bigInteger("9223372036854775808")},
+		{`-(-9223372036854775807 - 1)`, bigInteger("9223372036854775808")},
+		{`9223372036854775808 - 1`, int64(9223372036854775807)},
+		{`18446744073709551616 % 10`, int64(6)},
+		{`18446744073709551616 > 9223372036854775807`, true},
+		{`18446744073709551616 == 18446744073709551616.0`, true},
+		{`{ [18446744073709551616]: "big" }[18446744073709551616.0]`, "big"},
+		{`0.1d + 0.2d`, decimalValue("0.3")},
+		{`0.1d + 0.2d == 0.3d`, true},
+		{`12.30d * 3`, decimalValue("36.90")},
+		{`10d / 3`, decimalValue("3.3333333333333333")},
+		{`-2.50d % 1`, decimalValue("-0.50")},
+		{`1.5d == 1.5`, true},
+		{`0.1d < 0.1`, true},
+		{`{ [1.50d]: "x", [2d]: "y" }[1.5] + { [2d]: "y" }[2]`, "xy"},
+		{`"total: {19.99d * 2}"`, "total: 39.98"},
+		{test.MakeInput(
+			`let n = 9223372036854775806`,
+			`for let i = 0; i < 3; i += 1 do`,
+			`	n = n + 1`,
+			`n`,
+		), bigInteger("9223372036854775809")},
+	})
+}
+
+func TestInterpolation(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{`"level {1 + 1}"`, "level 2"},
+		{test.MakeInput(`let name = "eevee"`, `"hi {name}!"`), "hi eevee!"},
+		{`"{[1, "a"]} {null} {true}"`, `[1, "a"] null true`},
+		{test.MakeInput(`let m = {hp: 40}`, `"{m.hp}/{m["hp"] + 10} hp"`), "40/50 hp"},
+		{test.MakeInput(`let greet = fn(n) return "hi {n}"`, `greet("{1}{2}")`), "hi 12"},
+		{test.MakeInput(`let x = 1`, `"""{x}"""`), "1"},
+		{test.MakeInput(`let x = 1`, `"""a{x}b"""`), "a1b"},
+	})
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 / 0`, "line 1: division by zero"},
+		{`1.5d / 0`, "line 1: division by zero"},
+		{`1.5d + 1.0`, "line 1: unsupported operand types for +: DECIMAL and FLOAT"},
+		{test.MakeInput(`let x = 1`, `x + "eevee"`), "line 2: unsupported operand types for +: INTEGER and STRING"},
+		{`"eevee"[5]`, "line 1: string index out of range: 5"},
+		{test.MakeInput(`let xs = [1, 2]`, `xs[2] = 3`), "line 2: array index out of range: 2"},
+		{`{ [[1]]: 1 }`, "line 1: unusable as map key: ARRAY"},
+		{`"eevee"()`, "line 1: STRING is not callable"},
+		{test.MakeInput(`fn f(x) x`, `f(1, 2)`), "line 2: wrong number of arguments for f: want=1, got=2"},
+		{`(fn (x) x)()`, "line 1: wrong number of arguments for anonymous: want=1, got=0"},
+		{test.MakeInput(`fn f(x)`, `	return 1 / x`, `f(0)`), "line 2: division by zero"},
+		{test.MakeInput(`f()`, `fn f() 1`), "line 1: identifier not found: f"},
+		{test.MakeInput(`let y = [g]`, `fn g() 1`, `y`), "line 1: identifier not found: g"},
+		{test.MakeInput(`let g = fn () f()`, `g()`, `fn f() 1`), "line 1: NULL is not callable"},
+	}
+
+	for _, tt := range tests {
+		vm := New(compile(t, tt.input))
+		err := vm.Run()
+		if err == nil {
+			t.Errorf("%q - Expected error %q, got none", tt.input, tt.expected)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("%q - Wrong error. Expected %q, got %q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func runVMTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		vm := New(compile(t, tt.input))
+		if err := vm.Run(); err != nil {
+			t.Fatalf("%q - VM error: %s", tt.input, err)
+		}
+
+		checkObject(t, tt.input, vm.Result(), tt.expected)
+	}
+}
+
+func bigInteger(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func decimalValue(s string) decimal.Decimal {
+	d, _ := decimal.Parse(s)
+	return d
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	l := lexer.New(test.MakeFile(input))
+	p := parser.New(l, false)
+	program := p.Parse()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q - Parser errors: %q", input, p.Errors())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("%q - Compiler error: %s", input, err)
+	}
+
+	return c.Bytecode()
+}
+
+func checkObject(t *testing.T, input string, obj object.Object, expected interface{}) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int64:
+		result, ok := obj.(*object.Integer)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected integer %d, got %q", input, expected, obj.Inspect())
+		}
+	case float64:
+		result, ok := obj.(*object.Float)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected float %v, got %q", input, expected, obj.Inspect())
+		}
+	case *big.Int:
+		result, ok := obj.(*object.BigInteger)
+		if !ok || result.Value.Cmp(expected) != 0 {
+			t.Errorf("%q - Expected big integer %s, got %q", input, expected, obj.Inspect())
+		}
+	case decimal.Decimal:
+		result, ok := obj.(*object.Decimal)
+		if !ok || result.Value.String() != expected.String() {
+			t.Errorf("%q - Expected decimal %s, got %q", input, expected, obj.Inspect())
+		}
+	case string:
+		result, ok := obj.(*object.String)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected string %q, got %q", input, expected, obj.Inspect())
+		}
+	case bool:
+		result, ok := obj.(*object.Bool)
+		if !ok || result.Value != expected {
+			t.Errorf("%q - Expected bool %t, got %q", input, expected, obj.Inspect())
+		}
+	case []interface{}:
+		result, ok := obj.(*object.Array)
+		if !ok || len(result.Elements) != len(expected) {
+			t.Errorf("%q - Expected array of %d elements, got %q", input, len(expected), obj.Inspect())
+			return
+		}
+		for i, e := range expected {
+			checkObject(t, input, result.Elements[i], e)
+		}
+	case nil:
+		if obj != object.NULL {
+			t.Errorf("%q - Expected null, got %q", input, obj.Inspect())
+		}
+	}
+}